@@ -25,7 +25,7 @@ import (
 // Item 堆元素
 type Item struct {
 	Data   interface{} // 携带的数据
-	Weight int         // 权重值（决定堆元素的顺序）
+	Weight int64       // 权重值（决定堆元素的顺序，比如纳秒时间戳）
 }
 
 type InterfaceHeap interface {
@@ -25,7 +25,7 @@ import (
 )
 
 // 用单调递增的变量来表示元素的顺序
-var uniq = 1
+var uniq int64 = 1
 var min = uniq
 
 func HeapOp_PushItem(heap InterfaceHeap, num int) interface{} {
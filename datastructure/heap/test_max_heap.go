@@ -25,7 +25,7 @@ import (
 )
 
 // 用单调递减的变量来表示元素的顺序
-var muniq = 1000000
+var muniq int64 = 1000000
 var mmax = muniq
 
 func MaxHeapOp_PushItem(heap InterfaceHeap, num int) interface{} {
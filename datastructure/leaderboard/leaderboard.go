@@ -0,0 +1,309 @@
+// Package leaderboard.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 排行榜(分数越高排名越靠前)
+// 基于有序集合实现，提供分数相同时的排名策略和先后顺序，以及"我附近的玩家"、分页等常用查询
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package leaderboard
+
+import (
+	"errors"
+	"github.com/stormYuanYang/yytools/common/assert"
+	"github.com/stormYuanYang/yytools/datastructure/sorted_set"
+	"math"
+	"time"
+)
+
+var ErrScoreNaN = errors.New("排行榜:分数不是数字")
+
+// 排名策略(决定分数相同的成员的排名)
+type RankPolicy int32
+
+const (
+	Ordinal             RankPolicy = iota // 0 顺序排名:1,2,3,4 (分数相同时按照先后顺序排名，排名不重复)
+	StandardCompetition                   // 1 标准竞赛排名:1,2,2,4 (分数相同时排名相同，之后的排名跳过)
+	Dense                                 // 2 密集排名:1,2,2,3 (分数相同时排名相同，之后的排名连续)
+)
+
+// 分数相同时成员的先后顺序
+type TieBreak int32
+
+const (
+	EarliestFirst TieBreak = iota // 0 先达到该分数的成员靠前
+	LatestFirst                   // 1 后达到该分数的成员靠前
+)
+
+// 排行榜中的一个条目
+type Entry[K comparable] struct {
+	Key        K
+	Score      float64
+	Rank       int       // 按照排名策略计算的排名(从1开始)
+	AchievedAt time.Time // 达到当前分数的时间
+}
+
+// 有序集合中的卫星数据
+type member struct {
+	achievedAt int64  // 达到当前分数的时间(纳秒)
+	seq        uint64 // 达到当前分数的序号(时间相同时用于区分先后)
+}
+
+type Leaderboard[K comparable] struct {
+	ss       *sorted_set.SortedSet[K, *member]
+	policy   RankPolicy
+	tieBreak TieBreak
+	clock    func() time.Time
+	seq      uint64
+	
+	// 密集排名需要知道有多少个不同的更高分数
+	// 用一个有序集合保存所有出现过的分数，以及每个分数的成员数量
+	scores      *sorted_set.SortedSet[float64, float64]
+	scoreCounts map[float64]int
+}
+
+func compareFloat64(a, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareUint64(a, b uint64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func NewLeaderboard[K comparable](policy RankPolicy, tieBreak TieBreak) *Leaderboard[K] {
+	assert.Assert(policy >= Ordinal && policy <= Dense, "不支持的排名策略:", policy)
+	assert.Assert(tieBreak == EarliestFirst || tieBreak == LatestFirst, "不支持的先后顺序:", tieBreak)
+	
+	// 有序集合是按照从低到高排序的，排行榜取的是逆序排名
+	// 所以在有序集合中，靠前的成员反而要"更大"
+	cmp := func(a, b *member) int {
+		if a.achievedAt != b.achievedAt {
+			if a.achievedAt < b.achievedAt {
+				return 1
+			}
+			return -1
+		}
+		return -compareUint64(a.seq, b.seq)
+	}
+	if tieBreak == LatestFirst {
+		earliest := cmp
+		cmp = func(a, b *member) int {
+			return -earliest(a, b)
+		}
+	}
+	return &Leaderboard[K]{
+		ss:          sorted_set.NewSortedSet[K, *member](cmp),
+		policy:      policy,
+		tieBreak:    tieBreak,
+		clock:       time.Now,
+		scores:      sorted_set.NewSortedSet[float64, float64](compareFloat64),
+		scoreCounts: map[float64]int{},
+	}
+}
+
+// 设置时钟(方便测试)
+func (this *Leaderboard[K]) SetClock(clock func() time.Time) {
+	assert.Assert(clock != nil, "clock must not be nil")
+	this.clock = clock
+}
+
+// 设置紧凑编码的最大元素数量(参考SortedSet.SetCompactMaxEntries)
+// 成员较少的排行榜(比如大量的公会、房间排行榜)可以节省内存
+func (this *Leaderboard[K]) SetCompactMaxEntries(maxEntries int) {
+	this.ss.SetCompactMaxEntries(maxEntries)
+	this.scores.SetCompactMaxEntries(maxEntries)
+}
+
+func (this *Leaderboard[K]) Length() int {
+	return this.ss.Length()
+}
+
+func (this *Leaderboard[K]) addScore(score float64) {
+	this.scoreCounts[score]++
+	if this.scoreCounts[score] == 1 {
+		this.scores.Insert(sorted_set.NewNodeData(score, score, score))
+	}
+}
+
+func (this *Leaderboard[K]) removeScore(score float64) {
+	this.scoreCounts[score]--
+	if this.scoreCounts[score] == 0 {
+		delete(this.scoreCounts, score)
+		this.scores.Delete(score)
+	}
+}
+
+// 设置成员的分数(成员不存在时添加)
+// 分数发生变化时，达到分数的时间更新为当前时间
+func (this *Leaderboard[K]) SetScore(key K, score float64) Entry[K] {
+	assert.Assert(!math.IsNaN(score), "score is not a number")
+	
+	if old := this.ss.Get(key); old != nil {
+		if old.Score == score {
+			return this.Get(key)
+		}
+		// 达到分数的时间变了，在有序集合中的位置也会变化，需要重新插入
+		this.ss.Delete(key)
+		this.removeScore(old.Score)
+	}
+	this.seq++
+	m := &member{
+		achievedAt: this.clock().UnixNano(),
+		seq:        this.seq,
+	}
+	this.ss.Insert(sorted_set.NewNodeData(key, score, m))
+	this.addScore(score)
+	return this.Get(key)
+}
+
+// 增加成员的分数(成员不存在时以delta作为分数添加)
+// 新的分数不是数字时(比如正无穷加负无穷)不做修改，返回ErrScoreNaN
+func (this *Leaderboard[K]) IncrScore(key K, delta float64) (Entry[K], error) {
+	score := delta
+	if old := this.ss.Get(key); old != nil {
+		score += old.Score
+	}
+	if math.IsNaN(score) {
+		return Entry[K]{}, ErrScoreNaN
+	}
+	return this.SetScore(key, score), nil
+}
+
+func (this *Leaderboard[K]) Remove(key K) bool {
+	data, ok := this.ss.Delete(key)
+	if ok {
+		this.removeScore(data.Score)
+	}
+	return ok
+}
+
+// 排名在[position, position+n)中的成员,position是排行榜中的位置(即顺序排名)
+func (this *Leaderboard[K]) entries(position int, n int) []Entry[K] {
+	entries := make([]Entry[K], 0, n)
+	if position > this.Length() || n <= 0 {
+		return entries
+	}
+	datas := this.ss.GetRevRangeByRank(position, position+n-1)
+	for i, data := range datas {
+		entry := Entry[K]{
+			Key:        data.Key,
+			Score:      data.Score,
+			AchievedAt: time.Unix(0, data.Val.achievedAt),
+		}
+		switch {
+		case i == 0:
+			entry.Rank = this.rankOf(data.Score, position)
+		case data.Score == datas[i-1].Score:
+			// 分数相同，排名由排名策略决定
+			if this.policy == Ordinal {
+				entry.Rank = position + i
+			} else {
+				entry.Rank = entries[i-1].Rank
+			}
+		default:
+			if this.policy == Dense {
+				entry.Rank = entries[i-1].Rank + 1
+			} else {
+				entry.Rank = position + i
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// 根据排名策略计算排名
+// position是成员在排行榜中的位置
+func (this *Leaderboard[K]) rankOf(score float64, position int) int {
+	switch this.policy {
+	case Ordinal:
+		return position
+	case StandardCompetition:
+		// 比该分数高的成员数量加一
+		return this.ss.Length() - this.countLessOrEqual(score) + 1
+	case Dense:
+		// 比该分数高的不同分数的数量加一
+		return this.scores.GetRevRank(score)
+	default:
+		panic("unsupported rank policy")
+	}
+}
+
+// 分数小于等于score的成员数量
+func (this *Leaderboard[K]) countLessOrEqual(score float64) int {
+	return this.ss.CountByScore(math.Inf(-1), false, score, false)
+}
+
+// 获取成员的条目(成员不存在时返回的条目排名为0)
+func (this *Leaderboard[K]) Get(key K) Entry[K] {
+	position := this.ss.GetRevRank(key)
+	if position == 0 {
+		return Entry[K]{Key: key}
+	}
+	return this.entries(position, 1)[0]
+}
+
+// 获取成员的排名(成员不存在时返回0)
+func (this *Leaderboard[K]) Rank(key K) int {
+	data := this.ss.Get(key)
+	if data == nil {
+		return 0
+	}
+	return this.rankOf(data.Score, this.ss.GetRevRank(key))
+}
+
+// 前n名
+func (this *Leaderboard[K]) Top(n int) []Entry[K] {
+	return this.entries(1, n)
+}
+
+// 分页获取(page从1开始)
+func (this *Leaderboard[K]) Page(page int, pageSize int) []Entry[K] {
+	assert.Assert(page > 0 && pageSize > 0, "page和pageSize必须是正数, page:", page, " pageSize:", pageSize)
+	return this.entries((page-1)*pageSize+1, pageSize)
+}
+
+// 总页数
+func (this *Leaderboard[K]) PageCount(pageSize int) int {
+	assert.Assert(pageSize > 0, "pageSize必须是正数:", pageSize)
+	return (this.Length() + pageSize - 1) / pageSize
+}
+
+// 成员附近的条目:排在成员前面的above个,成员自己,排在成员后面的below个
+// 成员不存在时返回空
+func (this *Leaderboard[K]) Around(key K, above int, below int) []Entry[K] {
+	assert.Assert(above >= 0 && below >= 0, "above和below不能是负数")
+	position := this.ss.GetRevRank(key)
+	if position == 0 {
+		return []Entry[K]{}
+	}
+	start := position - above
+	if start < 1 {
+		start = 1
+	}
+	return this.entries(start, position+below-start+1)
+}
@@ -0,0 +1,195 @@
+// Package leaderboard.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stormYuanYang/yytools/algorithm/math_tools/random"
+	"github.com/stormYuanYang/yytools/common/assert"
+	"github.com/stormYuanYang/yytools/datastructure/sorted_set"
+	"math"
+	"sort"
+	"time"
+)
+
+// 暴力计算的排行榜(用来验证排行榜的实现)
+type naiveEntry struct {
+	key   int64
+	score float64
+	seq   int // 达到分数的先后顺序
+}
+
+func naiveSorted(m map[int64]*naiveEntry, tieBreak TieBreak) []*naiveEntry {
+	list := make([]*naiveEntry, 0, len(m))
+	for _, one := range m {
+		list = append(list, one)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].score != list[j].score {
+			return list[i].score > list[j].score
+		}
+		if tieBreak == EarliestFirst {
+			return list[i].seq < list[j].seq
+		}
+		return list[i].seq > list[j].seq
+	})
+	return list
+}
+
+// 按照排名策略计算每个位置的排名
+func naiveRanks(list []*naiveEntry, policy RankPolicy) []int {
+	ranks := make([]int, len(list))
+	for i := range list {
+		switch {
+		case i == 0:
+			ranks[i] = 1
+		case policy == Ordinal:
+			ranks[i] = i + 1
+		case list[i].score == list[i-1].score:
+			ranks[i] = ranks[i-1]
+		case policy == StandardCompetition:
+			ranks[i] = i + 1
+		default:
+			ranks[i] = ranks[i-1] + 1
+		}
+	}
+	return ranks
+}
+
+func leaderboardMustMatch(lb *Leaderboard[int64], naive map[int64]*naiveEntry) {
+	list := naiveSorted(naive, lb.tieBreak)
+	ranks := naiveRanks(list, lb.policy)
+	assert.Assert(lb.Length() == len(list), "长度不一致:", lb.Length(), " ", len(list))
+	
+	pageSize := random.RandInt(1, 20)
+	for page := 1; page <= lb.PageCount(pageSize); page++ {
+		for i, entry := range lb.Page(page, pageSize) {
+			position := (page-1)*pageSize + i
+			assert.Assert(entry.Key == list[position].key && entry.Score == list[position].score,
+				"排行榜顺序不正确, position:", position+1, " key:", entry.Key, " ", list[position].key)
+			assert.Assert(entry.Rank == ranks[position], "排名不正确, key:", entry.Key, " rank:", entry.Rank, " ", ranks[position])
+			assert.Assert(lb.Rank(entry.Key) == entry.Rank, "排名不一致, key:", entry.Key)
+		}
+	}
+	
+	if len(list) > 0 {
+		position := random.RandInt(0, len(list)-1)
+		above := random.RandInt(0, 5)
+		below := random.RandInt(0, 5)
+		around := lb.Around(list[position].key, above, below)
+		start := max(position-above, 0)
+		end := min(position+below, len(list)-1)
+		assert.Assert(len(around) == end-start+1, "附近的成员数量不正确:", len(around), " ", end-start+1)
+		for i, entry := range around {
+			assert.Assert(entry.Key == list[start+i].key && entry.Rank == ranks[start+i], "附近的成员不正确:", entry.Key)
+		}
+	}
+}
+
+func LeaderboardOpTest(n int, opCnt int) {
+	policy := RankPolicy(random.RandInt(int(Ordinal), int(Dense)))
+	tieBreak := TieBreak(random.RandInt(int(EarliestFirst), int(LatestFirst)))
+	lb := NewLeaderboard[int64](policy, tieBreak)
+	// 阈值很小，成员数量变化时会频繁地转换编码(阈值为0时不使用紧凑编码)
+	lb.SetCompactMaxEntries(random.RandInt(0, 16))
+	// 固定的时钟,分数相同时只能依靠序号区分先后
+	now := time.Unix(1700000000, 0)
+	lb.SetClock(func() time.Time {
+		return now
+	})
+	naive := map[int64]*naiveEntry{}
+	seq := 0
+	
+	for i := 0; i < opCnt; i++ {
+		key := int64(random.RandInt(1, n))
+		switch random.RandInt(0, 9) {
+		case 0:
+			ok := lb.Remove(key)
+			_, has := naive[key]
+			assert.Assert(ok == has, "删除结果不正确:", key)
+			delete(naive, key)
+		case 1, 2, 3:
+			delta := float64(random.RandInt(0, 10) - 5)
+			entry, err := lb.IncrScore(key, delta)
+			assert.Assert(err == nil, "增加分数不会失败:", err)
+			old, has := naive[key]
+			if !has {
+				seq++
+				naive[key] = &naiveEntry{key: key, score: delta, seq: seq}
+			} else if delta != 0 {
+				seq++
+				old.score += delta
+				old.seq = seq
+			}
+			assert.Assert(entry.Score == naive[key].score, "分数不正确:", entry.Score)
+		default:
+			score := float64(random.RandInt(0, 20))
+			entry := lb.SetScore(key, score)
+			old, has := naive[key]
+			if !has || old.score != score {
+				seq++
+				naive[key] = &naiveEntry{key: key, score: score, seq: seq}
+			}
+			assert.Assert(entry.Score == score && entry.Key == key, "分数不正确:", entry.Score)
+		}
+		if random.RandInt(0, opCnt/10) == 0 {
+			leaderboardMustMatch(lb, naive)
+		}
+		// 时钟偶尔前进
+		if random.RandInt(0, 3) == 0 {
+			now = now.Add(time.Second)
+		}
+	}
+	leaderboardMustMatch(lb, naive)
+}
+
+// 紧凑编码的小排行榜:标准竞赛排名按分数统计，以及分数不是数字时返回错误
+func LeaderboardCompactTest() {
+	lb := NewLeaderboard[int64](StandardCompetition, EarliestFirst)
+	lb.SetCompactMaxEntries(16)
+	for key, score := range []float64{30, 20, 20, 10} {
+		lb.SetScore(int64(key), score)
+	}
+	assert.Assert(lb.ss.Encoding() == sorted_set.EncodingCompact, "成员数量没有超过阈值时是紧凑编码")
+	for key, rank := range []int{1, 2, 2, 4} {
+		assert.Assert(lb.Rank(int64(key)) == rank, "排名不正确, key:", key, " rank:", lb.Rank(int64(key)))
+	}
+	
+	lb.SetScore(4, math.Inf(1))
+	_, err := lb.IncrScore(4, math.Inf(-1))
+	assert.Assert(errors.Is(err, ErrScoreNaN), "分数不是数字时返回错误:", err)
+	assert.Assert(lb.Get(4).Score == math.Inf(1) && lb.Rank(4) == 1, "失败时分数保持不变")
+}
+
+func LeaderboardTest(total int) {
+	println("排行榜测试开始...")
+	random.RandSeed(time.Now().UnixMilli())
+	nums := []int{1, 2, 3, 5, 10, 100, 1000}
+	for a := 1; a <= total; a++ {
+		fmt.Printf("-------第%d轮测试开始-------\n", a)
+		for k, n := range nums {
+			LeaderboardOpTest(n, 10000)
+			fmt.Printf("测试#%d结束. 成员数量上限:%d\n", k+1, n)
+		}
+		LeaderboardCompactTest()
+		fmt.Printf("紧凑编码测试结束\n")
+		fmt.Printf("-------第%d轮测试结束-------\n\n", a)
+	}
+	println("排行榜测试结束...")
+}
@@ -0,0 +1,220 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 按顺序构建跳跃表
+// 数据已经有序时，每个结点都只需要追加到表尾，不需要从头查找插入位置
+// 构建n个结点的时间复杂度为O(n)
+// 批量构建(BulkLoad)时结点的高度由排名决定(不随机)，得到的跳跃表是完全平衡的
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stormYuanYang/yytools/common/assert"
+	"math"
+	"slices"
+)
+
+var (
+	ErrBulkLoadNotEmpty = errors.New("批量构建:只能基于空的有序集合(跳跃表)构建")
+	ErrBulkLoadOrder    = errors.New("批量构建:数据不是严格有序的")
+	ErrBulkLoadKey      = errors.New("批量构建:key重复")
+)
+
+type skipListBuilder[K comparable, V any] struct {
+	sl       *SkipList[K, V]
+	last     [SKIPLIST_MAXLEVEL]*Node[K, V] // 每一高度的最后一个结点
+	lastRank [SKIPLIST_MAXLEVEL]int         // 每一高度最后一个结点的排名
+}
+
+// 只能基于空的跳跃表构建
+func newSkipListBuilder[K comparable, V any](sl *SkipList[K, V]) *skipListBuilder[K, V] {
+	assert.Assert(sl.Length == 0, "只能基于空的跳跃表构建, length:", sl.Length)
+	return &skipListBuilder[K, V]{
+		sl: sl,
+	}
+}
+
+// 追加结点到表尾(结点的高度是随机的)
+// 需要由调用者保证数据比表尾的数据大
+func (this *skipListBuilder[K, V]) append(data *NodeData[K, V]) *Node[K, V] {
+	return this.appendLevel(data, randomLevel(this.sl.LevelUpProb))
+}
+
+// 追加结点到表尾(结点的高度由排名决定)
+func (this *skipListBuilder[K, V]) appendBalanced(data *NodeData[K, V]) *Node[K, V] {
+	return this.appendLevel(data, balancedLevel(this.sl.Length+1, this.sl.LevelUpProb))
+}
+
+func (this *skipListBuilder[K, V]) appendLevel(data *NodeData[K, V], level int) *Node[K, V] {
+	assert.Assert(data != nil, "data must not be nil")
+	assert.Assert(!math.IsNaN(data.Score), "score is not a number:", data.Score)
+	assert.Assert(level >= 1 && level <= SKIPLIST_MAXLEVEL, "level不正确:", level)
+	sl := this.sl
+	assert.Assert(sl.Tail == nil || sl.dataLessThan(sl.Tail.Data, data), "数据必须是严格递增的")
+	
+	if level > sl.Level {
+		for i := sl.Level; i < level; i++ {
+			// 更高的高度，前置结点就是头结点
+			this.last[i] = sl.Head
+			this.lastRank[i] = 0
+		}
+		sl.Level = level
+	}
+	
+	rank := sl.Length + 1
+	node := CreateNode(level, data)
+	for i := 0; i < level; i++ {
+		// 每一高度的最后一个结点指向新结点,跨度就是两者排名之差
+		this.last[i].Levels[i].Forward = node
+		this.last[i].Levels[i].Span = rank - this.lastRank[i]
+		this.last[i] = node
+		this.lastRank[i] = rank
+	}
+	// 最下层是双向链表(backward不指向头结点)
+	node.Backward = sl.Tail
+	sl.Tail = node
+	sl.Length++
+	return node
+}
+
+// 构建结束，修正每一高度最后一个结点的跨度
+func (this *skipListBuilder[K, V]) finish() *SkipList[K, V] {
+	sl := this.sl
+	for i := 0; i < sl.Level; i++ {
+		// 最后一个结点到nil的跨度等于跳跃表长度减去其排名
+		this.last[i].Levels[i].Span = sl.Length - this.lastRank[i]
+	}
+	if sl.SumEnabled {
+		sl.rebuildSums()
+	}
+	return sl
+}
+
+// 根据排名计算结点的高度(按完全平衡的跳跃表)
+// 每1/p个结点中有一个结点的高度加1:p为0.25时，排名是4的倍数的结点高度至少是2，16的倍数至少是3，依此类推
+func balancedLevel(rank int, levelUpProb float32) int {
+	level := 1
+	if levelUpProb <= 0 {
+		return level
+	}
+	// 分支因子(1/p取整，至少是2)
+	factor := max(int(math.Round(float64(1/levelUpProb))), 2)
+	for rank%factor == 0 && level < SKIPLIST_MAXLEVEL {
+		rank /= factor
+		level++
+	}
+	return level
+}
+
+// 检查数据是否可以批量构建:不能为nil，分数不能是NaN，必须严格递增
+func (this *SkipList[K, V]) checkBulkLoad(datas []*NodeData[K, V]) error {
+	for i, data := range datas {
+		if data == nil {
+			return fmt.Errorf("批量构建:数据不能为nil, index:%d", i)
+		}
+		if math.IsNaN(data.Score) {
+			return fmt.Errorf("批量构建:分数不是数字, index:%d", i)
+		}
+		if i > 0 && !this.dataLessThan(datas[i-1], data) {
+			return fmt.Errorf("%w: index:%d", ErrBulkLoadOrder, i)
+		}
+	}
+	return nil
+}
+
+// 通过已经有序的数据批量构建跳跃表(跳跃表必须是空的)
+// 数据必须按(分数, 卫星数据)严格递增，否则返回错误，跳跃表保持不变
+// 结点的高度由排名决定，构建出的跳跃表是完全平衡的；时间复杂度O(n)
+func (this *SkipList[K, V]) BulkLoad(datas []*NodeData[K, V]) error {
+	if this.Length != 0 {
+		return ErrBulkLoadNotEmpty
+	}
+	if err := this.checkBulkLoad(datas); err != nil {
+		return err
+	}
+	builder := newSkipListBuilder(this)
+	for _, data := range datas {
+		builder.appendBalanced(data)
+	}
+	builder.finish()
+	return nil
+}
+
+// 通过若干数据批量构建有序集合(有序集合必须是空的)
+// sorted为true时，数据必须已经按(分数, 卫星数据)严格递增；否则先排序一次(会修改datas中元素的顺序)
+// key重复、数据不是严格递增等情况返回错误，有序集合保持不变
+// 和重新加载快照一样，不会触发钩子；设置了操作日志时，每个元素都记录为一次插入
+// 时间复杂度:有序时O(n)，否则O(nlogn)，都避免了逐个插入时的查找开销和随机高度
+func (this *SortedSet[K, V]) BulkLoad(datas []*NodeData[K, V], sorted bool) error {
+	this.expireDue()
+	if this.length() != 0 {
+		return ErrBulkLoadNotEmpty
+	}
+	cmp, prob, sumEnabled := this.params()
+	sl := NewSkipListByParams[K, V](cmp, prob)
+	sl.SumEnabled = sumEnabled
+	if !sorted {
+		if slices.Contains(datas, nil) {
+			return errors.New("批量构建:数据不能为nil")
+		}
+		slices.SortFunc(datas, sl.CompareData)
+	}
+	if err := sl.checkBulkLoad(datas); err != nil {
+		return err
+	}
+	hashMap := make(map[K]*NodeData[K, V], len(datas))
+	for _, data := range datas {
+		if _, has := hashMap[data.Key]; has {
+			return fmt.Errorf("%w: %v", ErrBulkLoadKey, data.Key)
+		}
+		hashMap[data.Key] = data
+	}
+	
+	builder := newSkipListBuilder(sl)
+	for _, data := range datas {
+		builder.appendBalanced(data)
+		if this.journal != nil {
+			this.journal.appendInsert(data)
+		}
+	}
+	this.Sl = builder.finish()
+	this.Hash = hashMap
+	this.compact = nil
+	// 新的跳跃表和哈希表不会被视图共享(视图继续使用原来的跳跃表和哈希表或者数组)
+	this.lengthMustEqual()
+	this.fitEncoding()
+	return nil
+}
+
+// 通过若干数据构建有序集合(sorted的含义和BulkLoad相同)
+// 新的有序集合使用和like相同的参数:比较器、提升结点高度的概率、分数和的维护、紧凑编码的阈值
+// 调用者需要保证key不重复；(分数, 卫星数据)相同的元素返回ErrBulkLoadOrder
+func newSortedSetFromDatas[K comparable, V any](like *SortedSet[K, V], datas []*NodeData[K, V], sorted bool) (*SortedSet[K, V], error) {
+	cmp, prob, sumEnabled := like.params()
+	ss := &SortedSet[K, V]{
+		Sl:   NewSkipListByParams[K, V](cmp, prob),
+		Hash: map[K]*NodeData[K, V]{},
+	}
+	ss.Sl.SumEnabled = sumEnabled
+	ss.compactMax = like.compactMax
+	if err := ss.BulkLoad(datas, sorted); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
@@ -0,0 +1,73 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 编解码器
+// 序列化有序集合时，通过编解码器将key和卫星数据转换为字节数组
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+var ErrCodecDataLength = errors.New("编解码:数据长度不正确")
+
+// int64的编解码器(固定8字节,小端序)
+type Int64Codec struct{}
+
+func (Int64Codec) Encode(v int64) ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(nil, uint64(v)), nil
+}
+
+func (Int64Codec) Decode(data []byte) (int64, error) {
+	if len(data) != 8 {
+		return 0, ErrCodecDataLength
+	}
+	return int64(binary.LittleEndian.Uint64(data)), nil
+}
+
+// 字符串的编解码器
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (StringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// json编解码器
+// 适用于卫星数据是普通结构体的情况(效率不高，但使用方便)
+type JsonCodec[T any] struct{}
+
+func (JsonCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JsonCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
@@ -37,10 +37,10 @@ func randomLevel(levelUpProbability float32) int {
 	// 而且，得到的阈值一定是在[0,RAND_MAX)范围内的
 	threshold := int32(levelUpProbability * RAND_MAX)
 	// 满足两个条件就可以提升等级:
-	// 1.等级小于等于指定最大等级 且
+	// 1.等级小于指定最大等级(提升之后不会超过最大等级) 且
 	// 2.满足指定概率
 	// 否则退出循环
-	for random() < threshold && level <= SKIPLIST_MAXLEVEL {
+	for level < SKIPLIST_MAXLEVEL && random() < threshold {
 		level++
 	}
 	return level
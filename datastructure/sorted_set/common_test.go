@@ -62,4 +62,12 @@ func Test_randomLevel(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// 提升概率接近1时，高度也不能超过最大高度
+func Test_randomLevelMaxLevel(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if got := randomLevel(0.9999); got < 1 || got > SKIPLIST_MAXLEVEL {
+			t.Fatalf("randomLevel() = %v, want [1, %v]", got, SKIPLIST_MAXLEVEL)
+		}
+	}
+}
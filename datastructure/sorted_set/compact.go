@@ -0,0 +1,481 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 有序集合的紧凑编码(参考redis的listpack编码和zset-max-listpack-entries)
+// 元素数量不超过阈值时，只用一个有序数组保存数据:没有哈希表，也没有跳跃表的头结点(32层)和每一层的索引
+// 按排名和分数的操作通过下标和二分查找完成，按key查找需要遍历数组(元素很少，遍历比哈希更快也更省内存)
+// 超过阈值时自动转换成跳跃表编码(通过批量构建，时间复杂度O(n))
+// 元素数量减少到阈值的一半以下时再转换回紧凑编码(留出余量，避免在阈值附近反复转换)
+// 通过SetCompactMaxEntries开启，过期时间、操作日志、钩子、视图、快照等功能在两种编码下都可以使用
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"iter"
+	"math"
+	"slices"
+	"sort"
+)
+
+// 有序集合的编码
+type Encoding int8
+
+const (
+	EncodingCompact  Encoding = iota // 0 紧凑编码(有序数组)
+	EncodingSkipList                 // 1 跳跃表
+)
+
+// 有序集合的底层结构(跳跃表或者紧凑编码的有序数组)提供的查询和范围删除操作
+// 插入、删除和更新分数需要同步哈希表、记录撤销日志，由有序集合分别处理
+type sortedList[K comparable, V any] interface {
+	GetRank(data *NodeData[K, V]) int
+	dataByRank(rank int) *NodeData[K, V]
+	GetRangeByRank(start int, end int) []*NodeData[K, V]
+	GetRevRangeByRank(start int, end int) []*NodeData[K, V]
+	GetRangeByScore(r *RangeSpecified) []*NodeData[K, V]
+	GetRevRangeByScore(r *RangeSpecified) []*NodeData[K, V]
+	GetRangeByScoreLimit(r *RangeSpecified, offset int, count int) []*NodeData[K, V]
+	GetRevRangeByScoreLimit(r *RangeSpecified, offset int, count int) []*NodeData[K, V]
+	CountByScore(r *RangeSpecified) int
+	rankRangeByScore(r *RangeSpecified) (int, int)
+	GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V]
+	CountByValue(r *ValueRangeSpecified[V]) int
+	rankRangeByValue(r *ValueRangeSpecified[V]) (int, int)
+	DeleteRangeByRank(start int, end int) []*NodeData[K, V]
+	DeleteRangeByScore(r *RangeSpecified) []*NodeData[K, V]
+	DeleteRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V]
+	SumByRank(start int, end int) float64
+	SumByScore(r *RangeSpecified) float64
+	All() iter.Seq2[int, *NodeData[K, V]]
+	Backward() iter.Seq2[int, *NodeData[K, V]]
+	IterRangeByRank(start int, end int) iter.Seq2[int, *NodeData[K, V]]
+	IterRangeByScore(r *RangeSpecified) iter.Seq2[int, *NodeData[K, V]]
+	walk(yield func(int, *NodeData[K, V]) bool)
+}
+
+var (
+	_ sortedList[int, int] = (*SkipList[int, int])(nil)
+	_ sortedList[int, int] = (*compactList[int, int])(nil)
+)
+
+// 紧凑编码
+type compactList[K comparable, V any] struct {
+	datas       []*NodeData[K, V]            // 按(分数, 卫星数据)排好序的数据
+	cmp         Comparator[V]                // 分数相同时，比较卫星数据的大小
+	levelUpProb float32                      // 转换成跳跃表时使用的提升结点高度的概率
+	sumEnabled  bool                         // 是否开启了分数和的统计(转换成跳跃表时维护分数和)
+	shared      bool                         // 数组被视图引用(修改之前需要先复制)
+	owned       map[*NodeData[K, V]]struct{} // 有视图时，创建视图之后复制或者插入的数据(可以原地修改，没有视图时为nil)
+}
+
+func newCompactList[K comparable, V any](cmp Comparator[V], levelUpProb float32, sumEnabled bool, datas []*NodeData[K, V]) *compactList[K, V] {
+	return &compactList[K, V]{
+		datas:       datas,
+		cmp:         cmp,
+		levelUpProb: levelUpProb,
+		sumEnabled:  sumEnabled,
+	}
+}
+
+func (this *compactList[K, V]) compareData(a, b *NodeData[K, V]) int {
+	if a.Score < b.Score {
+		return -1
+	}
+	if a.Score > b.Score {
+		return 1
+	}
+	return this.cmp(a.Val, b.Val)
+}
+
+// key对应的下标(不存在时返回-1)
+func (this *compactList[K, V]) indexOf(key K) int {
+	for i, data := range this.datas {
+		if data.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// 插入数据(调用者保证key不重复)
+func (this *compactList[K, V]) insert(data *NodeData[K, V]) {
+	assert.Assert(!math.IsNaN(data.Score), "score is not a number:", data.Score)
+	i, found := slices.BinarySearchFunc(this.datas, data, this.compareData)
+	assert.Assert(!found, "insert must success, data.Key:", data.Key)
+	this.datas = slices.Insert(this.datas, i, data)
+	if this.owned != nil {
+		this.owned[data] = struct{}{}
+	}
+}
+
+// 删除指定下标的数据
+func (this *compactList[K, V]) remove(i int) *NodeData[K, V] {
+	data := this.datas[i]
+	this.datas = slices.Delete(this.datas, i, i+1)
+	return data
+}
+
+// 更新分数:先从数组中删除，修改分数后再插入
+func (this *compactList[K, V]) updateScore(data *NodeData[K, V], newScore float64) {
+	assert.Assert(!math.IsNaN(newScore), "score is not a number:", newScore)
+	i, found := slices.BinarySearchFunc(this.datas, data, this.compareData)
+	assert.Assert(found, "data must exist, key:", data.Key)
+	this.datas = slices.Delete(this.datas, i, i+1)
+	data.Score = newScore
+	i, found = slices.BinarySearchFunc(this.datas, data, this.compareData)
+	assert.Assert(!found, "insert must success, key:", data.Key)
+	this.datas = slices.Insert(this.datas, i, data)
+}
+
+/*
+	和跳跃表相同的查询操作
+*/
+
+func (this *compactList[K, V]) GetRank(data *NodeData[K, V]) int {
+	i, found := slices.BinarySearchFunc(this.datas, data, this.compareData)
+	if !found {
+		return 0
+	}
+	return i + 1
+}
+
+func (this *compactList[K, V]) dataByRank(rank int) *NodeData[K, V] {
+	if rank <= 0 || rank > len(this.datas) {
+		return nil
+	}
+	return this.datas[rank-1]
+}
+
+func (this *compactList[K, V]) GetRangeByRank(start int, end int) []*NodeData[K, V] {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	if start > len(this.datas) {
+		return []*NodeData[K, V]{}
+	}
+	return slices.Clone(this.datas[start-1 : min(end, len(this.datas))])
+}
+
+func (this *compactList[K, V]) GetRevRangeByRank(start int, end int) []*NodeData[K, V] {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	length := len(this.datas)
+	if start > length {
+		return []*NodeData[K, V]{}
+	}
+	end = min(end, length)
+	// 逆序排名为start的数据，其下标就是length-start
+	datas := slices.Clone(this.datas[length-end : length-start+1])
+	slices.Reverse(datas)
+	return datas
+}
+
+// 分数范围内首尾数据的排名(范围内没有数据时返回(0, 0))
+func (this *compactList[K, V]) rankRangeByScore(r *RangeSpecified) (int, int) {
+	first := sort.Search(len(this.datas), func(i int) bool {
+		return scoreGeaterThanMin(this.datas[i].Score, r)
+	})
+	last := sort.Search(len(this.datas), func(i int) bool {
+		return !scoreLessThanMax(this.datas[i].Score, r)
+	})
+	if first >= last {
+		return 0, 0
+	}
+	return first + 1, last
+}
+
+func (this *compactList[K, V]) GetRangeByScore(r *RangeSpecified) []*NodeData[K, V] {
+	return this.GetRangeByScoreLimit(r, 0, -1)
+}
+
+func (this *compactList[K, V]) GetRevRangeByScore(r *RangeSpecified) []*NodeData[K, V] {
+	return this.GetRevRangeByScoreLimit(r, 0, -1)
+}
+
+func (this *compactList[K, V]) GetRangeByScoreLimit(r *RangeSpecified, offset int, count int) []*NodeData[K, V] {
+	assert.Assert(offset >= 0, "offset must not be negative:", offset)
+	start, end := this.rankRangeByScore(r)
+	start += offset
+	if start == offset || start > end || count == 0 {
+		// 范围内没有数据，或者跳过了所有数据
+		return []*NodeData[K, V]{}
+	}
+	if count > 0 {
+		end = min(end, start+count-1)
+	}
+	return this.GetRangeByRank(start, end)
+}
+
+func (this *compactList[K, V]) GetRevRangeByScoreLimit(r *RangeSpecified, offset int, count int) []*NodeData[K, V] {
+	assert.Assert(offset >= 0, "offset must not be negative:", offset)
+	first, last := this.rankRangeByScore(r)
+	if first == 0 || count == 0 {
+		return []*NodeData[K, V]{}
+	}
+	// 转换成逆序排名
+	length := len(this.datas)
+	start := length - last + 1 + offset
+	end := length - first + 1
+	if start > end {
+		return []*NodeData[K, V]{}
+	}
+	if count > 0 {
+		end = min(end, start+count-1)
+	}
+	return this.GetRevRangeByRank(start, end)
+}
+
+func (this *compactList[K, V]) CountByScore(r *RangeSpecified) int {
+	start, end := this.rankRangeByScore(r)
+	if start == 0 {
+		return 0
+	}
+	return end - start + 1
+}
+
+// 值范围内首尾数据的排名(范围内没有数据时返回(0, 0))
+func (this *compactList[K, V]) rankRangeByValue(r *ValueRangeSpecified[V]) (int, int) {
+	first := sort.Search(len(this.datas), func(i int) bool {
+		return valueGeaterThanMin(this.cmp, this.datas[i].Val, r)
+	})
+	last := sort.Search(len(this.datas), func(i int) bool {
+		return !valueLessThanMax(this.cmp, this.datas[i].Val, r)
+	})
+	if first >= last {
+		return 0, 0
+	}
+	return first + 1, last
+}
+
+func (this *compactList[K, V]) GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	start, end := this.rankRangeByValue(r)
+	if start == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.GetRangeByRank(start, end)
+}
+
+func (this *compactList[K, V]) CountByValue(r *ValueRangeSpecified[V]) int {
+	start, end := this.rankRangeByValue(r)
+	if start == 0 {
+		return 0
+	}
+	return end - start + 1
+}
+
+/*
+	范围删除
+*/
+
+// 删除排名范围内的数据(排名超出范围的部分忽略)
+func (this *compactList[K, V]) DeleteRangeByRank(start int, end int) []*NodeData[K, V] {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	if start > len(this.datas) {
+		return []*NodeData[K, V]{}
+	}
+	end = min(end, len(this.datas))
+	deleted := slices.Clone(this.datas[start-1 : end])
+	this.datas = slices.Delete(this.datas, start-1, end)
+	return deleted
+}
+
+func (this *compactList[K, V]) DeleteRangeByScore(r *RangeSpecified) []*NodeData[K, V] {
+	start, end := this.rankRangeByScore(r)
+	if start == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.DeleteRangeByRank(start, end)
+}
+
+func (this *compactList[K, V]) DeleteRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	start, end := this.rankRangeByValue(r)
+	if start == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.DeleteRangeByRank(start, end)
+}
+
+/*
+	分数统计
+	元素数量不超过阈值，直接遍历求和
+*/
+
+func (this *compactList[K, V]) SumByRank(start int, end int) float64 {
+	assert.Assert(this.sumEnabled, "需要先开启分数和的维护(EnableSum)")
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	sum := 0.0
+	for _, data := range this.datas[min(start-1, len(this.datas)):min(end, len(this.datas))] {
+		sum += data.Score
+	}
+	return sum
+}
+
+func (this *compactList[K, V]) SumByScore(r *RangeSpecified) float64 {
+	assert.Assert(r != nil, "r range cannot be nil")
+	start, end := this.rankRangeByScore(r)
+	if start == 0 {
+		return 0
+	}
+	return this.SumByRank(start, end)
+}
+
+/*
+	遍历
+*/
+
+func (this *compactList[K, V]) walk(yield func(int, *NodeData[K, V]) bool) {
+	for i, data := range this.datas {
+		if !yield(i+1, data) {
+			return
+		}
+	}
+}
+
+func (this *compactList[K, V]) All() iter.Seq2[int, *NodeData[K, V]] {
+	return this.walk
+}
+
+func (this *compactList[K, V]) Backward() iter.Seq2[int, *NodeData[K, V]] {
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		for i := len(this.datas) - 1; i >= 0; i-- {
+			if !yield(i+1, this.datas[i]) {
+				return
+			}
+		}
+	}
+}
+
+func (this *compactList[K, V]) IterRangeByRank(start int, end int) iter.Seq2[int, *NodeData[K, V]] {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		for rank := start; rank <= min(end, len(this.datas)); rank++ {
+			if !yield(rank, this.datas[rank-1]) {
+				return
+			}
+		}
+	}
+}
+
+func (this *compactList[K, V]) IterRangeByScore(r *RangeSpecified) iter.Seq2[int, *NodeData[K, V]] {
+	assert.Assert(r != nil, "r range cannot be nil")
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		start, end := this.rankRangeByScore(r)
+		if start == 0 {
+			return
+		}
+		for rank := start; rank <= end; rank++ {
+			if !yield(rank, this.datas[rank-1]) {
+				return
+			}
+		}
+	}
+}
+
+/*
+	编码的转换
+*/
+
+// 设置紧凑编码的最大元素数量(为0时不使用紧凑编码，默认为0)
+// 推荐使用DEFAULT_COMPACT_MAX_ENTRIES；设置之后立即按元素数量转换编码
+func (this *SortedSet[K, V]) SetCompactMaxEntries(maxEntries int) {
+	assert.Assert(maxEntries >= 0, "maxEntries must not be negative:", maxEntries)
+	this.expireDue()
+	this.compactMax = maxEntries
+	this.fitEncoding()
+}
+
+// 当前的编码
+func (this *SortedSet[K, V]) Encoding() Encoding {
+	if this.compact != nil {
+		return EncodingCompact
+	}
+	return EncodingSkipList
+}
+
+// 当前编码的底层结构
+func (this *SortedSet[K, V]) list() sortedList[K, V] {
+	if this.compact != nil {
+		return this.compact
+	}
+	return this.Sl
+}
+
+// 当前编码的参数:卫星数据的比较函数、提升结点高度的概率、是否开启了分数和的维护
+func (this *SortedSet[K, V]) params() (Comparator[V], float32, bool) {
+	if c := this.compact; c != nil {
+		return c.cmp, c.levelUpProb, c.sumEnabled
+	}
+	return this.Sl.Cmp, this.Sl.LevelUpProb, this.Sl.SumEnabled
+}
+
+// 修改之后根据元素数量转换编码
+func (this *SortedSet[K, V]) convertIfNeeded() {
+	if this.compact != nil {
+		if len(this.compact.datas) > this.compactMax {
+			this.promote()
+		}
+	} else if this.compactMax > 0 && this.Sl.Length <= this.compactMax/2 {
+		this.demote()
+	}
+}
+
+// 重新构建之后(批量构建、加载快照、修改阈值)，元素数量不超过阈值就使用紧凑编码
+func (this *SortedSet[K, V]) fitEncoding() {
+	if this.compact != nil {
+		if len(this.compact.datas) > this.compactMax {
+			this.promote()
+		}
+	} else if this.compactMax > 0 && this.Sl.Length <= this.compactMax {
+		this.demote()
+	}
+}
+
+// 转换成跳跃表
+// 数组中的数据可能被视图引用时，新的跳跃表继续区分数据的归属(更新分数时复制数据)
+func (this *SortedSet[K, V]) promote() {
+	c := this.compact
+	sl := NewSkipListByParams[K, V](c.cmp, c.levelUpProb)
+	sl.SumEnabled = c.sumEnabled
+	builder := newSkipListBuilder(sl)
+	hashMap := make(map[K]*NodeData[K, V], len(c.datas))
+	for _, data := range c.datas {
+		builder.appendBalanced(data)
+		hashMap[data.Key] = data
+	}
+	if c.owned != nil {
+		sl.undo = newUndoLog[K, V]()
+		sl.undo.owned = c.owned
+	}
+	this.Sl = builder.finish()
+	this.Hash = hashMap
+	this.compact = nil
+}
+
+// 转换成紧凑编码
+// 跳跃表中的数据可能被视图引用时，紧凑编码继续区分数据的归属
+func (this *SortedSet[K, V]) demote() {
+	sl := this.Sl
+	datas := make([]*NodeData[K, V], 0, max(this.compactMax, sl.Length))
+	sl.walk(func(_ int, data *NodeData[K, V]) bool {
+		datas = append(datas, data)
+		return true
+	})
+	this.compact = newCompactList(sl.Cmp, sl.LevelUpProb, sl.SumEnabled, datas)
+	if sl.undo != nil {
+		this.compact.owned = sl.undo.owned
+	}
+	this.Sl = nil
+	this.Hash = nil
+}
@@ -0,0 +1,308 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 复合分数(多维分数)的有序集合
+// 分数是由多个分量组成的元组，每个分量可以是int64、float64或者string，并且有各自的排序方向
+// 比如竞技场排行榜按(积分降序, 胜场降序, 达成时间升序)排序，不需要把多个维度压缩进一个float64(会损失精度)
+// 实现:所有元素的float64分数都是0，由比较器按元组(相同时按key)决定先后顺序，
+// 所以按元组前缀的范围查询就是按值的范围查询(所有元素的分数都相同，按值查找的结果有意义)
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"cmp"
+	"github.com/stormYuanYang/yytools/common/assert"
+	"iter"
+	"math"
+)
+
+// 分量的类型
+type FieldKind int8
+
+const (
+	FieldInt    FieldKind = iota // int64
+	FieldFloat                   // float64(不能是NaN)
+	FieldString                  // string(按字节序比较)
+)
+
+// 分量的排序方向
+type FieldOrder int8
+
+const (
+	Asc  FieldOrder = iota // 升序:值小的排在前面
+	Desc                   // 降序:值大的排在前面
+)
+
+// 复合分数中的一个分量的定义
+type ScoreField struct {
+	Name  string // 名字(只用于提示)
+	Kind  FieldKind
+	Order FieldOrder
+}
+
+// 复合分数的定义:各个分量的类型和排序方向，按顺序依次比较
+type ScoreSchema []ScoreField
+
+// 复合分数中的一个分量(只有Kind对应的字段有意义)
+type TupleValue struct {
+	Kind  FieldKind
+	Int   int64
+	Float float64
+	Str   string
+}
+
+func IntValue(v int64) TupleValue {
+	return TupleValue{Kind: FieldInt, Int: v}
+}
+
+func FloatValue(v float64) TupleValue {
+	return TupleValue{Kind: FieldFloat, Float: v}
+}
+
+func StringValue(v string) TupleValue {
+	return TupleValue{Kind: FieldString, Str: v}
+}
+
+// 复合分数(元组)
+type Tuple []TupleValue
+
+// 检查元组是否是复合分数的前缀(长度相同时就是完整的复合分数)
+func (this ScoreSchema) checkPrefix(t Tuple) {
+	assert.Assert(len(t) <= len(this), "元组的长度超过了复合分数的定义, len:", len(t), " schema:", len(this))
+	for i, v := range t {
+		assert.Assert(v.Kind == this[i].Kind, "分量的类型不正确, index:", i, " field:", this[i].Name)
+		assert.Assert(v.Kind != FieldFloat || !math.IsNaN(v.Float), "分量不能是NaN, field:", this[i].Name)
+	}
+}
+
+// 按复合分数的定义比较两个元组的前n个分量(n是两个元组中较短的长度)
+// 返回值已经考虑了各个分量的排序方向:小于0表示a排在b前面
+func (this ScoreSchema) ComparePrefix(a, b Tuple) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		var c int
+		switch this[i].Kind {
+		case FieldInt:
+			c = cmp.Compare(a[i].Int, b[i].Int)
+		case FieldFloat:
+			c = cmp.Compare(a[i].Float, b[i].Float)
+		case FieldString:
+			c = cmp.Compare(a[i].Str, b[i].Str)
+		}
+		if c != 0 {
+			if this[i].Order == Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+// 复合分数有序集合中元素的数据(作为底层有序集合的卫星数据)
+// 调用者不能修改
+type CompositeData[K comparable, V any] struct {
+	Key   K
+	Score Tuple
+	Val   V
+	bound bool // true:只是范围查询的边界(比较时只比较元组的前缀)
+}
+
+type CompositeSortedSet[K comparable, V any] struct {
+	Schema ScoreSchema
+	Ss     *SortedSet[K, *CompositeData[K, V]] // 所有元素的分数都是0
+}
+
+// keyCmp用于复合分数完全相同时比较key，决定元素的先后顺序(比如按玩家id)
+func NewCompositeSortedSet[K comparable, V any](schema ScoreSchema, keyCmp Comparator[K]) *CompositeSortedSet[K, V] {
+	assert.Assert(len(schema) > 0, "复合分数至少需要一个分量")
+	assert.Assert(keyCmp != nil, "比较器不能为nil")
+	this := &CompositeSortedSet[K, V]{
+		Schema: schema,
+	}
+	this.Ss = NewSortedSet[K, *CompositeData[K, V]](func(a, b *CompositeData[K, V]) int {
+		c := schema.ComparePrefix(a.Score, b.Score)
+		if c == 0 && a.bound && b.bound && len(a.Score) != len(b.Score) {
+			// 两个边界的长度不同时(比如下界是(3)，上界是(3, 0.5))，范围不一定是空的，不能认为相等
+			// 跳跃表判断范围是否合法时会比较上下界，这里认为下界更小，由元素和边界的比较决定结果
+			return -1
+		}
+		if c != 0 || a.bound || b.bound {
+			// 和边界比较时，前缀相同就认为相等
+			return c
+		}
+		return keyCmp(a.Key, b.Key)
+	})
+	return this
+}
+
+/*
+	基本操作
+*/
+
+func (this *CompositeSortedSet[K, V]) Get(key K) *CompositeData[K, V] {
+	data := this.Ss.Get(key)
+	if data == nil {
+		return nil
+	}
+	return data.Val
+}
+
+func (this *CompositeSortedSet[K, V]) Length() int {
+	return this.Ss.Length()
+}
+
+// 插入(key已存在时失败)
+// score必须是完整的复合分数
+func (this *CompositeSortedSet[K, V]) Insert(key K, score Tuple, val V) bool {
+	assert.Assert(len(score) == len(this.Schema), "复合分数的长度不正确:", len(score))
+	this.Schema.checkPrefix(score)
+	data := &CompositeData[K, V]{
+		Key:   key,
+		Score: score,
+		Val:   val,
+	}
+	return this.Ss.Insert(NewNodeData(key, 0, data))
+}
+
+func (this *CompositeSortedSet[K, V]) Delete(key K) (*CompositeData[K, V], bool) {
+	data, ok := this.Ss.Delete(key)
+	if !ok {
+		return nil, false
+	}
+	return data.Val, true
+}
+
+// 更新复合分数(卫星数据不变)，返回更新后的数据
+// 底层的有序集合中是先删除再插入(注册的钩子收到的是删除和插入两次通知)
+func (this *CompositeSortedSet[K, V]) UpdateScore(key K, score Tuple) (*CompositeData[K, V], bool) {
+	old, ok := this.Delete(key)
+	if !ok {
+		return nil, false
+	}
+	ok = this.Insert(key, score, old.Val)
+	assert.Assert(ok, "insert must success, key:", key)
+	return this.Get(key), ok
+}
+
+/*
+	排名相关操作
+*/
+
+func (this *CompositeSortedSet[K, V]) GetRank(key K) int {
+	return this.Ss.GetRank(key)
+}
+
+func (this *CompositeSortedSet[K, V]) GetRevRank(key K) int {
+	return this.Ss.GetRevRank(key)
+}
+
+func (this *CompositeSortedSet[K, V]) GetByRank(rank int) *CompositeData[K, V] {
+	data := this.Ss.GetByRank(rank)
+	if data == nil {
+		return nil
+	}
+	return data.Val
+}
+
+func (this *CompositeSortedSet[K, V]) GetRangeByRank(start int, end int) []*CompositeData[K, V] {
+	return compositeDatas(this.Ss.GetRangeByRank(start, end))
+}
+
+func (this *CompositeSortedSet[K, V]) GetRevRangeByRank(start int, end int) []*CompositeData[K, V] {
+	return compositeDatas(this.Ss.GetRevRangeByRank(start, end))
+}
+
+func (this *CompositeSortedSet[K, V]) DeleteRangeByRank(start int, end int) []*CompositeData[K, V] {
+	return compositeDatas(this.Ss.DeleteRangeByRank(start, end))
+}
+
+func compositeDatas[K comparable, V any](datas []*NodeData[K, *CompositeData[K, V]]) []*CompositeData[K, V] {
+	result := make([]*CompositeData[K, V], 0, len(datas))
+	for _, data := range datas {
+		result = append(result, data.Val)
+	}
+	return result
+}
+
+/*
+	按复合分数的范围操作
+	边界是元组的前缀(可以只指定前几个分量)，元素的复合分数的前缀和边界比较
+	min和max是按排序后的先后顺序而言的:min是排在前面的边界(对于降序的分量，min的值反而更大)
+	边界为nil表示没有限制
+*/
+
+func (this *CompositeSortedSet[K, V]) scoreRange(min Tuple, minEx bool, max Tuple, maxEx bool) *ValueRangeSpecified[*CompositeData[K, V]] {
+	this.Schema.checkPrefix(min)
+	this.Schema.checkPrefix(max)
+	r := NewValueRange(&CompositeData[K, V]{Score: min, bound: true}, minEx,
+		&CompositeData[K, V]{Score: max, bound: true}, maxEx)
+	r.MinInf = min == nil
+	r.MaxInf = max == nil
+	return r
+}
+
+// 复合分数范围内的数据(按排序的先后顺序返回)
+// 比如按(积分降序, 胜场降序)排序时，GetRangeByScore(Tuple{IntValue(2000)}, false, Tuple{IntValue(1000)}, false)
+// 返回积分在[1000, 2000]内的所有元素
+func (this *CompositeSortedSet[K, V]) GetRangeByScore(min Tuple, minEx bool, max Tuple, maxEx bool) []*CompositeData[K, V] {
+	return compositeDatas(this.Ss.GetRangeByValue(this.scoreRange(min, minEx, max, maxEx)))
+}
+
+// 分页获取复合分数范围内的数据
+// 跳过前offset个数据，最多返回count个(count小于0表示不限制数量)
+func (this *CompositeSortedSet[K, V]) GetRangeByScoreLimit(min Tuple, minEx bool, max Tuple, maxEx bool, offset int, count int) []*CompositeData[K, V] {
+	assert.Assert(offset >= 0, "offset must not be negative:", offset)
+	r := this.scoreRange(min, minEx, max, maxEx)
+	this.Ss.expireDue()
+	start, end := this.Ss.list().rankRangeByValue(r)
+	start += offset
+	if start == offset || start > end || count == 0 {
+		// 范围内没有元素，或者跳过了所有元素
+		return []*CompositeData[K, V]{}
+	}
+	if count > 0 && start+count-1 < end {
+		end = start + count - 1
+	}
+	return this.GetRangeByRank(start, end)
+}
+
+// 复合分数范围内的数据数量
+// 时间复杂度O(logn)
+func (this *CompositeSortedSet[K, V]) CountByScore(min Tuple, minEx bool, max Tuple, maxEx bool) int {
+	return this.Ss.CountByValue(this.scoreRange(min, minEx, max, maxEx))
+}
+
+// 删除复合分数范围内的数据
+func (this *CompositeSortedSet[K, V]) DeleteRangeByScore(min Tuple, minEx bool, max Tuple, maxEx bool) []*CompositeData[K, V] {
+	return compositeDatas(this.Ss.DeleteRangeByValue(this.scoreRange(min, minEx, max, maxEx)))
+}
+
+/*
+	遍历
+*/
+
+// 按排序的先后顺序遍历所有数据
+func (this *CompositeSortedSet[K, V]) All() iter.Seq2[int, *CompositeData[K, V]] {
+	return func(yield func(int, *CompositeData[K, V]) bool) {
+		for rank, data := range this.Ss.All() {
+			if !yield(rank, data.Val) {
+				return
+			}
+		}
+	}
+}
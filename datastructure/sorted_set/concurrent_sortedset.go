@@ -0,0 +1,666 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 并发安全的有序集合
+// 通过读写锁保护内部的有序集合：读操作之间可以并发，写操作互斥
+// 返回的数据都是拷贝(卫星数据是浅拷贝)，调用者持有的数据不会被其他协程修改
+// 持有读锁时不能修改有序集合，所以过期的元素在加锁时统一删除(而不是在访问时惰性删除)
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"context"
+	"github.com/stormYuanYang/yytools/common/assert"
+	"io"
+	"sync"
+	"time"
+)
+
+type ConcurrentSortedSet[K comparable, V any] struct {
+	mu     sync.RWMutex
+	ss     *SortedSet[K, V]
+	waitCh chan struct{} // 阻塞等待元素的协程都在等待这个通道被关闭(没有等待者时为nil)
+}
+
+func NewConcurrentSortedSet[K comparable, V any](cmp Comparator[V]) *ConcurrentSortedSet[K, V] {
+	ss := NewSortedSet[K, V](cmp)
+	ss.manualExpire = true
+	return &ConcurrentSortedSet[K, V]{
+		ss: ss,
+	}
+}
+
+// 加写锁，并删除所有已经过期的元素
+func (this *ConcurrentSortedSet[K, V]) lock() {
+	this.mu.Lock()
+	this.ss.removeExpired(0)
+}
+
+// 加读锁
+// 有已经过期的元素时，先加写锁删除它们，再重新加读锁
+func (this *ConcurrentSortedSet[K, V]) rlock() {
+	for {
+		this.mu.RLock()
+		if !this.ss.hasExpired() {
+			return
+		}
+		this.mu.RUnlock()
+		this.lock()
+		this.mu.Unlock()
+	}
+}
+
+func copyData[K comparable, V any](data *NodeData[K, V]) *NodeData[K, V] {
+	if data == nil {
+		return nil
+	}
+	one := *data
+	return &one
+}
+
+func copyDatas[K comparable, V any](datas []*NodeData[K, V]) []*NodeData[K, V] {
+	for i, data := range datas {
+		datas[i] = copyData(data)
+	}
+	return datas
+}
+
+// 在读锁的保护下访问有序集合(可以组合多个读操作)
+// 不能在fn中修改有序集合，也不能将有序集合中的数据泄露到fn之外
+func (this *ConcurrentSortedSet[K, V]) View(fn func(ss *SortedSet[K, V])) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	fn(this.ss)
+}
+
+// 在写锁的保护下访问有序集合(可以原子地执行多个操作)
+// 不能将有序集合中的数据泄露到fn之外
+func (this *ConcurrentSortedSet[K, V]) Update(fn func(ss *SortedSet[K, V])) {
+	this.lock()
+	defer this.mu.Unlock()
+	fn(this.ss)
+	this.wakeUp()
+}
+
+// 唤醒所有阻塞等待元素的协程(需要持有写锁)
+func (this *ConcurrentSortedSet[K, V]) wakeUp() {
+	if this.waitCh != nil && this.ss.Length() > 0 {
+		close(this.waitCh)
+		this.waitCh = nil
+	}
+}
+
+/*
+	基本操作
+*/
+
+func (this *ConcurrentSortedSet[K, V]) Get(key K) *NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyData(this.ss.Get(key))
+}
+
+// 插入的数据由有序集合接管，调用者之后不能再修改它
+func (this *ConcurrentSortedSet[K, V]) Insert(data *NodeData[K, V]) bool {
+	this.lock()
+	defer this.mu.Unlock()
+	defer this.wakeUp()
+	return this.ss.Insert(data)
+}
+
+func (this *ConcurrentSortedSet[K, V]) Delete(key K) (*NodeData[K, V], bool) {
+	this.lock()
+	defer this.mu.Unlock()
+	// 被删除的数据已经不在有序集合中，不需要拷贝
+	return this.ss.Delete(key)
+}
+
+func (this *ConcurrentSortedSet[K, V]) Length() int {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.Length()
+}
+
+func (this *ConcurrentSortedSet[K, V]) Add(data *NodeData[K, V], flags AddFlag) bool {
+	this.lock()
+	defer this.mu.Unlock()
+	defer this.wakeUp()
+	return this.ss.Add(data, flags)
+}
+
+func (this *ConcurrentSortedSet[K, V]) IncrScore(key K, delta float64, val V) (float64, int, bool) {
+	this.lock()
+	defer this.mu.Unlock()
+	defer this.wakeUp()
+	return this.ss.IncrScore(key, delta, val)
+}
+
+func (this *ConcurrentSortedSet[K, V]) PopMin(count int) []*NodeData[K, V] {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.PopMin(count)
+}
+
+func (this *ConcurrentSortedSet[K, V]) PopMax(count int) []*NodeData[K, V] {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.PopMax(count)
+}
+
+// 阻塞地弹出元素:有序集合为空时等待，直到有元素可以弹出或者ctx结束
+func (this *ConcurrentSortedSet[K, V]) blockingPop(ctx context.Context, pop func() []*NodeData[K, V]) ([]*NodeData[K, V], error) {
+	for {
+		datas, ch := this.tryPop(pop)
+		if ch == nil {
+			return datas, nil
+		}
+		select {
+		case <-ch:
+			// 有新的元素，重新尝试弹出(可能被其他协程抢先弹出)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 尝试弹出元素，有序集合为空时返回等待新元素的通道
+// 通过defer释放锁，pop出错(panic)时也不会一直持有锁
+func (this *ConcurrentSortedSet[K, V]) tryPop(pop func() []*NodeData[K, V]) ([]*NodeData[K, V], chan struct{}) {
+	this.lock()
+	defer this.mu.Unlock()
+	if this.ss.Length() > 0 {
+		return pop(), nil
+	}
+	if this.waitCh == nil {
+		this.waitCh = make(chan struct{})
+	}
+	return nil, this.waitCh
+}
+
+// 阻塞版本的PopMin(参考redis的BZPOPMIN)
+// 可以通过ctx设置超时或者取消等待
+func (this *ConcurrentSortedSet[K, V]) BlockingPopMin(ctx context.Context, count int) ([]*NodeData[K, V], error) {
+	assert.Assert(count > 0, "count must be positive number")
+	return this.blockingPop(ctx, func() []*NodeData[K, V] {
+		return this.ss.PopMin(count)
+	})
+}
+
+// 阻塞版本的PopMax(参考redis的BZPOPMAX)
+// 可以通过ctx设置超时或者取消等待
+func (this *ConcurrentSortedSet[K, V]) BlockingPopMax(ctx context.Context, count int) ([]*NodeData[K, V], error) {
+	assert.Assert(count > 0, "count must be positive number")
+	return this.blockingPop(ctx, func() []*NodeData[K, V] {
+		return this.ss.PopMax(count)
+	})
+}
+
+/*
+	排名相关操作
+*/
+
+func (this *ConcurrentSortedSet[K, V]) GetRank(key K) int {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.GetRank(key)
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRevRank(key K) int {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.GetRevRank(key)
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetByRank(rank int) *NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyData(this.ss.GetByRank(rank))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetByRevRank(rank int) *NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyData(this.ss.GetByRevRank(rank))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.GetRangeByRank(start, end))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRevRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.GetRevRangeByRank(start, end))
+}
+
+func (this *ConcurrentSortedSet[K, V]) DeleteRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.DeleteRangeByRank(start, end)
+}
+
+/*
+	分数相关操作
+*/
+
+func (this *ConcurrentSortedSet[K, V]) UpdateScore(key K, newScore float64) (*NodeData[K, V], bool) {
+	this.lock()
+	defer this.mu.Unlock()
+	data, ok := this.ss.UpdateScore(key, newScore)
+	return copyData(data), ok
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.GetRangeByScore(min, minEx, max, maxEx))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRevRangeByScore(max float64, maxEx bool, min float64, minEx bool) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.GetRevRangeByScore(max, maxEx, min, minEx))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRangeByScoreLimit(min float64, minEx bool, max float64, maxEx bool, offset int, count int) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.GetRangeByScoreLimit(min, minEx, max, maxEx, offset, count))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRevRangeByScoreLimit(max float64, maxEx bool, min float64, minEx bool, offset int, count int) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.GetRevRangeByScoreLimit(max, maxEx, min, minEx, offset, count))
+}
+
+func (this *ConcurrentSortedSet[K, V]) CountByScore(min float64, minEx bool, max float64, maxEx bool) int {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.CountByScore(min, minEx, max, maxEx)
+}
+
+func (this *ConcurrentSortedSet[K, V]) DeleteRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V] {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.DeleteRangeByScore(min, minEx, max, maxEx)
+}
+
+/*
+	分数统计
+*/
+
+func (this *ConcurrentSortedSet[K, V]) EnableSum() {
+	this.lock()
+	defer this.mu.Unlock()
+	this.ss.EnableSum()
+}
+
+/*
+	编码
+*/
+
+func (this *ConcurrentSortedSet[K, V]) SetCompactMaxEntries(maxEntries int) {
+	this.lock()
+	defer this.mu.Unlock()
+	this.ss.SetCompactMaxEntries(maxEntries)
+}
+
+func (this *ConcurrentSortedSet[K, V]) Encoding() Encoding {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.Encoding()
+}
+
+func (this *ConcurrentSortedSet[K, V]) SumByRank(start int, end int) (float64, int) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.SumByRank(start, end)
+}
+
+func (this *ConcurrentSortedSet[K, V]) SumByScore(min float64, minEx bool, max float64, maxEx bool) (float64, int) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.SumByScore(min, minEx, max, maxEx)
+}
+
+func (this *ConcurrentSortedSet[K, V]) AvgByRank(start int, end int) (float64, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.AvgByRank(start, end)
+}
+
+func (this *ConcurrentSortedSet[K, V]) AvgByScore(min float64, minEx bool, max float64, maxEx bool) (float64, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.AvgByScore(min, minEx, max, maxEx)
+}
+
+func (this *ConcurrentSortedSet[K, V]) MinMaxByRank(start int, end int) (float64, float64, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.MinMaxByRank(start, end)
+}
+
+/*
+	百分位和分桶
+*/
+
+func (this *ConcurrentSortedSet[K, V]) GetByPercentile(p float64) *NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyData(this.ss.GetByPercentile(p))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetByRevPercentile(p float64) *NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyData(this.ss.GetByRevPercentile(p))
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetPercentile(key K) (float64, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.GetPercentile(key)
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetRevPercentile(key K) (float64, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.GetRevPercentile(key)
+}
+
+func (this *ConcurrentSortedSet[K, V]) ScoreQuantile(q float64) (float64, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.ScoreQuantile(q)
+}
+
+func (this *ConcurrentSortedSet[K, V]) Buckets(n int) []*Bucket[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	buckets := this.ss.Buckets(n)
+	for _, bucket := range buckets {
+		bucket.First = copyData(bucket.First)
+		bucket.Last = copyData(bucket.Last)
+	}
+	return buckets
+}
+
+func (this *ConcurrentSortedSet[K, V]) BucketOf(key K, n int) int {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.BucketOf(key, n)
+}
+
+/*
+	值相关操作
+*/
+
+func (this *ConcurrentSortedSet[K, V]) GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.GetRangeByValue(r))
+}
+
+func (this *ConcurrentSortedSet[K, V]) CountByValue(r *ValueRangeSpecified[V]) int {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.CountByValue(r)
+}
+
+func (this *ConcurrentSortedSet[K, V]) DeleteRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.DeleteRangeByValue(r)
+}
+
+/*
+	随机获取元素
+*/
+
+func (this *ConcurrentSortedSet[K, V]) RandMember(count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.RandMember(count, repeat, mode))
+}
+
+func (this *ConcurrentSortedSet[K, V]) RandMemberByRank(start int, end int, count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.RandMemberByRank(start, end, count, repeat, mode))
+}
+
+func (this *ConcurrentSortedSet[K, V]) RandMemberByScore(min float64, minEx bool, max float64, maxEx bool, count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return copyDatas(this.ss.RandMemberByScore(min, minEx, max, maxEx, count, repeat, mode))
+}
+
+/*
+	过期时间
+*/
+
+func (this *ConcurrentSortedSet[K, V]) SetClock(clock func() time.Time) {
+	this.lock()
+	defer this.mu.Unlock()
+	this.ss.SetClock(clock)
+}
+
+func (this *ConcurrentSortedSet[K, V]) ExpireAt(key K, at time.Time) bool {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.ExpireAt(key, at)
+}
+
+func (this *ConcurrentSortedSet[K, V]) Expire(key K, ttl time.Duration) bool {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.Expire(key, ttl)
+}
+
+func (this *ConcurrentSortedSet[K, V]) Persist(key K) bool {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.Persist(key)
+}
+
+func (this *ConcurrentSortedSet[K, V]) GetExpireAt(key K) (time.Time, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.GetExpireAt(key)
+}
+
+func (this *ConcurrentSortedSet[K, V]) TTL(key K) (time.Duration, bool) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.TTL(key)
+}
+
+// 主动删除最多limit个过期的元素(limit小于等于0表示不限制)
+func (this *ConcurrentSortedSet[K, V]) Sweep(limit int) int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.ss.Sweep(limit)
+}
+
+// 启动后台协程，每隔interval主动删除最多limit个过期的元素
+// 返回的函数用于停止后台协程(会等待协程退出)
+func (this *ConcurrentSortedSet[K, V]) StartSweeper(interval time.Duration, limit int) (stop func()) {
+	assert.Assert(interval > 0, "interval must be positive")
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				this.Sweep(limit)
+			case <-done:
+				return
+			}
+		}
+	}()
+	once := &sync.Once{}
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+/*
+	排名变化的通知
+	钩子在持有写锁时同步调用：不能在钩子中访问有序集合(会死锁)，也不能将事件中的数据泄露到钩子之外
+*/
+
+func (this *ConcurrentSortedSet[K, V]) AddChangeHook(hook ChangeHook[K, V]) int {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.AddChangeHook(hook)
+}
+
+func (this *ConcurrentSortedSet[K, V]) AddThresholdWatcher(n int, rev bool, fn ThresholdWatcher[K, V]) int {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.AddThresholdWatcher(n, rev, fn)
+}
+
+func (this *ConcurrentSortedSet[K, V]) RemoveHook(id int) bool {
+	this.lock()
+	defer this.mu.Unlock()
+	return this.ss.RemoveHook(id)
+}
+
+/*
+	原子的组合操作
+*/
+
+// 插入并返回排名
+// 插入失败(key已存在)时返回0
+func (this *ConcurrentSortedSet[K, V]) InsertAndRank(data *NodeData[K, V]) (int, bool) {
+	this.lock()
+	defer this.mu.Unlock()
+	if !this.ss.Insert(data) {
+		return 0, false
+	}
+	return this.ss.GetRank(data.Key), true
+}
+
+// 更新分数并返回新的排名
+// key不存在时返回0
+func (this *ConcurrentSortedSet[K, V]) UpdateScoreAndRank(key K, newScore float64) (int, bool) {
+	this.lock()
+	defer this.mu.Unlock()
+	if _, ok := this.ss.UpdateScore(key, newScore); !ok {
+		return 0, false
+	}
+	return this.ss.GetRank(key), true
+}
+
+// 删除并返回删除前的排名
+// key不存在时返回0
+func (this *ConcurrentSortedSet[K, V]) DeleteAndRank(key K) (*NodeData[K, V], int) {
+	this.lock()
+	defer this.mu.Unlock()
+	rank := this.ss.GetRank(key)
+	if rank == 0 {
+		return nil, 0
+	}
+	data, _ := this.ss.Delete(key)
+	return data, rank
+}
+
+// 同时获取数据和排名(两者是一致的)
+func (this *ConcurrentSortedSet[K, V]) GetWithRank(key K) (*NodeData[K, V], int) {
+	this.rlock()
+	defer this.mu.RUnlock()
+	data := this.ss.Get(key)
+	if data == nil {
+		return nil, 0
+	}
+	return copyData(data), this.ss.GetRank(key)
+}
+
+/*
+	只读视图
+*/
+
+// 创建当前状态的只读视图(O(1))
+// 视图和有序集合共享结点，读取视图时会加读锁(遍历时每移动一次加一次锁，遍历的过程中可以修改有序集合)
+// 视图中的数据是内部数据(不是拷贝)，不能被调用者修改
+// 视图不再使用时应该调用Release
+func (this *ConcurrentSortedSet[K, V]) Snapshot() *SortedSetView[K, V] {
+	this.lock()
+	defer this.mu.Unlock()
+	view := this.ss.Snapshot()
+	view.ss.view.mu = this.mu.RLocker()
+	return view
+}
+
+/*
+	持久化
+*/
+
+func (this *ConcurrentSortedSet[K, V]) WriteSnapshot(w io.Writer, kc Codec[K], vc Codec[V]) error {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.WriteSnapshot(w, kc, vc)
+}
+
+func (this *ConcurrentSortedSet[K, V]) ReadSnapshot(r io.Reader, kc Codec[K], vc Codec[V]) error {
+	this.lock()
+	defer this.mu.Unlock()
+	defer this.wakeUp()
+	return this.ss.ReadSnapshot(r, kc, vc)
+}
+
+func (this *ConcurrentSortedSet[K, V]) SaveSnapshot(file string, kc Codec[K], vc Codec[V]) error {
+	this.rlock()
+	defer this.mu.RUnlock()
+	return this.ss.SaveSnapshot(file, kc, vc)
+}
+
+func (this *ConcurrentSortedSet[K, V]) LoadSnapshot(file string, kc Codec[K], vc Codec[V]) error {
+	this.lock()
+	defer this.mu.Unlock()
+	defer this.wakeUp()
+	return this.ss.LoadSnapshot(file, kc, vc)
+}
+
+func (this *ConcurrentSortedSet[K, V]) AttachJournal(j *Journal[K, V]) {
+	this.lock()
+	defer this.mu.Unlock()
+	this.ss.AttachJournal(j)
+}
+
+// 重写操作日志期间不能修改有序集合
+func (this *ConcurrentSortedSet[K, V]) RewriteJournal() error {
+	this.rlock()
+	defer this.mu.RUnlock()
+	j := this.ss.Journal()
+	if j == nil {
+		return ErrJournalClosed
+	}
+	return j.Rewrite(this.ss)
+}
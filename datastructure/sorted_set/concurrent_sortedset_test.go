@@ -0,0 +1,348 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 并发压力测试，需要配合竞态检测运行: go test -race -run Concurrent
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func compareInt64(a, b int64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// 分别使用跳跃表编码、两种编码之间转换、紧凑编码
+func TestConcurrentSortedSet(t *testing.T) {
+	for _, maxEntries := range []int{0, 400, 1000} {
+		t.Run(fmt.Sprint(maxEntries), func(t *testing.T) {
+			testConcurrentSortedSet(t, maxEntries)
+		})
+	}
+}
+
+func testConcurrentSortedSet(t *testing.T, maxEntries int) {
+	const (
+		writers       = 4
+		readers       = 4
+		keysPerWriter = 200
+		rounds        = 2000
+	)
+	ss := NewConcurrentSortedSet[int64, int64](compareInt64)
+	ss.SetCompactMaxEntries(maxEntries)
+	wg := sync.WaitGroup{}
+
+	// 每个写协程只操作属于自己的key，方便验证结果
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			base := int64(w * keysPerWriter)
+			for i := 0; i < rounds; i++ {
+				key := base + r.Int63n(keysPerWriter)
+				score := float64(r.Intn(100))
+				switch r.Intn(4) {
+				case 0:
+					if rank, ok := ss.InsertAndRank(NewNodeData(key, score, key)); ok && rank <= 0 {
+						t.Errorf("插入后排名不正确, key:%d rank:%d", key, rank)
+					}
+				case 1:
+					if data, rank := ss.DeleteAndRank(key); data != nil && (data.Key != key || rank <= 0) {
+						t.Errorf("删除结果不正确, key:%d rank:%d", key, rank)
+					}
+				default:
+					if rank, ok := ss.UpdateScoreAndRank(key, score); ok {
+						// 只有自己会修改这个key,所以分数一定是刚刚更新的分数
+						data, _ := ss.GetWithRank(key)
+						if data == nil || data.Score != score || rank <= 0 {
+							t.Errorf("更新分数结果不正确, key:%d rank:%d", key, rank)
+						}
+					}
+				}
+			}
+		}(w)
+	}
+
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(writers + i)))
+			for j := 0; j < rounds; j++ {
+				key := r.Int63n(writers * keysPerWriter)
+				if data, rank := ss.GetWithRank(key); data != nil && (data.Key != key || rank <= 0) {
+					t.Errorf("获取结果不正确, key:%d rank:%d", key, rank)
+				}
+				datas := ss.GetRangeByScore(20, false, 80, true)
+				for k := 1; k < len(datas); k++ {
+					if datas[k-1].Score > datas[k].Score {
+						t.Errorf("返回的元素必须是有序的")
+					}
+				}
+				revDatas := ss.GetRevRangeByRank(1, 10)
+				for k := 1; k < len(revDatas); k++ {
+					if revDatas[k-1].Score < revDatas[k].Score {
+						t.Errorf("返回的元素必须是逆序的")
+					}
+				}
+				ss.View(func(ss *SortedSet[int64, int64]) {
+					if err := ss.Validate(); err != nil {
+						t.Errorf("结构不合法:%v", err)
+					}
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ss.View(func(ss *SortedSet[int64, int64]) {
+		prev := (*NodeData[int64, int64])(nil)
+		for rank, data := range ss.All() {
+			if prev != nil && (prev.Score > data.Score || prev.Score == data.Score && prev.Val >= data.Val) {
+				t.Fatalf("有序集合必须是有序的, rank:%d", rank)
+			}
+			if ss.GetRank(data.Key) != rank {
+				t.Fatalf("排名不正确, rank:%d", rank)
+			}
+			prev = data
+		}
+	})
+}
+
+// count不合法时直接panic，不能一直持有锁
+func TestConcurrentSortedSetBlockingPopCount(t *testing.T) {
+	ss := NewConcurrentSortedSet[int64, int64](compareInt64)
+	ss.Insert(NewNodeData(int64(1), 1, int64(1)))
+	for _, pop := range []func(context.Context, int) ([]*NodeData[int64, int64], error){ss.BlockingPopMin, ss.BlockingPopMax} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("count为0时必须panic")
+				}
+			}()
+			pop(context.Background(), 0)
+		}()
+	}
+	// 锁没有被一直持有
+	if datas, err := ss.BlockingPopMin(context.Background(), 1); err != nil || len(datas) != 1 {
+		t.Fatalf("弹出结果不正确, err:%v", err)
+	}
+}
+
+func TestConcurrentSortedSetBlockingPop(t *testing.T) {
+	const (
+		producers   = 4
+		consumers   = 4
+		perProducer = 500
+	)
+	ss := NewConcurrentSortedSet[int64, int64](compareInt64)
+
+	// 空的有序集合上等待会超时
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	if datas, err := ss.BlockingPopMin(ctx, 1); err != context.DeadlineExceeded || datas != nil {
+		t.Fatalf("等待超时结果不正确, err:%v", err)
+	}
+	cancel()
+
+	wg := sync.WaitGroup{}
+	popped := make(chan int64, producers*perProducer)
+	producing := atomic.Int32{}
+	producing.Store(producers)
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				var datas []*NodeData[int64, int64]
+				var err error
+				if i%2 == 0 {
+					datas, err = ss.BlockingPopMin(ctx, 3)
+				} else {
+					datas, err = ss.BlockingPopMax(ctx, 2)
+				}
+				cancel()
+				if err != nil {
+					// 生产者都结束并且元素都被弹出后才退出
+					if producing.Load() == 0 && ss.Length() == 0 {
+						return
+					}
+					continue
+				}
+				if len(datas) == 0 {
+					t.Errorf("阻塞弹出不能返回空结果")
+				}
+				for _, data := range datas {
+					popped <- data.Key
+				}
+			}
+		}(i)
+	}
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			defer producing.Add(-1)
+			for i := 0; i < perProducer; i++ {
+				key := int64(p*perProducer + i)
+				ss.Insert(NewNodeData(key, float64(i%17), key))
+			}
+		}(p)
+	}
+	wg.Wait()
+	close(popped)
+
+	// 每个元素都恰好被弹出一次
+	seen := map[int64]bool{}
+	for key := range popped {
+		if seen[key] {
+			t.Fatalf("元素被重复弹出, key:%d", key)
+		}
+		seen[key] = true
+	}
+	if len(seen) != producers*perProducer || ss.Length() != 0 {
+		t.Fatalf("弹出的数量不正确:%d 剩余:%d", len(seen), ss.Length())
+	}
+}
+
+// 过期的元素在加锁时被删除，读操作(只持有读锁)永远看不到过期的元素
+func TestConcurrentSortedSetExpire(t *testing.T) {
+	const (
+		keys   = 500
+		rounds = 2000
+	)
+	ss := NewConcurrentSortedSet[int64, int64](compareInt64)
+	stop := ss.StartSweeper(time.Millisecond, 10)
+	defer stop()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < rounds; i++ {
+			key := r.Int63n(keys)
+			ss.Insert(NewNodeData(key, float64(r.Intn(100)), key))
+			ss.Expire(key, time.Duration(r.Intn(2000))*time.Microsecond)
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				ss.View(func(ss *SortedSet[int64, int64]) {
+					now := time.Now()
+					for _, data := range ss.All() {
+						if at, ok := ss.GetExpireAt(data.Key); ok && at.Before(now.Add(-time.Second)) {
+							t.Errorf("读到了过期的元素, key:%d", data.Key)
+						}
+					}
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	time.Sleep(5 * time.Millisecond)
+	if n := ss.Length(); n != 0 {
+		t.Errorf("所有元素都应该过期, length:%d", n)
+	}
+}
+
+// 写协程持续修改，读协程同时读取和遍历只读视图，视图的内容不能改变
+// 分别使用跳跃表编码、两种编码之间转换、紧凑编码
+func TestConcurrentSortedSetSnapshot(t *testing.T) {
+	for _, maxEntries := range []int{0, 400, 500} {
+		t.Run(fmt.Sprint(maxEntries), func(t *testing.T) {
+			testConcurrentSortedSetSnapshot(t, maxEntries)
+		})
+	}
+}
+
+func testConcurrentSortedSetSnapshot(t *testing.T, maxEntries int) {
+	const (
+		keys   = 500
+		rounds = 3000
+	)
+	ss := NewConcurrentSortedSet[int64, int64](compareInt64)
+	ss.SetCompactMaxEntries(maxEntries)
+	for key := int64(0); key < keys; key++ {
+		ss.Insert(NewNodeData(key, float64(key%50), key))
+	}
+	view := ss.Snapshot()
+	expected := view.GetRangeByRank(1, keys)
+	scores := make([]float64, len(expected))
+	for i, data := range expected {
+		scores[i] = data.Score
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < rounds; i++ {
+			key := r.Int63n(keys)
+			switch r.Intn(3) {
+			case 0:
+				ss.Delete(key)
+			case 1:
+				ss.Insert(NewNodeData(key, float64(r.Intn(100)), key))
+			default:
+				ss.UpdateScore(key, float64(r.Intn(100)))
+			}
+			if i%500 == 0 {
+				// 视图可以随时创建
+				ss.Snapshot().Release()
+			}
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds/10; i++ {
+				if n := view.Length(); n != keys {
+					t.Errorf("视图的长度不能改变, length:%d", n)
+					return
+				}
+				for rank, data := range view.All() {
+					if data != expected[rank-1] || data.Score != scores[rank-1] {
+						t.Errorf("视图的内容不能改变, rank:%d key:%d", rank, data.Key)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
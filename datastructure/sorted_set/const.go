@@ -23,4 +23,5 @@ const (
 	SKIPLIST_MAXLEVEL           = 32            // 跳跃表节点的最高高度
 	DEFAULT_LEVELUP_PROBABILITY = 0.25          // 提升节点高度的概率
 	RAND_MAX                    = math.MaxInt32 // int32的最大值 (0x7fffffff)
+	DEFAULT_COMPACT_MAX_ENTRIES = 128           // 紧凑编码的默认最大元素数量(和redis的zset-max-listpack-entries一致)
 )
\ No newline at end of file
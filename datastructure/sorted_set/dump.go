@@ -0,0 +1,238 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 跳跃表的结构输出(调试用)
+// 文本格式:每一层一行，每个结点一列，结点在该层存在时输出"key(跨度)"，方便直接看出索引的分布
+// DOT格式:可以用Graphviz渲染成图片(比如 dot -Tsvg dump.dot -o dump.svg)
+// 结点很多时(比如线上的快照)可以只输出前maxNodes个结点，超出的部分用"..."表示
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// 需要输出的结点(maxNodes小于等于0表示全部输出)
+func (this *SkipList[K, V]) dumpNodes(maxNodes int) ([]*Node[K, V], bool) {
+	nodes := make([]*Node[K, V], 0, min(this.Length, 1024))
+	for current := this.Head.Levels[0].Forward; current != nil; current = current.Levels[0].Forward {
+		if maxNodes > 0 && len(nodes) >= maxNodes {
+			return nodes, true
+		}
+		nodes = append(nodes, current)
+	}
+	return nodes, false
+}
+
+// 以文本格式输出跳跃表的结构
+// 比如:
+//
+//	length:2 level:2
+//	L1 HEAD(2) ---- 2(0) NIL
+//	L0 HEAD(1) 1(1) 2(0) NIL
+//	   score   10   20
+func (this *SkipList[K, V]) DumpText(w io.Writer, maxNodes int) error {
+	nodes, truncated := this.dumpNodes(maxNodes)
+	// 第一列是头结点，之后每一列是一个结点
+	cells := make([][]string, this.Level)
+	for i := this.Level - 1; i >= 0; i-- {
+		row := make([]string, 0, len(nodes)+1)
+		row = append(row, fmt.Sprintf("HEAD(%d)", this.Head.Levels[i].Span))
+		for _, node := range nodes {
+			if i < node.High() {
+				row = append(row, fmt.Sprintf("%v(%d)", node.Data.Key, node.Levels[i].Span))
+			} else {
+				row = append(row, "")
+			}
+		}
+		cells[this.Level-1-i] = row
+	}
+	scores := make([]string, 0, len(nodes)+1)
+	scores = append(scores, "score")
+	for _, node := range nodes {
+		scores = append(scores, fmt.Sprint(node.Data.Score))
+	}
+	// 每一列的宽度
+	widths := make([]int, len(nodes)+1)
+	for _, row := range append(cells, scores) {
+		for j, cell := range row {
+			widths[j] = max(widths[j], utf8.RuneCountInString(cell))
+		}
+	}
+	
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "length:%d level:%d\n", this.Length, this.Level)
+	end := "NIL"
+	if truncated {
+		end = "..."
+	}
+	for i, row := range cells {
+		fmt.Fprintf(bw, "L%d ", this.Level-1-i)
+		for j, cell := range row {
+			if cell == "" {
+				// 结点在该层不存在
+				bw.WriteString(strings.Repeat("-", widths[j]))
+			} else {
+				bw.WriteString(cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+			}
+			bw.WriteString(" ")
+		}
+		bw.WriteString(end + "\n")
+	}
+	var line strings.Builder
+	line.WriteString(strings.Repeat(" ", len(fmt.Sprintf("L%d ", max(this.Level-1, 0)))))
+	for j, cell := range scores {
+		line.WriteString(cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)) + " ")
+	}
+	bw.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	return bw.Flush()
+}
+
+// DOT的record标签中需要转义的字符
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`, `>`, `\>`, "\n", `\n`)
+
+// 以Graphviz的DOT格式输出跳跃表的结构
+// 每个结点是一个record，从上到下依次是各层的索引和结点的数据，边上的数字是跨度
+func (this *SkipList[K, V]) DumpDot(w io.Writer, maxNodes int) error {
+	nodes, truncated := this.dumpNodes(maxNodes)
+	names := make(map[*Node[K, V]]string, len(nodes)+1)
+	names[this.Head] = "head"
+	for i, node := range nodes {
+		names[node] = fmt.Sprintf("n%d", i+1)
+	}
+	// 各层的索引(从上到下)
+	levelFields := func(high int) string {
+		fields := make([]string, 0, high)
+		for i := high - 1; i >= 0; i-- {
+			fields = append(fields, fmt.Sprintf("<l%d> L%d", i, i))
+		}
+		return strings.Join(fields, "|")
+	}
+	
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph skiplist {\n")
+	bw.WriteString("\trankdir=LR;\n")
+	bw.WriteString("\tnode [shape=record];\n")
+	fmt.Fprintf(bw, "\thead [label=\"%s|HEAD\\nlength:%d\"];\n", levelFields(max(this.Level, 1)), this.Length)
+	for _, node := range nodes {
+		fmt.Fprintf(bw, "\t%s [label=\"%s|%s\\n%s\"];\n", names[node], levelFields(node.High()),
+			dotEscaper.Replace(fmt.Sprint(node.Data.Key)), dotEscaper.Replace(fmt.Sprint(node.Data.Score)))
+	}
+	fmt.Fprintf(bw, "\tnil [label=\"%s|NIL\"];\n", levelFields(max(this.Level, 1)))
+	if truncated {
+		bw.WriteString("\tmore [shape=plaintext, label=\"...\"];\n")
+	}
+	for _, node := range append([]*Node[K, V]{this.Head}, nodes...) {
+		high := node.High()
+		if node == this.Head {
+			high = this.Level
+		}
+		for i := 0; i < high; i++ {
+			next := node.Levels[i].Forward
+			target := fmt.Sprintf("nil:l%d", i)
+			if name, ok := names[next]; ok {
+				target = fmt.Sprintf("%s:l%d", name, i)
+			} else if next != nil {
+				// 超出输出范围的结点
+				target = "more"
+			}
+			fmt.Fprintf(bw, "\t%s:l%d -> %s [label=\"%d\"];\n", names[node], i, target, node.Levels[i].Span)
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// 紧凑编码需要输出的数据(maxNodes的含义和跳跃表相同)
+func (this *compactList[K, V]) dumpDatas(maxNodes int) ([]*NodeData[K, V], bool) {
+	if maxNodes > 0 && len(this.datas) > maxNodes {
+		return this.datas[:maxNodes], true
+	}
+	return this.datas, false
+}
+
+// 以文本格式输出紧凑编码的数组
+// 比如:
+//
+//	length:2 encoding:compact
+//	key   1  2  NIL
+//	score 10 20
+func (this *compactList[K, V]) DumpText(w io.Writer, maxNodes int) error {
+	datas, truncated := this.dumpDatas(maxNodes)
+	keys := []string{"key"}
+	scores := []string{"score"}
+	for _, data := range datas {
+		keys = append(keys, fmt.Sprint(data.Key))
+		scores = append(scores, fmt.Sprint(data.Score))
+	}
+	end := "NIL"
+	if truncated {
+		end = "..."
+	}
+	
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "length:%d encoding:compact\n", len(this.datas))
+	var keyLine, scoreLine strings.Builder
+	for j := range keys {
+		width := max(utf8.RuneCountInString(keys[j]), utf8.RuneCountInString(scores[j]))
+		keyLine.WriteString(keys[j] + strings.Repeat(" ", width-utf8.RuneCountInString(keys[j])) + " ")
+		scoreLine.WriteString(scores[j] + strings.Repeat(" ", width-utf8.RuneCountInString(scores[j])) + " ")
+	}
+	bw.WriteString(keyLine.String() + end + "\n")
+	bw.WriteString(strings.TrimRight(scoreLine.String(), " ") + "\n")
+	return bw.Flush()
+}
+
+// 以Graphviz的DOT格式输出紧凑编码的数组(一个record，每个字段是一个数据)
+func (this *compactList[K, V]) DumpDot(w io.Writer, maxNodes int) error {
+	datas, truncated := this.dumpDatas(maxNodes)
+	fields := make([]string, 0, len(datas)+2)
+	fields = append(fields, fmt.Sprintf("COMPACT\\nlength:%d", len(this.datas)))
+	for _, data := range datas {
+		fields = append(fields, dotEscaper.Replace(fmt.Sprint(data.Key))+"\\n"+dotEscaper.Replace(fmt.Sprint(data.Score)))
+	}
+	if truncated {
+		fields = append(fields, "...")
+	}
+	
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph compact {\n")
+	bw.WriteString("\tnode [shape=record];\n")
+	fmt.Fprintf(bw, "\tdatas [label=\"%s\"];\n", strings.Join(fields, "|"))
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// 以文本格式输出有序集合的结构(参考SkipList.DumpText，紧凑编码时输出数组)
+func (this *SortedSet[K, V]) DumpText(w io.Writer, maxNodes int) error {
+	if this.compact != nil {
+		return this.compact.DumpText(w, maxNodes)
+	}
+	return this.Sl.DumpText(w, maxNodes)
+}
+
+// 以Graphviz的DOT格式输出有序集合的结构(参考SkipList.DumpDot，紧凑编码时输出数组)
+func (this *SortedSet[K, V]) DumpDot(w io.Writer, maxNodes int) error {
+	if this.compact != nil {
+		return this.compact.DumpDot(w, maxNodes)
+	}
+	return this.Sl.DumpDot(w, maxNodes)
+}
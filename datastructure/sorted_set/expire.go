@@ -0,0 +1,192 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 元素的过期时间
+// 1.惰性删除:访问有序集合时，先删除所有已经过期的元素(保证排名和范围查询不会包含过期的元素)
+// 2.主动删除:调用者定期调用Sweep，每次删除有限数量的过期元素，避免一次删除太多导致卡顿
+//   SortedSet不是并发安全的，没有自带的后台协程:需要由调用者在访问有序集合的协程中驱动(比如逻辑帧、定时器)
+//   如果一直不访问也不调用Sweep，过期的元素会一直占用内存；ConcurrentSortedSet可以用StartSweeper启动后台协程
+// 过期时间保存在最小堆中，堆顶就是最早过期的元素
+// 修改或者删除过期时间时不从堆中移除旧的记录，而是在弹出时和当前的过期时间比较，不一致就丢弃(延迟删除)
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"github.com/stormYuanYang/yytools/datastructure/heap"
+	"time"
+)
+
+type expireState[K comparable] struct {
+	heap     *heap.Heap  // 最小堆,Weight是过期时间(纳秒),Data是key
+	expireAt map[K]int64 // key的过期时间(纳秒)
+}
+
+// 有序集合的时钟(用于判断元素是否过期)，默认是time.Now
+// 可以替换为自定义的时钟，方便测试
+func (this *SortedSet[K, V]) SetClock(clock func() time.Time) {
+	assert.Assert(clock != nil, "clock must not be nil")
+	this.clock = clock
+}
+
+func (this *SortedSet[K, V]) now() int64 {
+	if this.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return this.clock().UnixNano()
+}
+
+// 设置元素的过期时间(元素不存在时返回false)
+func (this *SortedSet[K, V]) ExpireAt(key K, at time.Time) bool {
+	this.expireDue()
+	if _, exist := this.lookup(key); !exist {
+		return false
+	}
+	this.setExpireAt(key, at.UnixNano())
+	if this.journal != nil {
+		this.journal.appendExpireAt(key, at.UnixNano())
+	}
+	return true
+}
+
+// 设置元素的存活时间(元素不存在时返回false)
+func (this *SortedSet[K, V]) Expire(key K, ttl time.Duration) bool {
+	return this.ExpireAt(key, time.Unix(0, this.now()).Add(ttl))
+}
+
+// 移除元素的过期时间(元素不存在或者没有过期时间时返回false)
+func (this *SortedSet[K, V]) Persist(key K) bool {
+	this.expireDue()
+	if this.expire == nil {
+		return false
+	}
+	if _, has := this.expire.expireAt[key]; !has {
+		return false
+	}
+	delete(this.expire.expireAt, key)
+	if this.journal != nil {
+		this.journal.appendPersist(key)
+	}
+	return true
+}
+
+// 获取元素的过期时间(元素不存在或者没有过期时间时返回false)
+func (this *SortedSet[K, V]) GetExpireAt(key K) (time.Time, bool) {
+	this.expireDue()
+	if this.expire == nil {
+		return time.Time{}, false
+	}
+	at, has := this.expire.expireAt[key]
+	if !has {
+		return time.Time{}, false
+	}
+	return time.Unix(0, at), true
+}
+
+// 获取元素剩余的存活时间(元素不存在或者没有过期时间时返回false)
+func (this *SortedSet[K, V]) TTL(key K) (time.Duration, bool) {
+	at, ok := this.GetExpireAt(key)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(at.UnixNano() - this.now()), true
+}
+
+func (this *SortedSet[K, V]) setExpireAt(key K, at int64) {
+	if this.expire == nil {
+		this.expire = &expireState[K]{
+			heap:     heap.NewHeap(),
+			expireAt: map[K]int64{},
+		}
+	}
+	this.expire.expireAt[key] = at
+	this.expire.heap.PushItem(&heap.Item{
+		Data:   key,
+		Weight: at,
+	})
+	// 延迟删除的记录太多时，重建最小堆
+	if this.expire.heap.Length() > 2*len(this.expire.expireAt)+64 {
+		this.rebuildExpireHeap()
+	}
+}
+
+func (this *SortedSet[K, V]) rebuildExpireHeap() {
+	h := heap.NewHeap()
+	for key, at := range this.expire.expireAt {
+		h.PushItem(&heap.Item{
+			Data:   key,
+			Weight: at,
+		})
+	}
+	this.expire.heap = h
+}
+
+// 元素被删除时，同步删除其过期时间
+func (this *SortedSet[K, V]) forgetExpire(key K) {
+	if this.expire != nil {
+		delete(this.expire.expireAt, key)
+	}
+}
+
+// 是否有已经过期(但还没有被删除)的元素
+// 不会修改有序集合；堆顶可能是延迟删除的记录，所以结果是保守的
+func (this *SortedSet[K, V]) hasExpired() bool {
+	if this.expire == nil || this.expire.heap.Length() == 0 {
+		return false
+	}
+	return this.expire.heap.PeekItem().Weight <= this.now()
+}
+
+// 删除最多limit个过期的元素(limit小于等于0表示不限制)
+// 返回删除的元素数量
+func (this *SortedSet[K, V]) removeExpired(limit int) int {
+	if this.expire == nil {
+		return 0
+	}
+	now := this.now()
+	h := this.expire.heap
+	removed := 0
+	for h.Length() > 0 && (limit <= 0 || removed < limit) {
+		item := h.PeekItem()
+		if item.Weight > now {
+			break
+		}
+		h.PopItem()
+		key := item.Data.(K)
+		if at, has := this.expire.expireAt[key]; !has || at != item.Weight {
+			// 过期时间已经被修改或者移除
+			continue
+		}
+		this.deleteKey(key)
+		removed++
+	}
+	return removed
+}
+
+// 惰性删除:删除所有已过期的元素
+func (this *SortedSet[K, V]) expireDue() {
+	if this.expire != nil && !this.manualExpire {
+		this.removeExpired(0)
+	}
+}
+
+// 主动删除最多limit个过期的元素(limit小于等于0表示不限制)
+// 返回删除的元素数量
+// 需要由调用者定期调用(和其他操作在同一个协程中)，有序集合本身不会主动删除
+func (this *SortedSet[K, V]) Sweep(limit int) int {
+	return this.removeExpired(limit)
+}
@@ -0,0 +1,258 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 排名变化的通知
+// 1.变化钩子:插入、删除(包括范围删除、弹出、过期)、更新分数时，报告被修改的元素变化前后的排名和分数
+// 2.阈值监听:监听"前N名"的边界，元素进入或者离开前N名时通知(包括被挤出前N名、补进前N名的其他元素)
+// 元素变化时，其他元素之间的相对顺序不变，所以被挤出(或者补进)前N名的元素一定紧挨着边界，不需要遍历
+// 没有注册钩子时不会有任何额外的开销；注册之后每次修改多一次O(logn)的排名查询
+// 钩子在修改完成之后同步调用，钩子中不能修改有序集合
+// 钩子中可以注册或者移除钩子(包括移除自己)，只影响之后的通知:本次通知仍然按照通知开始时注册的钩子调用
+// 重新加载快照(ReadSnapshot)和批量构建(BulkLoad)会替换所有元素，不会触发钩子
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"slices"
+)
+
+// 变化的类型
+type ChangeType int32
+
+const (
+	ChangeInsert ChangeType = iota // 0 插入
+	ChangeDelete                   // 1 删除
+	ChangeUpdate                   // 2 更新分数
+)
+
+// 元素的变化
+// 范围删除时，一次删除的多个元素各对应一个事件，排名和长度都是相对整个范围删除之前和之后的
+type ChangeEvent[K comparable, V any] struct {
+	Type      ChangeType
+	Data      *NodeData[K, V] // 发生变化的元素
+	OldScore  float64         // 变化前的分数(插入时为0)
+	NewScore  float64         // 变化后的分数(删除时为0)
+	OldRank   int             // 变化前的排名(插入时为0)
+	NewRank   int             // 变化后的排名(删除时为0)
+	OldLength int             // 变化前有序集合的长度
+	NewLength int             // 变化后有序集合的长度
+}
+
+// 变化前的逆序排名(插入时为0)
+func (this *ChangeEvent[K, V]) OldRevRank() int {
+	return toRevRank(this.OldRank, this.OldLength)
+}
+
+// 变化后的逆序排名(删除时为0)
+// 更新分数时，逆序排名在新旧逆序排名之间的元素就是被超越(或者反超)的元素
+func (this *ChangeEvent[K, V]) NewRevRank() int {
+	return toRevRank(this.NewRank, this.NewLength)
+}
+
+func toRevRank(rank int, length int) int {
+	if rank == 0 {
+		return 0
+	}
+	return length - rank + 1
+}
+
+type ChangeHook[K comparable, V any] func(e *ChangeEvent[K, V])
+
+// 元素进入或者离开前N名
+type ThresholdEvent[K comparable, V any] struct {
+	Data    *NodeData[K, V]     // 进入或者离开前N名的元素
+	Entered bool                // true:进入前N名 false:离开前N名
+	Cause   *ChangeEvent[K, V] // 导致变化的事件(Data可能是被挤出或者补进前N名的其他元素)
+}
+
+type ThresholdWatcher[K comparable, V any] func(e *ThresholdEvent[K, V])
+
+type thresholdWatcher[K comparable, V any] struct {
+	n   int  // 前N名
+	rev bool // true:按逆序排名(分数最高的N个) false:按排名(分数最低的N个)
+	fn  ThresholdWatcher[K, V]
+}
+
+type hookEntry[K comparable, V any] struct {
+	id      int
+	hook    ChangeHook[K, V]
+	watcher *thresholdWatcher[K, V]
+}
+
+type hookState[K comparable, V any] struct {
+	nextID  int
+	entries []*hookEntry[K, V] // 按照注册的顺序调用(移除时复制一份，正在进行的通知不受影响)
+}
+
+func (this *SortedSet[K, V]) addHookEntry(entry *hookEntry[K, V]) int {
+	if this.hooks == nil {
+		this.hooks = &hookState[K, V]{}
+	}
+	this.hooks.nextID++
+	entry.id = this.hooks.nextID
+	this.hooks.entries = append(this.hooks.entries, entry)
+	return entry.id
+}
+
+// 注册变化钩子，返回的id用于移除钩子
+func (this *SortedSet[K, V]) AddChangeHook(hook ChangeHook[K, V]) int {
+	assert.Assert(hook != nil, "hook must not be nil")
+	return this.addHookEntry(&hookEntry[K, V]{hook: hook})
+}
+
+// 注册阈值监听:元素进入或者离开前n名时通知
+// rev为true时前n名指分数最高的n个元素(逆序排名)，否则指分数最低的n个元素
+// 返回的id用于移除监听
+func (this *SortedSet[K, V]) AddThresholdWatcher(n int, rev bool, fn ThresholdWatcher[K, V]) int {
+	assert.Assert(n > 0, "n must be positive number")
+	assert.Assert(fn != nil, "fn must not be nil")
+	return this.addHookEntry(&hookEntry[K, V]{
+		watcher: &thresholdWatcher[K, V]{
+			n:   n,
+			rev: rev,
+			fn:  fn,
+		},
+	})
+}
+
+// 移除钩子或者监听(id不存在时返回false)
+func (this *SortedSet[K, V]) RemoveHook(id int) bool {
+	if this.hooks == nil {
+		return false
+	}
+	for i, entry := range this.hooks.entries {
+		if entry.id == id {
+			// 不能原地删除:钩子中移除钩子时，通知还在遍历原来的切片
+			this.hooks.entries = slices.Delete(slices.Clone(this.hooks.entries), i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// 是否注册了钩子(没有注册时不需要计算变化前后的排名)
+func (this *SortedSet[K, V]) hooked() bool {
+	return this.hooks != nil && len(this.hooks.entries) > 0
+}
+
+// 单个元素变化之后通知
+func (this *SortedSet[K, V]) notifyChange(e *ChangeEvent[K, V]) {
+	for _, entry := range this.hooks.entries {
+		if entry.hook != nil {
+			entry.hook(e)
+		} else {
+			this.checkThreshold(entry.watcher, e)
+		}
+	}
+}
+
+// 范围删除之后通知
+// 被删除的元素在删除之前的排名是连续的，第一个的排名是firstRank
+func (this *SortedSet[K, V]) notifyRangeDelete(deleted []*NodeData[K, V], firstRank int, oldLength int) {
+	if len(deleted) == 0 {
+		return
+	}
+	events := make([]*ChangeEvent[K, V], 0, len(deleted))
+	for i, data := range deleted {
+		events = append(events, &ChangeEvent[K, V]{
+			Type:      ChangeDelete,
+			Data:      data,
+			OldScore:  data.Score,
+			OldRank:   firstRank + i,
+			OldLength: oldLength,
+			NewLength: this.length(),
+		})
+	}
+	for _, entry := range this.hooks.entries {
+		if entry.hook != nil {
+			for _, e := range events {
+				entry.hook(e)
+			}
+		} else {
+			this.checkThresholdRangeDelete(entry.watcher, events)
+		}
+	}
+}
+
+// 排名换算成监听方向上的位置(0表示不存在)
+func (this *thresholdWatcher[K, V]) position(rank int, length int) int {
+	if this.rev {
+		return toRevRank(rank, length)
+	}
+	return rank
+}
+
+// 变化之后监听方向上指定位置的元素
+func (this *SortedSet[K, V]) dataAtPosition(w *thresholdWatcher[K, V], pos int) *NodeData[K, V] {
+	rank := pos
+	if w.rev {
+		rank = this.length() - pos + 1
+	}
+	return this.list().dataByRank(rank)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// 单个元素变化时检查前n名的边界
+// 其他元素的相对顺序不变:变化前有a个其他元素在前n名中，变化后有b个
+// b大于a时，其他元素中第a+1到b个补进了前n名；b小于a时，第b+1到a个被挤出了前n名
+func (this *SortedSet[K, V]) checkThreshold(w *thresholdWatcher[K, V], e *ChangeEvent[K, V]) {
+	oldPos := w.position(e.OldRank, e.OldLength)
+	newPos := w.position(e.NewRank, e.NewLength)
+	inBefore := oldPos >= 1 && oldPos <= w.n
+	inAfter := newPos >= 1 && newPos <= w.n
+	if inBefore != inAfter {
+		w.fn(&ThresholdEvent[K, V]{Data: e.Data, Entered: inAfter, Cause: e})
+	}
+	
+	a := min(w.n, e.OldLength) - b2i(inBefore)
+	b := min(w.n, e.NewLength) - b2i(inAfter)
+	for i := min(a, b) + 1; i <= max(a, b); i++ {
+		// 其他元素中的第i个，在变化之后的位置(排在变化的元素之后的要加一)
+		pos := i
+		if newPos >= 1 && i >= newPos {
+			pos++
+		}
+		w.fn(&ThresholdEvent[K, V]{Data: this.dataAtPosition(w, pos), Entered: b > a, Cause: e})
+	}
+}
+
+// 范围删除时检查前n名的边界
+// 删除不会让其他元素离开前n名，只会让其他元素补进前n名
+func (this *SortedSet[K, V]) checkThresholdRangeDelete(w *thresholdWatcher[K, V], events []*ChangeEvent[K, V]) {
+	removed := 0
+	for _, e := range events {
+		if pos := w.position(e.OldRank, e.OldLength); pos <= w.n {
+			removed++
+			w.fn(&ThresholdEvent[K, V]{Data: e.Data, Entered: false, Cause: e})
+		}
+	}
+	oldLength := events[0].OldLength
+	a := min(w.n, oldLength) - removed
+	b := min(w.n, this.length())
+	assert.Assert(b >= a, "范围删除不会让其他元素离开前n名, a:", a, " b:", b)
+	for pos := a + 1; pos <= b; pos++ {
+		// 补进前n名的元素由最后一个被删除的元素导致(和被删除的元素没有一一对应的关系)
+		w.fn(&ThresholdEvent[K, V]{Data: this.dataAtPosition(w, pos), Entered: true, Cause: events[len(events)-1]})
+	}
+}
@@ -0,0 +1,118 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 有序集合的接口
+// 只包含增删改查、排名和范围相关的操作，不同的有序结构(跳跃表、树堆、有序数组)都可以实现
+// 过期时间、操作日志、快照、钩子等附加功能只有基于跳跃表的SortedSet支持
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"iter"
+	"math"
+)
+
+type ISortedSet[K comparable, V any] interface {
+	Get(key K) *NodeData[K, V]                                   // 获取数据
+	Length() int                                                 // 元素数量
+	Insert(data *NodeData[K, V]) bool                            // 插入(key已存在时失败)
+	Delete(key K) (*NodeData[K, V], bool)                        // 删除
+	Add(data *NodeData[K, V], flags AddFlag) bool                // 添加或者更新(参考redis的ZADD)
+	IncrScore(key K, delta float64, val V) (float64, int, bool)  // 增加分数(参考redis的ZINCRBY)
+	UpdateScore(key K, newScore float64) (*NodeData[K, V], bool) // 更新分数
+	
+	GetRank(key K) int                                      // 排名(不存在时为0)
+	GetRevRank(key K) int                                   // 逆序排名(不存在时为0)
+	GetByRank(rank int) *NodeData[K, V]                     // 通过排名获取数据
+	GetByRevRank(rank int) *NodeData[K, V]                  // 通过逆序排名获取数据
+	GetRangeByRank(start int, end int) []*NodeData[K, V]    // 排名范围内的数据
+	GetRevRangeByRank(start int, end int) []*NodeData[K, V] // 逆序排名范围内的数据
+	DeleteRangeByRank(start int, end int) []*NodeData[K, V] // 删除排名范围内的数据
+	PopMin(count int) []*NodeData[K, V]                     // 弹出分数最低的若干数据
+	PopMax(count int) []*NodeData[K, V]                     // 弹出分数最高的若干数据
+	
+	GetRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V]                                // 分数范围内的数据
+	GetRevRangeByScore(max float64, maxEx bool, min float64, minEx bool) []*NodeData[K, V]                             // 分数范围内的数据(从高到低)
+	GetRangeByScoreLimit(min float64, minEx bool, max float64, maxEx bool, offset int, count int) []*NodeData[K, V]    // 分页获取分数范围内的数据
+	GetRevRangeByScoreLimit(max float64, maxEx bool, min float64, minEx bool, offset int, count int) []*NodeData[K, V] // 分页获取分数范围内的数据(从高到低)
+	CountByScore(min float64, minEx bool, max float64, maxEx bool) int                                                 // 分数范围内的数据数量
+	DeleteRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V]                             // 删除分数范围内的数据
+	
+	GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V]    // 值范围内的数据
+	CountByValue(r *ValueRangeSpecified[V]) int                     // 值范围内的数据数量
+	DeleteRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] // 删除值范围内的数据
+	
+	All() iter.Seq2[int, *NodeData[K, V]]                                                              // 按分数从低到高遍历
+	Backward() iter.Seq2[int, *NodeData[K, V]]                                                         // 按分数从高到低遍历
+	IterRangeByRank(start int, end int) iter.Seq2[int, *NodeData[K, V]]                                // 遍历排名范围
+	IterRangeByScore(min float64, minEx bool, max float64, maxEx bool) iter.Seq2[int, *NodeData[K, V]] // 遍历分数范围
+}
+
+// 编译时检查是否实现了接口
+var (
+	_ ISortedSet[int, int] = (*SortedSet[int, int])(nil)
+	_ ISortedSet[int, int] = (*TreapSortedSet[int, int])(nil)
+)
+
+// 添加或者更新元素(ZADD)，不同的实现共用
+func addData[K comparable, V any](ss ISortedSet[K, V], data *NodeData[K, V], flags AddFlag) bool {
+	assert.Assert(data != nil, "data == nil")
+	assert.Assert(flags&AddNX == 0 || flags&AddXX == 0, "NX和XX不能同时指定")
+	assert.Assert(flags&AddNX == 0 || flags&(AddGT|AddLT) == 0, "NX和GT、LT不能同时指定")
+	assert.Assert(flags&AddGT == 0 || flags&AddLT == 0, "GT和LT不能同时指定")
+	
+	old := ss.Get(data.Key)
+	if old == nil {
+		if flags&AddXX != 0 {
+			return false
+		}
+		return ss.Insert(data)
+	}
+	
+	if flags&AddNX != 0 ||
+		flags&AddGT != 0 && data.Score <= old.Score ||
+		flags&AddLT != 0 && data.Score >= old.Score ||
+		data.Score == old.Score {
+		// 不需要更新
+		return false
+	}
+	_, ok := ss.UpdateScore(data.Key, data.Score)
+	assert.Assert(ok, "update must success, data.Key:", data.Key)
+	return flags&AddCH != 0
+}
+
+// 增加元素的分数(ZINCRBY)，不同的实现共用
+func incrScore[K comparable, V any](ss ISortedSet[K, V], key K, delta float64, val V) (float64, int, bool) {
+	assert.Assert(!math.IsNaN(delta), "delta is not a number")
+	
+	old := ss.Get(key)
+	if old == nil {
+		data := NewNodeData(key, delta, val)
+		ss.Insert(data)
+		return delta, ss.GetRank(key), true
+	}
+	newScore := old.Score + delta
+	if math.IsNaN(newScore) {
+		return old.Score, ss.GetRank(key), false
+	}
+	if newScore != old.Score {
+		_, ok := ss.UpdateScore(key, newScore)
+		assert.Assert(ok, "update must success, key:", key)
+	}
+	return newScore, ss.GetRank(key), true
+}
@@ -0,0 +1,130 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 不同实现的性能对比: go test -bench ISortedSet -benchmem
+// 小集合的内存占用对比: go test -bench ISortedSetSmallMemory -benchmem
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+const benchSortedSetSize = 100000
+
+var benchSortedSetImpls = []struct {
+	name string
+	new  func() ISortedSet[int64, int64]
+}{
+	{"SkipList", func() ISortedSet[int64, int64] { return NewSortedSet[int64, int64](compareInt64) }},
+	{"Treap", func() ISortedSet[int64, int64] { return NewTreapSortedSet[int64, int64](compareInt64) }},
+	{"Compact", func() ISortedSet[int64, int64] {
+		ss := NewSortedSet[int64, int64](compareInt64)
+		ss.SetCompactMaxEntries(DEFAULT_COMPACT_MAX_ENTRIES)
+		return ss
+	}},
+}
+
+// 插入n个分数随机的元素
+func benchFillSortedSet(ss ISortedSet[int64, int64], n int) {
+	for i := 0; i < n; i++ {
+		ss.Insert(NewNodeData(int64(i), float64(rand.Intn(n)), int64(i)))
+	}
+}
+
+func BenchmarkISortedSetInsert(b *testing.B) {
+	for _, impl := range benchSortedSetImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			ss := impl.new()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ss.Insert(NewNodeData(int64(i), float64(rand.Intn(benchSortedSetSize)), int64(i)))
+			}
+		})
+	}
+}
+
+func BenchmarkISortedSetUpdateScore(b *testing.B) {
+	for _, impl := range benchSortedSetImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			ss := impl.new()
+			benchFillSortedSet(ss, benchSortedSetSize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ss.UpdateScore(int64(rand.Intn(benchSortedSetSize)), float64(rand.Intn(benchSortedSetSize)))
+			}
+		})
+	}
+}
+
+func BenchmarkISortedSetGetRank(b *testing.B) {
+	for _, impl := range benchSortedSetImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			ss := impl.new()
+			benchFillSortedSet(ss, benchSortedSetSize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ss.GetRank(int64(rand.Intn(benchSortedSetSize)))
+			}
+		})
+	}
+}
+
+func BenchmarkISortedSetGetRangeByRank(b *testing.B) {
+	for _, count := range []int{10, 100} {
+		for _, impl := range benchSortedSetImpls {
+			b.Run(fmt.Sprintf("%s/%d", impl.name, count), func(b *testing.B) {
+				ss := impl.new()
+				benchFillSortedSet(ss, benchSortedSetSize)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					start := rand.Intn(benchSortedSetSize-count) + 1
+					ss.GetRangeByRank(start, start+count-1)
+				}
+			})
+		}
+	}
+}
+
+// 构建大量的小集合，heap-B/set是GC之后每个有序集合实际占用的堆内存
+// B/op是构建过程中分配的内存(包括扩容产生的垃圾)
+func BenchmarkISortedSetSmallMemory(b *testing.B) {
+	for _, n := range []int{16, 64, 128} {
+		for _, impl := range benchSortedSetImpls {
+			b.Run(fmt.Sprintf("%s/%d", impl.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				sets := make([]ISortedSet[int64, int64], b.N)
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					sets[i] = impl.new()
+					benchFillSortedSet(sets[i], n)
+				}
+				b.StopTimer()
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/float64(b.N), "heap-B/set")
+				runtime.KeepAlive(sets)
+			})
+		}
+	}
+}
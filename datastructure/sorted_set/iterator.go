@@ -0,0 +1,365 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 跳跃表(有序集合)的游标和迭代器
+// 游标可以定位到指定的分数、排名或者key,然后惰性地向前或向后移动
+// 遍历过程中不会分配保存结果的切片，适合对大量数据分页扫描
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"iter"
+	"math"
+)
+
+// 游标
+// 注意：游标不感知跳跃表的修改
+// 修改跳跃表(特别是删除游标所在的结点)后，需要重新定位游标
+// 通过视图创建的游标读取的是创建视图时的状态
+// 通过紧凑编码的有序集合创建的游标按排名在数组中移动(重新定位时使用有序集合当前的编码)
+type Iterator[K comparable, V any] struct {
+	sl      *SkipList[K, V]
+	compact *compactList[K, V] // 紧凑编码时才有(此时sl为nil)
+	ss      *SortedSet[K, V]   // 通过有序集合创建时才有，用于按key定位
+	current *Node[K, V]        // 当前所在的结点(紧凑编码时为nil)
+	data    *NodeData[K, V]    // 当前结点携带的数据(nil表示游标无效，视图中是创建视图时的数据)
+	rank    int                // 当前结点的排名(游标无效时为0)
+}
+
+func (this *SkipList[K, V]) NewIterator() *Iterator[K, V] {
+	return &Iterator[K, V]{
+		sl: this,
+	}
+}
+
+// 通过有序集合创建的游标，可以按key定位
+func (this *SortedSet[K, V]) NewIterator() *Iterator[K, V] {
+	this.expireDue()
+	return &Iterator[K, V]{
+		sl:      this.Sl,
+		compact: this.compact,
+		ss:      this,
+	}
+}
+
+// 定位之前调用:加锁(视图需要时)，并且使用有序集合当前的编码
+func (this *Iterator[K, V]) lock() {
+	if this.ss != nil {
+		this.sl, this.compact = this.ss.Sl, this.ss.compact
+	}
+	if this.sl != nil {
+		this.sl.lockView()
+	}
+}
+
+func (this *Iterator[K, V]) unlock() {
+	if this.sl != nil {
+		this.sl.unlockView()
+	}
+}
+
+func (this *Iterator[K, V]) set(node *Node[K, V], rank int) bool {
+	if node == nil {
+		this.current = nil
+		this.data = nil
+		this.rank = 0
+		return false
+	}
+	this.current = node
+	this.data = this.sl.state(node).Data
+	this.rank = rank
+	return true
+}
+
+// 紧凑编码时，定位到指定排名
+func (this *Iterator[K, V]) setRank(rank int) bool {
+	this.current = nil
+	this.data = this.compact.dataByRank(rank)
+	if this.data == nil {
+		this.rank = 0
+		return false
+	}
+	this.rank = rank
+	return true
+}
+
+// 游标是否指向一个有效的结点
+func (this *Iterator[K, V]) Valid() bool {
+	return this.data != nil
+}
+
+// 游标当前指向的数据(游标无效时返回nil)
+func (this *Iterator[K, V]) Data() *NodeData[K, V] {
+	return this.data
+}
+
+// 游标当前指向的数据的排名(游标无效时返回0)
+func (this *Iterator[K, V]) Rank() int {
+	return this.rank
+}
+
+// 定位到第一个结点(分数最小)
+func (this *Iterator[K, V]) SeekToFirst() bool {
+	this.lock()
+	defer this.unlock()
+	if this.compact != nil {
+		return this.setRank(1)
+	}
+	return this.set(this.sl.state(this.sl.Head).Levels[0].Forward, 1)
+}
+
+// 定位到最后一个结点(分数最大)
+func (this *Iterator[K, V]) SeekToLast() bool {
+	this.lock()
+	defer this.unlock()
+	if this.compact != nil {
+		return this.setRank(len(this.compact.datas))
+	}
+	return this.set(this.sl.Tail, this.sl.Length)
+}
+
+// 定位到指定排名的结点
+func (this *Iterator[K, V]) SeekRank(rank int) bool {
+	this.lock()
+	defer this.unlock()
+	if this.compact != nil {
+		return this.setRank(rank)
+	}
+	if rank <= 0 || rank > this.sl.Length {
+		return this.set(nil, 0)
+	}
+	return this.set(this.sl.GetNodeByRank(rank), rank)
+}
+
+// 定位到第一个分数大于等于score的结点
+func (this *Iterator[K, V]) SeekScore(score float64) bool {
+	assert.Assert(!math.IsNaN(score), "score is not a number:", score)
+	
+	return this.seekRange(&RangeSpecified{
+		Min: score,
+		Max: math.Inf(1),
+	})
+}
+
+// 定位到指定分数范围内的第一个结点
+func (this *Iterator[K, V]) seekRange(r *RangeSpecified) bool {
+	this.lock()
+	defer this.unlock()
+	if this.compact != nil {
+		first, _ := this.compact.rankRangeByScore(r)
+		return this.setRank(first)
+	}
+	node := this.sl.FirstInRange(r)
+	if node == nil {
+		return this.set(nil, 0)
+	}
+	return this.set(node, this.sl.GetRank(this.sl.state(node).Data))
+}
+
+// 定位到最后一个分数小于等于score的结点(方便按分数从高到低遍历)
+func (this *Iterator[K, V]) SeekRevScore(score float64) bool {
+	assert.Assert(!math.IsNaN(score), "score is not a number:", score)
+	
+	r := &RangeSpecified{
+		Min: math.Inf(-1),
+		Max: score,
+	}
+	this.lock()
+	defer this.unlock()
+	if this.compact != nil {
+		_, last := this.compact.rankRangeByScore(r)
+		return this.setRank(last)
+	}
+	node := this.sl.LastInRange(r)
+	if node == nil {
+		return this.set(nil, 0)
+	}
+	return this.set(node, this.sl.GetRank(this.sl.state(node).Data))
+}
+
+// 定位到指定数据所在的结点
+func (this *Iterator[K, V]) SeekData(data *NodeData[K, V]) bool {
+	assert.Assert(data != nil, "data must not be nil")
+	
+	this.lock()
+	defer this.unlock()
+	return this.seekData(data)
+}
+
+func (this *Iterator[K, V]) seekData(data *NodeData[K, V]) bool {
+	if this.compact != nil {
+		return this.setRank(this.compact.GetRank(data))
+	}
+	node, ok := this.sl.Get(data.Score, data)
+	if !ok {
+		return this.set(nil, 0)
+	}
+	return this.set(node, this.sl.GetRank(data))
+}
+
+// 定位到指定key所在的结点
+// 只有通过有序集合创建的游标才能按key定位
+func (this *Iterator[K, V]) SeekKey(key K) bool {
+	assert.Assert(this.ss != nil, "只有通过有序集合创建的游标才能按key定位")
+	
+	this.lock()
+	defer this.unlock()
+	data, exist := this.ss.lookup(key)
+	if !exist {
+		return this.set(nil, 0)
+	}
+	return this.seekData(data)
+}
+
+// 游标向前(分数增大的方向)移动一个结点
+func (this *Iterator[K, V]) Next() bool {
+	if this.data == nil {
+		return false
+	}
+	if this.compact != nil {
+		return this.setRank(this.rank + 1)
+	}
+	this.sl.lockView()
+	defer this.sl.unlockView()
+	return this.set(this.sl.state(this.current).Levels[0].Forward, this.rank+1)
+}
+
+// 游标向后(分数减小的方向)移动一个结点
+func (this *Iterator[K, V]) Prev() bool {
+	if this.data == nil {
+		return false
+	}
+	if this.compact != nil {
+		return this.setRank(this.rank - 1)
+	}
+	this.sl.lockView()
+	defer this.sl.unlockView()
+	return this.set(this.sl.state(this.current).Backward, this.rank-1)
+}
+
+/*
+	迭代器(配合for range使用)
+	遍历时依次产生排名和数据
+	迭代器通过游标实现，每次只移动一个结点
+*/
+
+// 按分数从低到高遍历所有数据
+func (this *SkipList[K, V]) All() iter.Seq2[int, *NodeData[K, V]] {
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		it := this.NewIterator()
+		for ok := it.SeekToFirst(); ok; ok = it.Next() {
+			if !yield(it.Rank(), it.Data()) {
+				return
+			}
+		}
+	}
+}
+
+// 按分数从低到高遍历所有数据(不加锁，调用者已经持有读锁或者不需要加锁)
+func (this *SkipList[K, V]) walk(yield func(int, *NodeData[K, V]) bool) {
+	rank := 1
+	for current := this.state(this.Head).Levels[0].Forward; current != nil; rank++ {
+		state := this.state(current)
+		if !yield(rank, state.Data) {
+			return
+		}
+		current = state.Levels[0].Forward
+	}
+}
+
+// 按分数从高到低遍历所有数据(产生的是正序排名)
+func (this *SkipList[K, V]) Backward() iter.Seq2[int, *NodeData[K, V]] {
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		it := this.NewIterator()
+		for ok := it.SeekToLast(); ok; ok = it.Prev() {
+			if !yield(it.Rank(), it.Data()) {
+				return
+			}
+		}
+	}
+}
+
+// 遍历指定排名范围的数据
+func (this *SkipList[K, V]) IterRangeByRank(start int, end int) iter.Seq2[int, *NodeData[K, V]] {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		it := this.NewIterator()
+		for ok := it.SeekRank(start); ok && it.Rank() <= end; ok = it.Next() {
+			if !yield(it.Rank(), it.Data()) {
+				return
+			}
+		}
+	}
+}
+
+// 遍历指定分数范围的数据
+func (this *SkipList[K, V]) IterRangeByScore(r *RangeSpecified) iter.Seq2[int, *NodeData[K, V]] {
+	assert.Assert(r != nil, "r range cannot be nil")
+	
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		it := this.NewIterator()
+		for ok := it.seekRange(r); ok && scoreLessThanMax(it.Data().Score, r); ok = it.Next() {
+			if !yield(it.Rank(), it.Data()) {
+				return
+			}
+		}
+	}
+}
+
+// 迭代器在开始遍历时才删除过期的元素，而不是在创建时
+// (创建之后、遍历之前可能又有元素过期，或者跳跃表被替换、编码被转换)
+func (this *SortedSet[K, V]) All() iter.Seq2[int, *NodeData[K, V]] {
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		this.expireDue()
+		this.list().All()(yield)
+	}
+}
+
+func (this *SortedSet[K, V]) Backward() iter.Seq2[int, *NodeData[K, V]] {
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		this.expireDue()
+		this.list().Backward()(yield)
+	}
+}
+
+func (this *SortedSet[K, V]) IterRangeByRank(start int, end int) iter.Seq2[int, *NodeData[K, V]] {
+	if start > end {
+		start, end = end, start
+	}
+	assert.Assert(start > 0 && end > 0, "rank范围不合法, start:", start, " end:", end)
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		this.expireDue()
+		this.list().IterRangeByRank(start, end)(yield)
+	}
+}
+
+func (this *SortedSet[K, V]) IterRangeByScore(min float64, minEx bool, max float64, maxEx bool) iter.Seq2[int, *NodeData[K, V]] {
+	r := &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		this.expireDue()
+		this.list().IterRangeByScore(r)(yield)
+	}
+}
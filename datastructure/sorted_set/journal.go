@@ -0,0 +1,667 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 有序集合的操作日志(参考redis的AOF)
+// 依次追加记录有序集合的修改操作，启动时重放日志即可恢复数据
+// 每条记录的格式(整数均为小端序):
+// | 记录长度(uint32,不包含自身和校验和) | 校验和(crc32,4字节) | 操作类型(1字节) | 操作参数 |
+// 进程崩溃时最后一条记录可能只写入了一部分，重放时会截断这条不完整的记录
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 刷盘策略
+type FsyncPolicy int32
+
+const (
+	FsyncAlways   FsyncPolicy = iota // 0 每次写入都刷盘(最安全，也最慢)
+	FsyncEverySec                    // 1 每秒刷盘一次(崩溃时最多丢失一秒的数据)
+	FsyncNo                          // 2 不主动刷盘，由操作系统决定
+)
+
+// 操作类型
+const (
+	journalOpInsert             = byte(1)
+	journalOpDelete             = byte(2)
+	journalOpUpdateScore        = byte(3)
+	journalOpDeleteRangeByRank  = byte(4)
+	journalOpDeleteRangeByScore = byte(5)
+	journalOpDeleteRangeByValue = byte(6)
+	journalOpExpireAt           = byte(7)
+	journalOpPersist            = byte(8)
+)
+
+const journalRecordHeaderSize = 8
+
+var (
+	ErrJournalClosed   = errors.New("操作日志:已关闭")
+	ErrJournalRecord   = errors.New("操作日志:记录格式不正确")
+	ErrJournalChecksum = errors.New("操作日志:校验和不一致")
+)
+
+type Journal[K comparable, V any] struct {
+	mu     sync.Mutex
+	file   string
+	f      *os.File
+	kc     Codec[K]
+	vc     Codec[V]
+	policy FsyncPolicy
+	err    error         // 写入出错后记录错误，之后的写入都会被忽略
+	dirty  bool          // 是否有尚未刷盘的数据
+	stop   chan struct{} // 通知每秒刷盘的协程退出
+	wg     sync.WaitGroup
+}
+
+// 打开(不存在则创建)操作日志文件
+// 新的记录总是追加到文件末尾
+func OpenJournal[K comparable, V any](file string, kc Codec[K], vc Codec[V], policy FsyncPolicy) (*Journal[K, V], error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal[K, V]{
+		file:   file,
+		f:      f,
+		kc:     kc,
+		vc:     vc,
+		policy: policy,
+		stop:   make(chan struct{}),
+	}
+	if policy == FsyncEverySec {
+		j.wg.Add(1)
+		go j.syncEverySecond()
+	}
+	return j, nil
+}
+
+func (this *Journal[K, V]) syncEverySecond() {
+	defer this.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			this.mu.Lock()
+			this.sync()
+			this.mu.Unlock()
+		case <-this.stop:
+			return
+		}
+	}
+}
+
+// 需要持有锁
+func (this *Journal[K, V]) sync() {
+	if this.f == nil || !this.dirty || this.err != nil {
+		return
+	}
+	this.dirty = false
+	if err := this.f.Sync(); err != nil {
+		this.err = err
+	}
+}
+
+// 写入过程中遇到的第一个错误
+// 出错之后的所有写入都会被忽略，调用者需要及时检查
+func (this *Journal[K, V]) Err() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.err
+}
+
+// 刷盘
+func (this *Journal[K, V]) Sync() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.sync()
+	return this.err
+}
+
+func (this *Journal[K, V]) Close() error {
+	this.mu.Lock()
+	if this.f == nil {
+		this.mu.Unlock()
+		return ErrJournalClosed
+	}
+	this.sync()
+	err := this.f.Close()
+	this.f = nil
+	this.mu.Unlock()
+	
+	close(this.stop)
+	this.wg.Wait()
+	if this.err != nil {
+		return this.err
+	}
+	return err
+}
+
+/*
+	记录的编码
+*/
+
+func appendJournalBytes(buf []byte, p []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(p)))
+	return append(buf, p...)
+}
+
+func appendJournalFloat(buf []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+}
+
+func appendJournalInt64(buf []byte, i int64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, uint64(i))
+}
+
+func appendJournalBool(buf []byte, b bool) []byte {
+	if b {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+// 写入一条记录
+// 整条记录通过一次write写入，避免和其他记录交错
+func (this *Journal[K, V]) write(op byte, payload []byte) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.err != nil {
+		return
+	}
+	if this.f == nil {
+		this.err = ErrJournalClosed
+		return
+	}
+	
+	record := make([]byte, journalRecordHeaderSize, journalRecordHeaderSize+1+len(payload))
+	record = append(record, op)
+	record = append(record, payload...)
+	body := record[journalRecordHeaderSize:]
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(body))
+	if _, err := this.f.Write(record); err != nil {
+		this.err = err
+		return
+	}
+	this.dirty = true
+	if this.policy == FsyncAlways {
+		this.sync()
+	}
+}
+
+func (this *Journal[K, V]) encodeData(buf []byte, data *NodeData[K, V]) ([]byte, error) {
+	buf = appendJournalFloat(buf, data.Score)
+	key, err := this.kc.Encode(data.Key)
+	if err != nil {
+		return nil, err
+	}
+	buf = appendJournalBytes(buf, key)
+	val, err := this.vc.Encode(data.Val)
+	if err != nil {
+		return nil, err
+	}
+	return appendJournalBytes(buf, val), nil
+}
+
+func (this *Journal[K, V]) setErr(err error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.err == nil {
+		this.err = err
+	}
+}
+
+func (this *Journal[K, V]) appendInsert(data *NodeData[K, V]) {
+	buf, err := this.encodeData(nil, data)
+	if err != nil {
+		this.setErr(err)
+		return
+	}
+	this.write(journalOpInsert, buf)
+}
+
+func (this *Journal[K, V]) appendDelete(key K) {
+	k, err := this.kc.Encode(key)
+	if err != nil {
+		this.setErr(err)
+		return
+	}
+	this.write(journalOpDelete, appendJournalBytes(nil, k))
+}
+
+func (this *Journal[K, V]) appendUpdateScore(key K, newScore float64) {
+	k, err := this.kc.Encode(key)
+	if err != nil {
+		this.setErr(err)
+		return
+	}
+	buf := appendJournalBytes(nil, k)
+	this.write(journalOpUpdateScore, appendJournalFloat(buf, newScore))
+}
+
+func (this *Journal[K, V]) appendDeleteRangeByRank(start int, end int) {
+	buf := binary.AppendUvarint(nil, uint64(start))
+	this.write(journalOpDeleteRangeByRank, binary.AppendUvarint(buf, uint64(end)))
+}
+
+func (this *Journal[K, V]) appendDeleteRangeByScore(min float64, minEx bool, max float64, maxEx bool) {
+	buf := appendJournalFloat(nil, min)
+	buf = appendJournalBool(buf, minEx)
+	buf = appendJournalFloat(buf, max)
+	buf = appendJournalBool(buf, maxEx)
+	this.write(journalOpDeleteRangeByScore, buf)
+}
+
+func (this *Journal[K, V]) appendDeleteRangeByValue(r *ValueRangeSpecified[V]) {
+	buf := appendJournalBool(nil, r.MinInf)
+	buf = appendJournalBool(buf, r.MinExclusive)
+	buf = appendJournalBool(buf, r.MaxInf)
+	buf = appendJournalBool(buf, r.MaxExclusive)
+	for _, v := range []V{r.Min, r.Max} {
+		p, err := this.vc.Encode(v)
+		if err != nil {
+			this.setErr(err)
+			return
+		}
+		buf = appendJournalBytes(buf, p)
+	}
+	this.write(journalOpDeleteRangeByValue, buf)
+}
+
+func (this *Journal[K, V]) appendExpireAt(key K, at int64) {
+	k, err := this.kc.Encode(key)
+	if err != nil {
+		this.setErr(err)
+		return
+	}
+	buf := appendJournalBytes(nil, k)
+	this.write(journalOpExpireAt, appendJournalInt64(buf, at))
+}
+
+func (this *Journal[K, V]) appendPersist(key K) {
+	k, err := this.kc.Encode(key)
+	if err != nil {
+		this.setErr(err)
+		return
+	}
+	this.write(journalOpPersist, appendJournalBytes(nil, k))
+}
+
+/*
+	记录的解码和重放
+*/
+
+type journalPayload struct {
+	buf []byte
+	err error
+}
+
+func (this *journalPayload) bytes() []byte {
+	if this.err != nil {
+		return nil
+	}
+	n, l := binary.Uvarint(this.buf)
+	if l <= 0 || uint64(len(this.buf)-l) < n {
+		this.err = ErrJournalRecord
+		return nil
+	}
+	p := this.buf[l : l+int(n)]
+	this.buf = this.buf[l+int(n):]
+	return p
+}
+
+func (this *journalPayload) uvarint() int {
+	if this.err != nil {
+		return 0
+	}
+	n, l := binary.Uvarint(this.buf)
+	if l <= 0 || n > math.MaxInt32 {
+		this.err = ErrJournalRecord
+		return 0
+	}
+	this.buf = this.buf[l:]
+	return int(n)
+}
+
+func (this *journalPayload) float() float64 {
+	if this.err != nil {
+		return 0
+	}
+	if len(this.buf) < 8 {
+		this.err = ErrJournalRecord
+		return 0
+	}
+	f := math.Float64frombits(binary.LittleEndian.Uint64(this.buf))
+	this.buf = this.buf[8:]
+	if math.IsNaN(f) {
+		this.err = ErrJournalRecord
+	}
+	return f
+}
+
+func (this *journalPayload) int64() int64 {
+	if this.err != nil {
+		return 0
+	}
+	if len(this.buf) < 8 {
+		this.err = ErrJournalRecord
+		return 0
+	}
+	i := int64(binary.LittleEndian.Uint64(this.buf))
+	this.buf = this.buf[8:]
+	return i
+}
+
+func (this *journalPayload) bool() bool {
+	if this.err != nil {
+		return false
+	}
+	if len(this.buf) < 1 {
+		this.err = ErrJournalRecord
+		return false
+	}
+	b := this.buf[0] != 0
+	this.buf = this.buf[1:]
+	return b
+}
+
+func (this *Journal[K, V]) decodeKey(p *journalPayload) K {
+	raw := p.bytes()
+	var key K
+	if p.err != nil {
+		return key
+	}
+	key, p.err = this.kc.Decode(raw)
+	return key
+}
+
+func (this *Journal[K, V]) decodeVal(p *journalPayload) V {
+	raw := p.bytes()
+	var val V
+	if p.err != nil {
+		return val
+	}
+	val, p.err = this.vc.Decode(raw)
+	return val
+}
+
+// 在有序集合上执行一条记录
+// 重放时有序集合不能挂载操作日志(避免重复记录)
+func (this *Journal[K, V]) apply(ss *SortedSet[K, V], op byte, p *journalPayload) error {
+	switch op {
+	case journalOpInsert:
+		score := p.float()
+		key := this.decodeKey(p)
+		val := this.decodeVal(p)
+		if p.err != nil {
+			return p.err
+		}
+		ss.Insert(NewNodeData(key, score, val))
+	case journalOpDelete:
+		key := this.decodeKey(p)
+		if p.err != nil {
+			return p.err
+		}
+		ss.Delete(key)
+	case journalOpUpdateScore:
+		key := this.decodeKey(p)
+		score := p.float()
+		if p.err != nil {
+			return p.err
+		}
+		ss.UpdateScore(key, score)
+	case journalOpDeleteRangeByRank:
+		start := p.uvarint()
+		end := p.uvarint()
+		if p.err != nil {
+			return p.err
+		}
+		if start <= 0 || end <= 0 {
+			return ErrJournalRecord
+		}
+		ss.DeleteRangeByRank(start, end)
+	case journalOpDeleteRangeByScore:
+		min := p.float()
+		minEx := p.bool()
+		max := p.float()
+		maxEx := p.bool()
+		if p.err != nil {
+			return p.err
+		}
+		ss.DeleteRangeByScore(min, minEx, max, maxEx)
+	case journalOpDeleteRangeByValue:
+		r := &ValueRangeSpecified[V]{}
+		r.MinInf = p.bool()
+		r.MinExclusive = p.bool()
+		r.MaxInf = p.bool()
+		r.MaxExclusive = p.bool()
+		r.Min = this.decodeVal(p)
+		r.Max = this.decodeVal(p)
+		if p.err != nil {
+			return p.err
+		}
+		ss.DeleteRangeByValue(r)
+	case journalOpExpireAt:
+		key := this.decodeKey(p)
+		at := p.int64()
+		if p.err != nil {
+			return p.err
+		}
+		ss.ExpireAt(key, time.Unix(0, at))
+	case journalOpPersist:
+		key := this.decodeKey(p)
+		if p.err != nil {
+			return p.err
+		}
+		ss.Persist(key)
+	default:
+		return fmt.Errorf("%w: 未知的操作类型 %d", ErrJournalRecord, op)
+	}
+	if len(p.buf) != 0 {
+		return ErrJournalRecord
+	}
+	return nil
+}
+
+// 从头重放操作日志中的所有记录
+// 文件末尾不完整的记录(进程崩溃导致)会被截断；其他位置的记录损坏则返回错误
+// 返回成功重放的记录数量
+// 重放时不做惰性删除:真正过期的元素已经记录为删除，按排名删除的记录需要在和记录时相同的元素上重放
+func (this *Journal[K, V]) Replay(ss *SortedSet[K, V]) (int, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.f == nil {
+		return 0, ErrJournalClosed
+	}
+	if ss.journal != nil {
+		return 0, errors.New("操作日志:重放时有序集合不能挂载操作日志")
+	}
+	manualExpire := ss.manualExpire
+	ss.manualExpire = true
+	defer func() {
+		ss.manualExpire = manualExpire
+	}()
+	
+	if _, err := this.f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(this.f)
+	offset := int64(0)
+	count := 0
+	header := make([]byte, journalRecordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				// 正好读完
+				return count, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return count, this.f.Truncate(offset)
+			}
+			return count, err
+		}
+		length := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+		if length == 0 {
+			return count, fmt.Errorf("%w: offset:%d", ErrJournalRecord, offset)
+		}
+		// 数据可能损坏，不能直接按照记录的长度分配内存
+		buf := &bytes.Buffer{}
+		if _, err := io.CopyN(buf, r, int64(length)); err != nil {
+			if err == io.EOF {
+				// 最后一条记录不完整
+				return count, this.f.Truncate(offset)
+			}
+			return count, err
+		}
+		body := buf.Bytes()
+		if crc32.ChecksumIEEE(body) != checksum {
+			if _, err := r.Peek(1); err == io.EOF {
+				// 最后一条记录写入了一部分
+				return count, this.f.Truncate(offset)
+			}
+			return count, fmt.Errorf("%w: offset:%d", ErrJournalChecksum, offset)
+		}
+		if err := this.apply(ss, body[0], &journalPayload{buf: body[1:]}); err != nil {
+			return count, fmt.Errorf("offset:%d %w", offset, err)
+		}
+		offset += int64(journalRecordHeaderSize) + int64(length)
+		count++
+	}
+}
+
+// 重写(压缩)操作日志
+// 根据有序集合当前的数据生成新的日志(每个元素一条插入记录，每个过期时间一条记录)，替换原有的日志文件
+func (this *Journal[K, V]) Rewrite(ss *SortedSet[K, V]) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.f == nil {
+		return ErrJournalClosed
+	}
+	if this.err != nil {
+		return this.err
+	}
+	
+	tmpFile := this.file + ".rewrite"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	writeRecord := func(op byte, payload []byte) error {
+		body := append([]byte{op}, payload...)
+		header := binary.LittleEndian.AppendUint32(nil, uint32(len(body)))
+		header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(body))
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		_, err := w.Write(body)
+		return err
+	}
+	ss.list().walk(func(_ int, data *NodeData[K, V]) bool {
+		var payload []byte
+		if payload, err = this.encodeData(nil, data); err != nil {
+			return false
+		}
+		err = writeRecord(journalOpInsert, payload)
+		return err == nil
+	})
+	// 元素的过期时间(每个一条记录)
+	if ss.expire != nil {
+		for key, at := range ss.expire.expireAt {
+			if err != nil {
+				break
+			}
+			var k []byte
+			k, err = this.kc.Encode(key)
+			if err != nil {
+				break
+			}
+			err = writeRecord(journalOpExpireAt, appendJournalInt64(appendJournalBytes(nil, k), at))
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	
+	// 用新的日志文件替换旧的
+	if err = os.Rename(tmpFile, this.file); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	newF, err := os.OpenFile(this.file, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		this.err = err
+		return err
+	}
+	this.f.Close()
+	this.f = newF
+	this.dirty = false
+	// 重命名也要刷盘，否则崩溃后目录中可能仍然是旧的日志文件
+	return syncDir(filepath.Dir(this.file))
+}
+
+// 刷盘目录(让目录中的创建、重命名等操作落盘)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/*
+	有序集合挂载操作日志
+*/
+
+// 挂载操作日志后，有序集合的所有修改操作都会被记录
+// 传入nil表示卸载
+func (this *SortedSet[K, V]) AttachJournal(j *Journal[K, V]) {
+	this.journal = j
+}
+
+func (this *SortedSet[K, V]) Journal() *Journal[K, V] {
+	return this.journal
+}
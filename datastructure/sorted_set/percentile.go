@@ -0,0 +1,154 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 百分位和分桶
+// 百分位的范围是[0,100]，按照最近排名法(nearest-rank)换算成排名：rank = ceil(p/100*n)，最小为1
+// 例如"前1%"就是逆序排名不超过ceil(1%*n)的元素
+// 分桶：按排名把有序集合分成若干个大小相同(最多相差1)的桶
+// 都是基于排名实现的，每次查询O(logn)
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"math"
+)
+
+// 百分位换算成排名(最近排名法)
+// 减去一个很小的数，抵消浮点数的误差(保证GetPercentile得到的百分位可以换算回原来的排名)
+func percentileToRank(p float64, length int) int {
+	assert.Assert(p >= 0 && p <= 100, "百分位的范围是[0,100]:", p)
+	rank := int(math.Ceil(p*float64(length)/100 - 1e-9))
+	return min(max(rank, 1), length)
+}
+
+// 获取指定百分位的数据(按分数从低到高)
+// p为50时就是中位数,有序集合为空时返回nil
+func (this *SortedSet[K, V]) GetByPercentile(p float64) *NodeData[K, V] {
+	this.expireDue()
+	if this.length() == 0 {
+		return nil
+	}
+	return this.list().dataByRank(percentileToRank(p, this.length()))
+}
+
+// 获取指定逆序百分位的数据(按分数从高到低)
+// 即"前p%"中分数最低的数据,有序集合为空时返回nil
+func (this *SortedSet[K, V]) GetByRevPercentile(p float64) *NodeData[K, V] {
+	this.expireDue()
+	if this.length() == 0 {
+		return nil
+	}
+	rank := percentileToRank(p, this.length())
+	return this.list().dataByRank(this.length() - rank + 1)
+}
+
+// 获取key的百分位(排名不超过它的数据所占的百分比,范围(0,100])
+// key不存在时返回false
+func (this *SortedSet[K, V]) GetPercentile(key K) (float64, bool) {
+	rank := this.GetRank(key)
+	if rank == 0 {
+		return 0, false
+	}
+	return float64(rank) / float64(this.length()) * 100, true
+}
+
+// 获取key的逆序百分位(即key处于"前百分之多少",范围(0,100])
+// key不存在时返回false
+func (this *SortedSet[K, V]) GetRevPercentile(key K) (float64, bool) {
+	rank := this.GetRevRank(key)
+	if rank == 0 {
+		return 0, false
+	}
+	return float64(rank) / float64(this.length()) * 100, true
+}
+
+// 指定分位数(范围[0,1])的分数
+// 分位数落在两个排名之间时，按照两者的分数线性插值
+// 有序集合为空时返回false
+func (this *SortedSet[K, V]) ScoreQuantile(q float64) (float64, bool) {
+	assert.Assert(q >= 0 && q <= 1, "分位数的范围是[0,1]:", q)
+	this.expireDue()
+	if this.length() == 0 {
+		return 0, false
+	}
+	h := q * float64(this.length()-1)
+	lower := int(math.Floor(h))
+	score := this.list().dataByRank(lower + 1).Score
+	if frac := h - float64(lower); frac > 0 {
+		// 分数相同时不需要插值(避免无穷大相减得到NaN)
+		if next := this.list().dataByRank(lower + 2).Score; next != score {
+			score += frac * (next - score)
+		}
+	}
+	return score, true
+}
+
+// 分桶
+type Bucket[K comparable, V any] struct {
+	Index int             // 桶的下标(从0开始，分数最低的桶下标为0)
+	Start int             // 桶中第一个数据的排名
+	End   int             // 桶中最后一个数据的排名
+	First *NodeData[K, V] // 桶中分数最低的数据
+	Last  *NodeData[K, V] // 桶中分数最高的数据
+}
+
+// 前rem个桶的大小是size+1,其余桶的大小是size
+func bucketSize(length int, n int) (int, int) {
+	return length / n, length % n
+}
+
+// 按排名把有序集合分成n个大小相同(最多相差1，排名靠前的桶更大)的桶
+// 元素数量少于n时，只返回非空的桶
+func (this *SortedSet[K, V]) Buckets(n int) []*Bucket[K, V] {
+	assert.Assert(n > 0, "n must be positive number")
+	this.expireDue()
+	size, rem := bucketSize(this.length(), n)
+	buckets := make([]*Bucket[K, V], 0, min(n, this.length()))
+	start := 1
+	for i := 0; i < n && start <= this.length(); i++ {
+		end := start + size - 1
+		if i < rem {
+			end++
+		}
+		buckets = append(buckets, &Bucket[K, V]{
+			Index: i,
+			Start: start,
+			End:   end,
+			First: this.list().dataByRank(start),
+			Last:  this.list().dataByRank(end),
+		})
+		start = end + 1
+	}
+	return buckets
+}
+
+// key所在的桶的下标(分成n个桶，和Buckets的分法一致)
+// key不存在时返回-1
+func (this *SortedSet[K, V]) BucketOf(key K, n int) int {
+	assert.Assert(n > 0, "n must be positive number")
+	rank := this.GetRank(key)
+	if rank == 0 {
+		return -1
+	}
+	size, rem := bucketSize(this.length(), n)
+	bigger := rem * (size + 1)
+	if rank <= bigger {
+		return (rank - 1) / (size + 1)
+	}
+	return rem + (rank-1-bigger)/size
+}
@@ -0,0 +1,184 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 随机获取有序集合中的元素(参考redis的ZRANDMEMBER)
+// 1.等概率:利用跳跃表的跨度按排名定位，每次选择O(logn)
+// 2.按分数加权:分数就是权重，利用probability_distribution中的方法选择
+// 可以限定排名范围或者分数范围，可以选择是否允许重复
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/algorithm/math_tools/probability_distribution"
+	random2 "github.com/stormYuanYang/yytools/algorithm/math_tools/random"
+	"github.com/stormYuanYang/yytools/common/assert"
+	"math"
+)
+
+// 随机选择的方式
+type RandMode int32
+
+const (
+	RandUniform RandMode = iota // 0 等概率
+	RandByScore                 // 1 按分数加权(分数不能为负数，也不能是无穷大)
+)
+
+// 按分数加权时，分数换算成整数权重的精度(最大分数对应的权重)
+const randWeightScale = 1 << 30
+
+// 随机获取count个元素
+// 没有可选的元素时返回空切片(不是nil)
+// repeat为true时可能重复，返回的数量就是count
+// repeat为false时不会重复，元素数量不足时返回所有元素(顺序是随机的)
+// 按分数加权时，分数为0的元素不会被选中
+func (this *SortedSet[K, V]) RandMember(count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	assert.Assert(count > 0, "count must be positive number")
+	this.expireDue()
+	return this.randInRankRange(1, this.length(), count, repeat, mode)
+}
+
+// 在指定排名范围内随机获取count个元素
+func (this *SortedSet[K, V]) RandMemberByRank(start int, end int, count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	assert.Assert(start > 0 && end > 0, "rank must be positive number, start:", start, " end:", end)
+	assert.Assert(count > 0, "count must be positive number")
+	this.expireDue()
+	if start > end {
+		start, end = end, start
+	}
+	return this.randInRankRange(start, min(end, this.length()), count, repeat, mode)
+}
+
+// 在指定分数范围(开闭区间由调用者指定)内随机获取count个元素
+// 先找到分数范围对应的排名范围(O(logn))，再按排名范围选择
+func (this *SortedSet[K, V]) RandMemberByScore(min float64, minEx bool, max float64, maxEx bool, count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	assert.Assert(count > 0, "count must be positive number")
+	this.expireDue()
+	r := &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+	start, end := this.list().rankRangeByScore(r)
+	if start == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.randInRankRange(start, end, count, repeat, mode)
+}
+
+func (this *SortedSet[K, V]) randInRankRange(start int, end int, count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	if start > end {
+		return []*NodeData[K, V]{}
+	}
+	switch mode {
+	case RandUniform:
+		return this.randUniform(start, end, count, repeat)
+	case RandByScore:
+		return this.randByScore(start, end, count, repeat)
+	default:
+		panic("unsupported rand mode")
+	}
+}
+
+// 等概率随机
+// 时间复杂度O(count*logn)
+func (this *SortedSet[K, V]) randUniform(start int, end int, count int, repeat bool) []*NodeData[K, V] {
+	if repeat {
+		datas := make([]*NodeData[K, V], 0, count)
+		for i := 0; i < count; i++ {
+			datas = append(datas, this.list().dataByRank(random2.RandInt(start, end)))
+		}
+		return datas
+	}
+	
+	// Floyd的算法:不重复地随机选择k个排名，每个排名组合的概率相同
+	size := end - start + 1
+	k := min(count, size)
+	chosen := make(map[int]bool, k)
+	ranks := make([]int, 0, k)
+	for j := size - k + 1; j <= size; j++ {
+		t := random2.RandInt(1, j)
+		if chosen[t] {
+			t = j
+		}
+		chosen[t] = true
+		ranks = append(ranks, start+t-1)
+	}
+	// 选择的顺序不是均匀的，再打乱一次
+	shuffle(ranks)
+	datas := make([]*NodeData[K, V], 0, k)
+	for _, rank := range ranks {
+		datas = append(datas, this.list().dataByRank(rank))
+	}
+	return datas
+}
+
+// 按分数加权随机
+// 需要遍历排名范围内的所有元素计算权重，时间复杂度O(m)(m是范围内的元素数量)
+// 不重复时每次选择之后都要移除被选中元素的权重，时间复杂度O(m*count)
+func (this *SortedSet[K, V]) randByScore(start int, end int, count int, repeat bool) []*NodeData[K, V] {
+	members := make([]*NodeData[K, V], 0, end-start+1)
+	maxScore := 0.0
+	for _, data := range this.list().GetRangeByRank(start, end) {
+		score := data.Score
+		assert.Assert(score >= 0 && !math.IsInf(score, 1), "按分数加权时分数不能为负数或者无穷大:", score)
+		members = append(members, data)
+		maxScore = math.Max(maxScore, score)
+	}
+	if maxScore == 0 {
+		// 所有元素的权重都是0
+		return []*NodeData[K, V]{}
+	}
+	
+	// 分数换算成整数权重(按最大分数等比例缩放，正数分数的权重至少为1)
+	weights := make([]int64, len(members))
+	totalWeight := int64(0)
+	for i, data := range members {
+		weights[i] = int64(math.Ceil(data.Score / maxScore * randWeightScale))
+		totalWeight += weights[i]
+	}
+	
+	if repeat {
+		// 多次选择，别名方法每次选择都是O(1)
+		method := probability_distribution.ProbFactory(probability_distribution.VoseAlias, weights)
+		datas := make([]*NodeData[K, V], 0, count)
+		for i := 0; i < count; i++ {
+			datas = append(datas, members[method.Generate()])
+		}
+		return datas
+	}
+	
+	datas := make([]*NodeData[K, V], 0, min(count, len(members)))
+	for len(datas) < count && totalWeight > 0 {
+		index := probability_distribution.CalcIndexByWeight(weights, totalWeight)
+		datas = append(datas, members[index])
+		// 被选中的元素不能再次被选中
+		totalWeight -= weights[index]
+		weights[index] = 0
+	}
+	return datas
+}
+
+// 随机打乱(Fisher-Yates)
+func shuffle(list []int) {
+	for i := len(list) - 1; i > 0; i-- {
+		j := random2.RandInt(0, i)
+		list[i], list[j] = list[j], list[i]
+	}
+}
@@ -0,0 +1,1034 @@
+// Package resp_server.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 命令的实现
+// 参数、回复和错误信息尽量和redis保持一致
+// 排名从0开始(负数表示从末尾开始)，分数范围用"("表示开区间，字典序范围用"[" "(" "-" "+"表示
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package resp_server
+
+import (
+	"fmt"
+	"github.com/stormYuanYang/yytools/datastructure/sorted_set"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	errSyntax       = "ERR syntax error"
+	errNotInteger   = "ERR value is not an integer or out of range"
+	errOutOfRange   = "ERR value is out of range"
+	errNotFloat     = "ERR value is not a valid float"
+	errMinMaxFloat  = "ERR min or max is not a float"
+	errMinMaxString = "ERR min or max not valid string range item"
+	errNaN          = "ERR resulting score is not a number (NaN)"
+)
+
+const maxRandMemberCount = maxArrayLen // ZRANDMEMBER允许重复时最多返回的成员数量
+
+type command struct {
+	name    string
+	arity   int // 参数数量(包含命令名)，负数表示至少需要-arity个参数
+	handler func(this *Server, c *client, args []string)
+}
+
+var commandTable = map[string]*command{}
+
+func init() {
+	for _, cmd := range []*command{
+		// 连接和服务器相关的命令
+		{"ping", -1, (*Server).pingCommand},
+		{"echo", 2, (*Server).echoCommand},
+		{"hello", -1, (*Server).helloCommand},
+		{"select", 2, (*Server).selectCommand},
+		{"quit", 1, (*Server).quitCommand},
+		{"command", -1, (*Server).commandCommand},
+		{"client", -2, (*Server).clientCommand},
+		// 键相关的命令
+		{"del", -2, (*Server).delCommand},
+		{"exists", -2, (*Server).existsCommand},
+		{"type", 2, (*Server).typeCommand},
+		{"keys", 2, (*Server).keysCommand},
+		{"dbsize", 1, (*Server).dbsizeCommand},
+		{"flushdb", -1, (*Server).flushdbCommand},
+		{"flushall", -1, (*Server).flushdbCommand},
+		// 有序集合相关的命令
+		{"zadd", -4, (*Server).zaddCommand},
+		{"zincrby", 4, (*Server).zincrbyCommand},
+		{"zrem", -3, (*Server).zremCommand},
+		{"zscore", 3, (*Server).zscoreCommand},
+		{"zmscore", -3, (*Server).zmscoreCommand},
+		{"zcard", 2, (*Server).zcardCommand},
+		{"zcount", 4, (*Server).zcountCommand},
+		{"zlexcount", 4, (*Server).zlexcountCommand},
+		{"zrank", -3, (*Server).zrankCommand},
+		{"zrevrank", -3, (*Server).zrevrankCommand},
+		{"zrange", -4, (*Server).zrangeCommand},
+		{"zrevrange", -4, (*Server).zrevrangeCommand},
+		{"zrangebyscore", -4, (*Server).zrangebyscoreCommand},
+		{"zrevrangebyscore", -4, (*Server).zrevrangebyscoreCommand},
+		{"zrangebylex", -4, (*Server).zrangebylexCommand},
+		{"zrevrangebylex", -4, (*Server).zrevrangebylexCommand},
+		{"zremrangebyrank", 4, (*Server).zremrangebyrankCommand},
+		{"zremrangebyscore", 4, (*Server).zremrangebyscoreCommand},
+		{"zremrangebylex", 4, (*Server).zremrangebylexCommand},
+		{"zpopmin", -2, (*Server).zpopminCommand},
+		{"zpopmax", -2, (*Server).zpopmaxCommand},
+		{"zrandmember", -2, (*Server).zrandmemberCommand},
+	} {
+		commandTable[cmd.name] = cmd
+	}
+}
+
+// 执行一条命令(持有锁，命令之间互不干扰)
+func (this *Server) execute(c *client, args []string) {
+	name := strings.ToLower(args[0])
+	cmd, ok := commandTable[name]
+	if !ok {
+		c.w.WriteError(fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s",
+			args[0], quoteArgs(args[1:])))
+		return
+	}
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		c.w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	cmd.handler(this, c, args)
+}
+
+func quoteArgs(args []string) string {
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString("'")
+		b.WriteString(arg)
+		b.WriteString("' ")
+	}
+	return b.String()
+}
+
+/*
+	参数解析
+*/
+
+func parseInt(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// 解析分数(支持inf、+inf、-inf，不能是NaN)
+func parseScore(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// 解析分数范围的一端，"("开头表示开区间
+func parseScoreBound(s string) (float64, bool, bool) {
+	exclusive := false
+	if strings.HasPrefix(s, "(") {
+		exclusive = true
+		s = s[1:]
+	}
+	f, ok := parseScore(s)
+	return f, exclusive, ok
+}
+
+// 解析分数范围
+func parseScoreRange(minArg string, maxArg string) (*sorted_set.RangeSpecified, bool) {
+	min, minEx, ok1 := parseScoreBound(minArg)
+	max, maxEx, ok2 := parseScoreBound(maxArg)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	return &sorted_set.RangeSpecified{
+		RangeSpecifiedBase: sorted_set.RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}, true
+}
+
+// 解析字典序范围:"["表示闭区间，"("表示开区间，"-"表示负无穷，"+"表示正无穷
+// 返回的empty表示范围一定是空的(比如最小值是"+")
+func parseLexRange(minArg string, maxArg string) (r *sorted_set.ValueRangeSpecified[string], empty bool, ok bool) {
+	valid := func(s string) bool {
+		return s == "-" || s == "+" || len(s) > 0 && (s[0] == '[' || s[0] == '(')
+	}
+	if !valid(minArg) || !valid(maxArg) {
+		return nil, false, false
+	}
+	if minArg == "+" || maxArg == "-" {
+		return nil, true, true
+	}
+	r = sorted_set.NewValueRange("", false, "", false)
+	if minArg == "-" {
+		r.MinInf = true
+	} else {
+		r.Min, r.MinExclusive = minArg[1:], minArg[0] == '('
+	}
+	if maxArg == "+" {
+		r.MaxInf = true
+	} else {
+		r.Max, r.MaxExclusive = maxArg[1:], maxArg[0] == '('
+	}
+	return r, false, true
+}
+
+// redis的排名范围(从0开始，负数表示从末尾开始)转换成有序集合的排名范围(从1开始)
+// 范围内没有元素时返回false
+func rankRange(start int, end int, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > end || start >= length {
+		return 0, 0, false
+	}
+	if end >= length {
+		end = length - 1
+	}
+	return start + 1, end + 1, true
+}
+
+/*
+	回复
+*/
+
+// 回复成员列表，withScores时带上分数
+// RESP3中每个成员和分数组成一个数组，RESP2中成员和分数交替排列
+func (this *client) replyMembers(datas []*sorted_set.NodeData[string, string], withScores bool) {
+	w := this.w
+	if !withScores {
+		w.WriteArray(len(datas))
+		for _, data := range datas {
+			w.WriteBulk(data.Key)
+		}
+		return
+	}
+	if w.proto >= 3 {
+		w.WriteArray(len(datas))
+		for _, data := range datas {
+			w.WriteArray(2)
+			w.WriteBulk(data.Key)
+			w.WriteDouble(data.Score)
+		}
+		return
+	}
+	w.WriteArray(len(datas) * 2)
+	for _, data := range datas {
+		w.WriteBulk(data.Key)
+		w.WriteDouble(data.Score)
+	}
+}
+
+/*
+	连接和服务器相关的命令
+*/
+
+func (this *Server) pingCommand(c *client, args []string) {
+	if len(args) > 2 {
+		c.w.WriteError("ERR wrong number of arguments for 'ping' command")
+	} else if len(args) == 2 {
+		c.w.WriteBulk(args[1])
+	} else {
+		c.w.WriteSimple("PONG")
+	}
+}
+
+func (this *Server) echoCommand(c *client, args []string) {
+	c.w.WriteBulk(args[1])
+}
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+// 协商协议版本，不需要认证(接受任意的用户名和密码)
+func (this *Server) helloCommand(c *client, args []string) {
+	proto := c.w.proto
+	if len(args) > 1 {
+		ver, ok := parseInt(args[1])
+		if !ok {
+			c.w.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if ver != 2 && ver != 3 {
+			c.w.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = ver
+	}
+	name := c.name
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToLower(args[i]); {
+		case opt == "auth" && i+2 < len(args):
+			i += 2
+		case opt == "setname" && i+1 < len(args):
+			name = args[i+1]
+			i++
+		default:
+			c.w.WriteError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i]))
+			return
+		}
+	}
+	c.w.proto, c.name = proto, name
+	c.w.WriteMap(7)
+	c.w.WriteBulk("server")
+	c.w.WriteBulk("redis")
+	c.w.WriteBulk("version")
+	c.w.WriteBulk("7.0.0")
+	c.w.WriteBulk("proto")
+	c.w.WriteInt(int64(proto))
+	c.w.WriteBulk("id")
+	c.w.WriteInt(1)
+	c.w.WriteBulk("mode")
+	c.w.WriteBulk("standalone")
+	c.w.WriteBulk("role")
+	c.w.WriteBulk("master")
+	c.w.WriteBulk("modules")
+	c.w.WriteArray(0)
+}
+
+// 只有一个数据库
+func (this *Server) selectCommand(c *client, args []string) {
+	index, ok := parseInt(args[1])
+	if !ok {
+		c.w.WriteError(errNotInteger)
+	} else if index != 0 {
+		c.w.WriteError("ERR DB index is out of range")
+	} else {
+		c.w.WriteSimple("OK")
+	}
+}
+
+func (this *Server) quitCommand(c *client, args []string) {
+	c.w.WriteSimple("OK")
+	c.quit = true
+}
+
+// redis-cli连接时会获取命令的文档，回复空的结果即可
+func (this *Server) commandCommand(c *client, args []string) {
+	c.w.WriteArray(0)
+}
+
+// 客户端库连接时常用的几个子命令
+func (this *Server) clientCommand(c *client, args []string) {
+	switch strings.ToLower(args[1]) {
+	case "setname":
+		if len(args) != 3 {
+			c.w.WriteError(errSyntax)
+			return
+		}
+		c.name = args[2]
+		c.w.WriteSimple("OK")
+	case "getname":
+		if c.name == "" {
+			c.w.WriteNull()
+		} else {
+			c.w.WriteBulk(c.name)
+		}
+	case "setinfo":
+		c.w.WriteSimple("OK")
+	case "id":
+		c.w.WriteInt(1)
+	default:
+		c.w.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'.", args[1]))
+	}
+}
+
+/*
+	键相关的命令
+*/
+
+func (this *Server) delCommand(c *client, args []string) {
+	deleted := 0
+	for _, key := range args[1:] {
+		if _, ok := this.db[key]; ok {
+			delete(this.db, key)
+			deleted++
+		}
+	}
+	c.w.WriteInt(int64(deleted))
+}
+
+func (this *Server) existsCommand(c *client, args []string) {
+	count := 0
+	for _, key := range args[1:] {
+		if _, ok := this.db[key]; ok {
+			count++
+		}
+	}
+	c.w.WriteInt(int64(count))
+}
+
+func (this *Server) typeCommand(c *client, args []string) {
+	if _, ok := this.db[args[1]]; ok {
+		c.w.WriteSimple("zset")
+	} else {
+		c.w.WriteSimple("none")
+	}
+}
+
+func (this *Server) keysCommand(c *client, args []string) {
+	keys := make([]string, 0, 4)
+	for key := range this.db {
+		if globMatch(args[1], key) {
+			keys = append(keys, key)
+		}
+	}
+	c.w.WriteArray(len(keys))
+	for _, key := range keys {
+		c.w.WriteBulk(key)
+	}
+}
+
+// redis风格的通配符匹配:*任意多个字符，?任意一个字符，[abc]、[^a]、[a-z]字符集合，\转义
+func globMatch(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// 没有结束的']'，当作普通字符
+				if s[0] != '[' {
+					return false
+				}
+				s, pattern = s[1:], pattern[1:]
+				continue
+			}
+			set := pattern[1 : end+1]
+			not := len(set) > 0 && set[0] == '^'
+			if not {
+				set = set[1:]
+			}
+			match := false
+			for i := 0; i < len(set); i++ {
+				if i+2 < len(set) && set[i+1] == '-' {
+					if set[i] <= s[0] && s[0] <= set[i+2] || set[i+2] <= s[0] && s[0] <= set[i] {
+						match = true
+					}
+					i += 2
+				} else if set[i] == s[0] {
+					match = true
+				}
+			}
+			if match == not {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[end+2:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+func (this *Server) dbsizeCommand(c *client, args []string) {
+	c.w.WriteInt(int64(len(this.db)))
+}
+
+// FLUSHDB/FLUSHALL [ASYNC|SYNC]
+func (this *Server) flushdbCommand(c *client, args []string) {
+	if len(args) > 2 || len(args) == 2 && !strings.EqualFold(args[1], "async") && !strings.EqualFold(args[1], "sync") {
+		c.w.WriteError(errSyntax)
+		return
+	}
+	this.db = map[string]*ZSet{}
+	c.w.WriteSimple("OK")
+}
+
+/*
+	有序集合的增删改
+*/
+
+// ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func (this *Server) zaddCommand(c *client, args []string) {
+	var flags sorted_set.AddFlag
+	incr := false
+	i := 2
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			flags |= sorted_set.AddNX
+		case "xx":
+			flags |= sorted_set.AddXX
+		case "gt":
+			flags |= sorted_set.AddGT
+		case "lt":
+			flags |= sorted_set.AddLT
+		case "ch":
+			flags |= sorted_set.AddCH
+		case "incr":
+			incr = true
+		default:
+			break options
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		c.w.WriteError(errSyntax)
+		return
+	}
+	if flags&sorted_set.AddNX != 0 && flags&sorted_set.AddXX != 0 {
+		c.w.WriteError("ERR XX and NX options at the same time are not compatible")
+		return
+	}
+	if flags&sorted_set.AddGT != 0 && flags&sorted_set.AddLT != 0 ||
+		flags&sorted_set.AddNX != 0 && flags&(sorted_set.AddGT|sorted_set.AddLT) != 0 {
+		c.w.WriteError("ERR GT, LT, and/or NX options at the same time are not compatible")
+		return
+	}
+	if incr && len(pairs) > 2 {
+		c.w.WriteError("ERR INCR option supports a single increment-element pair")
+		return
+	}
+	// 先解析所有的分数，保证出错时不会修改有序集合
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseScore(pairs[j])
+		if !ok {
+			c.w.WriteError(errNotFloat)
+			return
+		}
+		scores = append(scores, score)
+	}
+	
+	key := args[1]
+	ss := this.db[key]
+	if ss == nil {
+		if flags&sorted_set.AddXX != 0 {
+			// 只更新已存在的元素，有序集合不存在时什么都不用做
+			if incr {
+				c.w.WriteNull()
+			} else {
+				c.w.WriteInt(0)
+			}
+			return
+		}
+		ss = newZSet()
+	}
+	if incr {
+		this.zincr(c, key, ss, pairs[1], scores[0], flags)
+		return
+	}
+	count := 0
+	for j, score := range scores {
+		member := pairs[j*2+1]
+		if ss.Add(sorted_set.NewNodeData(member, score, member), flags) {
+			count++
+		}
+	}
+	this.setKey(key, ss)
+	c.w.WriteInt(int64(count))
+}
+
+// 增加成员的分数(ZINCRBY和ZADD INCR)，选项不允许修改时回复空值
+func (this *Server) zincr(c *client, key string, ss *ZSet, member string, delta float64, flags sorted_set.AddFlag) {
+	old := ss.Get(member)
+	if old == nil && flags&sorted_set.AddXX != 0 || old != nil && flags&sorted_set.AddNX != 0 {
+		c.w.WriteNull()
+		return
+	}
+	newScore := delta
+	if old != nil {
+		newScore += old.Score
+	}
+	if math.IsNaN(newScore) {
+		c.w.WriteError(errNaN)
+		return
+	}
+	if old != nil && (flags&sorted_set.AddGT != 0 && newScore <= old.Score ||
+		flags&sorted_set.AddLT != 0 && newScore >= old.Score) {
+		c.w.WriteNull()
+		return
+	}
+	score, _, ok := ss.IncrScore(member, delta, member)
+	if !ok {
+		c.w.WriteError(errNaN)
+		return
+	}
+	this.setKey(key, ss)
+	c.w.WriteDouble(score)
+}
+
+// ZINCRBY key increment member
+func (this *Server) zincrbyCommand(c *client, args []string) {
+	delta, ok := parseScore(args[2])
+	if !ok {
+		c.w.WriteError(errNotFloat)
+		return
+	}
+	ss := this.db[args[1]]
+	if ss == nil {
+		ss = newZSet()
+	}
+	this.zincr(c, args[1], ss, args[3], delta, 0)
+}
+
+// ZREM key member [member ...]
+func (this *Server) zremCommand(c *client, args []string) {
+	ss := this.db[args[1]]
+	if ss == nil {
+		c.w.WriteInt(0)
+		return
+	}
+	deleted := 0
+	for _, member := range args[2:] {
+		if _, ok := ss.Delete(member); ok {
+			deleted++
+		}
+	}
+	this.setKey(args[1], ss)
+	c.w.WriteInt(int64(deleted))
+}
+
+// ZREMRANGEBYRANK key start stop
+func (this *Server) zremrangebyrankCommand(c *client, args []string) {
+	start, ok1 := parseInt(args[2])
+	end, ok2 := parseInt(args[3])
+	if !ok1 || !ok2 {
+		c.w.WriteError(errNotInteger)
+		return
+	}
+	ss := this.db[args[1]]
+	if ss == nil {
+		c.w.WriteInt(0)
+		return
+	}
+	start, end, ok := rankRange(start, end, ss.Length())
+	if !ok {
+		c.w.WriteInt(0)
+		return
+	}
+	deleted := ss.DeleteRangeByRank(start, end)
+	this.setKey(args[1], ss)
+	c.w.WriteInt(int64(len(deleted)))
+}
+
+// ZREMRANGEBYSCORE key min max
+func (this *Server) zremrangebyscoreCommand(c *client, args []string) {
+	r, ok := parseScoreRange(args[2], args[3])
+	if !ok {
+		c.w.WriteError(errMinMaxFloat)
+		return
+	}
+	ss := this.db[args[1]]
+	if ss == nil {
+		c.w.WriteInt(0)
+		return
+	}
+	deleted := ss.DeleteRangeByScore(r.Min, r.MinExclusive, r.Max, r.MaxExclusive)
+	this.setKey(args[1], ss)
+	c.w.WriteInt(int64(len(deleted)))
+}
+
+// ZREMRANGEBYLEX key min max
+func (this *Server) zremrangebylexCommand(c *client, args []string) {
+	r, empty, ok := parseLexRange(args[2], args[3])
+	if !ok {
+		c.w.WriteError(errMinMaxString)
+		return
+	}
+	ss := this.db[args[1]]
+	if ss == nil || empty {
+		c.w.WriteInt(0)
+		return
+	}
+	deleted := ss.DeleteRangeByValue(r)
+	this.setKey(args[1], ss)
+	c.w.WriteInt(int64(len(deleted)))
+}
+
+// ZPOPMIN/ZPOPMAX key [count]
+// 没有指定count时回复一个成员和分数，指定count时回复成员列表
+func (this *Server) zpop(c *client, args []string, max bool) {
+	count := 1
+	if len(args) > 3 {
+		c.w.WriteError(errSyntax)
+		return
+	}
+	if len(args) == 3 {
+		n, ok := parseInt(args[2])
+		if !ok || n < 0 {
+			c.w.WriteError("ERR value is out of range, must be positive")
+			return
+		}
+		count = n
+	}
+	ss := this.db[args[1]]
+	datas := []*sorted_set.NodeData[string, string]{}
+	if ss != nil && count > 0 {
+		if max {
+			datas = ss.PopMax(count)
+		} else {
+			datas = ss.PopMin(count)
+		}
+		this.setKey(args[1], ss)
+	}
+	if len(args) == 2 {
+		// 单个成员的回复在两个协议版本中都是扁平的数组
+		c.w.WriteArray(len(datas) * 2)
+		for _, data := range datas {
+			c.w.WriteBulk(data.Key)
+			c.w.WriteDouble(data.Score)
+		}
+		return
+	}
+	c.replyMembers(datas, true)
+}
+
+func (this *Server) zpopminCommand(c *client, args []string) {
+	this.zpop(c, args, false)
+}
+
+func (this *Server) zpopmaxCommand(c *client, args []string) {
+	this.zpop(c, args, true)
+}
+
+/*
+	有序集合的查询
+*/
+
+// ZSCORE key member
+func (this *Server) zscoreCommand(c *client, args []string) {
+	ss := this.db[args[1]]
+	if ss == nil {
+		c.w.WriteNull()
+		return
+	}
+	if data := ss.Get(args[2]); data != nil {
+		c.w.WriteDouble(data.Score)
+	} else {
+		c.w.WriteNull()
+	}
+}
+
+// ZMSCORE key member [member ...]
+func (this *Server) zmscoreCommand(c *client, args []string) {
+	ss := this.db[args[1]]
+	c.w.WriteArray(len(args) - 2)
+	for _, member := range args[2:] {
+		if ss == nil {
+			c.w.WriteNull()
+		} else if data := ss.Get(member); data != nil {
+			c.w.WriteDouble(data.Score)
+		} else {
+			c.w.WriteNull()
+		}
+	}
+}
+
+// ZCARD key
+func (this *Server) zcardCommand(c *client, args []string) {
+	if ss := this.db[args[1]]; ss != nil {
+		c.w.WriteInt(int64(ss.Length()))
+	} else {
+		c.w.WriteInt(0)
+	}
+}
+
+// ZCOUNT key min max
+func (this *Server) zcountCommand(c *client, args []string) {
+	r, ok := parseScoreRange(args[2], args[3])
+	if !ok {
+		c.w.WriteError(errMinMaxFloat)
+		return
+	}
+	if ss := this.db[args[1]]; ss != nil {
+		c.w.WriteInt(int64(ss.CountByScore(r.Min, r.MinExclusive, r.Max, r.MaxExclusive)))
+	} else {
+		c.w.WriteInt(0)
+	}
+}
+
+// ZLEXCOUNT key min max
+func (this *Server) zlexcountCommand(c *client, args []string) {
+	r, empty, ok := parseLexRange(args[2], args[3])
+	if !ok {
+		c.w.WriteError(errMinMaxString)
+		return
+	}
+	if ss := this.db[args[1]]; ss != nil && !empty {
+		c.w.WriteInt(int64(ss.CountByValue(r)))
+	} else {
+		c.w.WriteInt(0)
+	}
+}
+
+// ZRANK/ZREVRANK key member [WITHSCORE]
+func (this *Server) zrank(c *client, args []string, rev bool) {
+	withScore := false
+	if len(args) > 4 || len(args) == 4 && !strings.EqualFold(args[3], "withscore") {
+		c.w.WriteError(errSyntax)
+		return
+	} else if len(args) == 4 {
+		withScore = true
+	}
+	rank := 0
+	var data *sorted_set.NodeData[string, string]
+	if ss := this.db[args[1]]; ss != nil {
+		if data = ss.Get(args[2]); data != nil {
+			if rev {
+				rank = ss.GetRevRank(args[2])
+			} else {
+				rank = ss.GetRank(args[2])
+			}
+		}
+	}
+	if data == nil {
+		if withScore {
+			c.w.WriteNullArray()
+		} else {
+			c.w.WriteNull()
+		}
+		return
+	}
+	if withScore {
+		c.w.WriteArray(2)
+		c.w.WriteInt(int64(rank - 1))
+		c.w.WriteDouble(data.Score)
+	} else {
+		c.w.WriteInt(int64(rank - 1))
+	}
+}
+
+func (this *Server) zrankCommand(c *client, args []string) {
+	this.zrank(c, args, false)
+}
+
+func (this *Server) zrevrankCommand(c *client, args []string) {
+	this.zrank(c, args, true)
+}
+
+// 范围查询的类型
+type rangeType int
+
+const (
+	rangeAuto  rangeType = iota // 由ZRANGE的选项决定
+	rangeRank                   // 按排名
+	rangeScore                  // 按分数
+	rangeLex                    // 按字典序
+)
+
+// ZRANGE系列命令的通用实现(参考redis的zrangeGenericCommand)
+// ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+// 其他命令只是固定了范围的类型和方向，逆序时参数的顺序是先max后min
+func (this *Server) zrangeGeneric(c *client, args []string, typ rangeType, rev bool) {
+	// 只有ZRANGE可以通过选项指定范围的类型和方向
+	auto := typ == rangeAuto
+	withScores := false
+	hasLimit := false
+	offset, count := 0, -1
+	for i := 4; i < len(args); i++ {
+		opt := strings.ToLower(args[i])
+		switch {
+		case opt == "withscores":
+			withScores = true
+		case opt == "limit" && i+2 < len(args):
+			o, ok1 := parseInt(args[i+1])
+			n, ok2 := parseInt(args[i+2])
+			if !ok1 || !ok2 {
+				c.w.WriteError(errNotInteger)
+				return
+			}
+			offset, count, hasLimit = o, n, true
+			i += 2
+		case auto && typ == rangeAuto && opt == "byscore":
+			typ = rangeScore
+		case auto && typ == rangeAuto && opt == "bylex":
+			typ = rangeLex
+		case auto && opt == "rev":
+			rev = true
+		default:
+			c.w.WriteError(errSyntax)
+			return
+		}
+	}
+	if typ == rangeAuto {
+		typ = rangeRank
+	}
+	if hasLimit && typ == rangeRank {
+		c.w.WriteError("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+		return
+	}
+	if withScores && typ == rangeLex {
+		c.w.WriteError("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+		return
+	}
+	minArg, maxArg := args[2], args[3]
+	if rev && typ != rangeRank {
+		minArg, maxArg = maxArg, minArg
+	}
+	
+	ss := this.db[args[1]]
+	datas := []*sorted_set.NodeData[string, string]{}
+	switch typ {
+	case rangeRank:
+		start, ok1 := parseInt(minArg)
+		end, ok2 := parseInt(maxArg)
+		if !ok1 || !ok2 {
+			c.w.WriteError(errNotInteger)
+			return
+		}
+		if ss == nil {
+			break
+		}
+		if start, end, ok := rankRange(start, end, ss.Length()); ok {
+			if rev {
+				datas = ss.GetRevRangeByRank(start, end)
+			} else {
+				datas = ss.GetRangeByRank(start, end)
+			}
+		}
+	case rangeScore:
+		r, ok := parseScoreRange(minArg, maxArg)
+		if !ok {
+			c.w.WriteError(errMinMaxFloat)
+			return
+		}
+		if ss == nil || offset < 0 {
+			break
+		}
+		if rev {
+			datas = ss.GetRevRangeByScoreLimit(r.Max, r.MaxExclusive, r.Min, r.MinExclusive, offset, count)
+		} else {
+			datas = ss.GetRangeByScoreLimit(r.Min, r.MinExclusive, r.Max, r.MaxExclusive, offset, count)
+		}
+	case rangeLex:
+		r, empty, ok := parseLexRange(minArg, maxArg)
+		if !ok {
+			c.w.WriteError(errMinMaxString)
+			return
+		}
+		if ss == nil || empty || offset < 0 {
+			break
+		}
+		datas = ss.GetRangeByValue(r)
+		if rev {
+			for i, j := 0, len(datas)-1; i < j; i, j = i+1, j-1 {
+				datas[i], datas[j] = datas[j], datas[i]
+			}
+		}
+		datas = datas[min(offset, len(datas)):]
+		if count >= 0 && count < len(datas) {
+			datas = datas[:count]
+		}
+	}
+	c.replyMembers(datas, withScores)
+}
+
+func (this *Server) zrangeCommand(c *client, args []string) {
+	this.zrangeGeneric(c, args, rangeAuto, false)
+}
+
+func (this *Server) zrevrangeCommand(c *client, args []string) {
+	this.zrangeGeneric(c, args, rangeRank, true)
+}
+
+func (this *Server) zrangebyscoreCommand(c *client, args []string) {
+	this.zrangeGeneric(c, args, rangeScore, false)
+}
+
+func (this *Server) zrevrangebyscoreCommand(c *client, args []string) {
+	this.zrangeGeneric(c, args, rangeScore, true)
+}
+
+func (this *Server) zrangebylexCommand(c *client, args []string) {
+	this.zrangeGeneric(c, args, rangeLex, false)
+}
+
+func (this *Server) zrevrangebylexCommand(c *client, args []string) {
+	this.zrangeGeneric(c, args, rangeLex, true)
+}
+
+// ZRANDMEMBER key [count [WITHSCORES]]
+// count为负数时可以重复获取同一个成员
+func (this *Server) zrandmemberCommand(c *client, args []string) {
+	if len(args) > 4 || len(args) == 4 && !strings.EqualFold(args[3], "withscores") {
+		c.w.WriteError(errSyntax)
+		return
+	}
+	ss := this.db[args[1]]
+	if len(args) == 2 {
+		if ss == nil {
+			c.w.WriteNull()
+		} else {
+			c.w.WriteBulk(ss.RandMember(1, false, sorted_set.RandUniform)[0].Key)
+		}
+		return
+	}
+	count, ok := parseInt(args[2])
+	if !ok {
+		c.w.WriteError(errNotInteger)
+		return
+	}
+	// 和redis一样拒绝超出范围的数量，取反之后不会溢出
+	// 负数允许重复，结果的数量就是-count，限制得更严格以免一条命令分配过多的内存
+	if count > math.MaxInt/2 || count < -maxRandMemberCount {
+		c.w.WriteError(errOutOfRange)
+		return
+	}
+	datas := []*sorted_set.NodeData[string, string]{}
+	if ss != nil && count != 0 {
+		if count > 0 {
+			datas = ss.RandMember(count, false, sorted_set.RandUniform)
+		} else {
+			datas = ss.RandMember(-count, true, sorted_set.RandUniform)
+		}
+	}
+	c.replyMembers(datas, len(args) == 4)
+}
@@ -0,0 +1,214 @@
+// Package resp_server.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// RESP协议(redis客户端和服务器之间的协议)的读写
+// 读取:客户端发送的命令，支持多条批量字符串组成的数组(redis-cli和各种客户端使用)和内联命令(telnet使用)
+// 写入:支持RESP2和RESP3，空值、浮点数、映射在两个版本中的编码不同，由连接协商的版本决定
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package resp_server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxArrayLen   = 1024 * 1024       // 一条命令最多的参数数量
+	maxBulkLen    = 512 * 1024 * 1024 // 单个参数的最大长度(和redis一致)
+	maxInlineSize = 64 * 1024         // 内联命令的最大长度(也是读缓冲区的大小)
+)
+
+// 协议错误，回复客户端之后需要关闭连接
+type protocolError struct {
+	msg string
+}
+
+func (this *protocolError) Error() string {
+	return "Protocol error: " + this.msg
+}
+
+// 读取一行(不包含结尾的\r\n)
+// 超过缓冲区大小的行是不合法的
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", &protocolError{"too big inline request"}
+	}
+	if err != nil {
+		return "", err
+	}
+	n := len(line) - 1
+	if n > 0 && line[n-1] == '\r' {
+		n--
+	}
+	return string(line[:n]), nil
+}
+
+// 读取客户端发送的一条命令
+// 返回的第一个参数是命令名，空行返回空的命令(调用者忽略即可)
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		// 内联命令
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArrayLen {
+		return nil, &protocolError{"invalid multibulk length"}
+	}
+	// 参数数量同样来自客户端，预分配的容量不超过一个缓冲区能容纳的参数数量
+	args := make([]string, 0, min(max(n, 0), maxInlineSize/4))
+	for i := 0; i < n; i++ {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, &protocolError{"expected '$', got '" + line[:min(len(line), 1)] + "'"}
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, &protocolError{"invalid bulk length"}
+		}
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// 读取长度为size的批量字符串和结尾的\r\n
+// 长度来自客户端，不能直接按长度分配内存:按块读取，缓冲区随着实际收到的数据增长
+func readBulk(r *bufio.Reader, size int) (string, error) {
+	var buf bytes.Buffer
+	for size > 0 {
+		chunk := min(size, maxInlineSize)
+		buf.Grow(chunk)
+		if _, err := io.CopyN(&buf, r, int64(chunk)); err != nil {
+			return "", err
+		}
+		size -= chunk
+	}
+	var crlf [2]byte
+	if _, err := io.ReadFull(r, crlf[:]); err != nil {
+		return "", err
+	}
+	if crlf[0] != '\r' || crlf[1] != '\n' {
+		return "", &protocolError{"invalid bulk terminator"}
+	}
+	return buf.String(), nil
+}
+
+// 回复的写入
+// 写入的内容先缓存，处理完客户端已经发送的所有命令之后再统一发送(支持管道)
+type Writer struct {
+	w     *bufio.Writer
+	proto int // 协议版本(2或者3)
+}
+
+func newWriter(w *bufio.Writer) *Writer {
+	return &Writer{
+		w:     w,
+		proto: 2,
+	}
+}
+
+func (this *Writer) writeLine(prefix byte, s string) {
+	this.w.WriteByte(prefix)
+	this.w.WriteString(s)
+	this.w.WriteString("\r\n")
+}
+
+// 简单字符串(比如OK)
+func (this *Writer) WriteSimple(s string) {
+	this.writeLine('+', s)
+}
+
+// 错误(msg需要包含错误类型前缀，比如ERR)
+func (this *Writer) WriteError(msg string) {
+	this.writeLine('-', msg)
+}
+
+func (this *Writer) WriteInt(n int64) {
+	this.writeLine(':', strconv.FormatInt(n, 10))
+}
+
+func (this *Writer) WriteBulk(s string) {
+	this.writeLine('$', strconv.Itoa(len(s)))
+	this.w.WriteString(s)
+	this.w.WriteString("\r\n")
+}
+
+// 空值:RESP2中是空的批量字符串，RESP3中有专门的类型
+func (this *Writer) WriteNull() {
+	if this.proto >= 3 {
+		this.w.WriteString("_\r\n")
+	} else {
+		this.w.WriteString("$-1\r\n")
+	}
+}
+
+// 空数组(比如ZRANK WITHSCORE的成员不存在时):RESP2中是长度为-1的数组
+func (this *Writer) WriteNullArray() {
+	if this.proto >= 3 {
+		this.w.WriteString("_\r\n")
+	} else {
+		this.w.WriteString("*-1\r\n")
+	}
+}
+
+func (this *Writer) WriteArray(n int) {
+	this.writeLine('*', strconv.Itoa(n))
+}
+
+// 映射:RESP2中是键值交替的数组
+func (this *Writer) WriteMap(n int) {
+	if this.proto >= 3 {
+		this.writeLine('%', strconv.Itoa(n))
+	} else {
+		this.WriteArray(n * 2)
+	}
+}
+
+// 浮点数:RESP2中是批量字符串
+func (this *Writer) WriteDouble(f float64) {
+	if this.proto >= 3 {
+		this.writeLine(',', formatScore(f))
+	} else {
+		this.WriteBulk(formatScore(f))
+	}
+}
+
+// 分数转换成字符串(和redis一致，无穷大是inf和-inf)
+func formatScore(f float64) string {
+	if math.IsInf(f, 1) {
+		return "inf"
+	}
+	if math.IsInf(f, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
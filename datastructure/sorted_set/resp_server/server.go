@@ -0,0 +1,198 @@
+// Package resp_server.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 兼容redis协议的服务器，用有序集合(SortedSet)实现ZADD、ZRANGE等Z开头的命令
+// 用于本地测试:redis-cli或者已有的redis客户端可以直接连接，不需要真正的redis
+// 和redis一样，所有命令串行执行(全局一把锁)，一条命令的执行是原子的
+// 只有一个数据库，只支持有序集合这一种类型
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package resp_server
+
+import (
+	"bufio"
+	"errors"
+	"github.com/stormYuanYang/yytools/datastructure/sorted_set"
+	"net"
+	"strings"
+	"sync"
+)
+
+// 成员同时作为key和卫星数据，分数相同时按成员的字典序排序(和redis一致)
+type ZSet = sorted_set.SortedSet[string, string]
+
+func newZSet() *ZSet {
+	return sorted_set.NewSortedSet[string, string](strings.Compare)
+}
+
+type Server struct {
+	mu        sync.Mutex       // 保护db，命令在持有锁时执行
+	db        map[string]*ZSet // 所有的有序集合(没有元素的有序集合会被删除)
+	connMu    sync.Mutex       // 保护下面的字段
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup // 等待所有连接处理结束
+}
+
+var ErrServerClosed = errors.New("resp_server: Server closed")
+
+func NewServer() *Server {
+	return &Server{
+		db:        map[string]*ZSet{},
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+// 在指定地址(比如"127.0.0.1:6379")监听并处理连接，直到服务器关闭
+func (this *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return this.Serve(l)
+}
+
+// 在指定的监听器上处理连接，直到服务器关闭(返回ErrServerClosed)或者监听出错
+// 可以先监听":0"得到随机端口，再调用Serve，方便在测试中嵌入
+func (this *Server) Serve(l net.Listener) error {
+	this.connMu.Lock()
+	if this.closed {
+		this.connMu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	this.listeners[l] = struct{}{}
+	this.connMu.Unlock()
+	
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			this.connMu.Lock()
+			closed := this.closed
+			delete(this.listeners, l)
+			this.connMu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !this.trackConn(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go this.serveConn(conn)
+	}
+}
+
+func (this *Server) trackConn(conn net.Conn) bool {
+	this.connMu.Lock()
+	defer this.connMu.Unlock()
+	if this.closed {
+		return false
+	}
+	this.conns[conn] = struct{}{}
+	this.wg.Add(1)
+	return true
+}
+
+func (this *Server) untrackConn(conn net.Conn) {
+	this.connMu.Lock()
+	delete(this.conns, conn)
+	this.connMu.Unlock()
+	this.wg.Done()
+}
+
+// 关闭服务器:关闭所有的监听器和连接，并等待连接处理结束
+func (this *Server) Close() error {
+	this.connMu.Lock()
+	if this.closed {
+		this.connMu.Unlock()
+		return nil
+	}
+	this.closed = true
+	var err error
+	for l := range this.listeners {
+		if e := l.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for conn := range this.conns {
+		conn.Close()
+	}
+	this.connMu.Unlock()
+	this.wg.Wait()
+	return err
+}
+
+// 直接访问有序集合(比如在测试中准备数据或者检查结果)
+// fn执行期间不会执行任何命令；有序集合不存在时ss为nil，fn返回的有序集合会被保存(nil或者空的有序集合会被删除)
+func (this *Server) Update(key string, fn func(ss *ZSet) *ZSet) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	ss := fn(this.db[key])
+	this.setKey(key, ss)
+}
+
+// 保存有序集合，nil或者空的有序集合会被删除(和redis一致，不存在空的有序集合)
+// 调用者需要持有锁
+func (this *Server) setKey(key string, ss *ZSet) {
+	if ss == nil || ss.Length() == 0 {
+		delete(this.db, key)
+	} else {
+		this.db[key] = ss
+	}
+}
+
+// 处理一个连接:读取命令、执行、回复
+// 客户端通过管道一次发送多条命令时，全部执行完再统一发送回复
+func (this *Server) serveConn(conn net.Conn) {
+	defer this.untrackConn(conn)
+	defer conn.Close()
+	
+	r := bufio.NewReaderSize(conn, maxInlineSize)
+	bw := bufio.NewWriter(conn)
+	c := &client{
+		w: newWriter(bw),
+	}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			var pe *protocolError
+			if errors.As(err, &pe) {
+				c.w.WriteError("ERR " + pe.Error())
+				bw.Flush()
+			}
+			return
+		}
+		if len(args) > 0 {
+			this.execute(c, args)
+		}
+		if r.Buffered() == 0 || c.quit {
+			if bw.Flush() != nil || c.quit {
+				return
+			}
+		}
+	}
+}
+
+// 连接的状态
+type client struct {
+	w    *Writer
+	name string // CLIENT SETNAME设置的名字
+	quit bool   // 回复之后关闭连接
+}
@@ -0,0 +1,279 @@
+// Package resp_server.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 通过TCP连接发送命令，逐字节比较回复(期望的回复和redis 7的一致)
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package resp_server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 启动监听随机端口的服务器
+func startServer(t *testing.T) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(l)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("Serve返回的错误不正确: %v", err)
+		}
+	})
+	return server, l.Addr().String()
+}
+
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *testConn {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &testConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// 编码成批量字符串组成的数组
+func encodeCommand(args ...string) string {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	return b.String()
+}
+
+// 读取一个完整的回复(原始字节)
+func (this *testConn) readReply() string {
+	line, err := this.r.ReadString('\n')
+	if err != nil {
+		this.t.Fatal(err)
+	}
+	switch line[0] {
+	case '$':
+		n, _ := strconv.Atoi(line[1 : len(line)-2])
+		if n < 0 {
+			return line
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(this.r, buf); err != nil {
+			this.t.Fatal(err)
+		}
+		return line + string(buf)
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1 : len(line)-2])
+		if line[0] == '%' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			line += this.readReply()
+		}
+	}
+	return line
+}
+
+func (this *testConn) mustDo(expected string, args ...string) {
+	this.t.Helper()
+	if _, err := this.conn.Write([]byte(encodeCommand(args...))); err != nil {
+		this.t.Fatal(err)
+	}
+	if reply := this.readReply(); reply != expected {
+		this.t.Errorf("%v\n回复: %q\n期望: %q", args, reply, expected)
+	}
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func array(items ...string) string {
+	return "*" + strconv.Itoa(len(items)) + "\r\n" + strings.Join(items, "")
+}
+
+func integer(n int) string {
+	return ":" + strconv.Itoa(n) + "\r\n"
+}
+
+const (
+	ok   = "+OK\r\n"
+	null = "$-1\r\n"
+)
+
+func TestZCommandsRESP2(t *testing.T) {
+	_, addr := startServer(t)
+	c := dial(t, addr)
+	
+	c.mustDo("+PONG\r\n", "PING")
+	c.mustDo(integer(3), "ZADD", "z", "1", "a", "2", "b", "3", "c")
+	c.mustDo(integer(1), "ZADD", "z", "CH", "1.5", "a")
+	c.mustDo(integer(0), "ZADD", "z", "NX", "10", "a")
+	c.mustDo(integer(0), "ZADD", "z", "XX", "10", "d")
+	c.mustDo(integer(0), "ZADD", "z", "GT", "CH", "1", "a")
+	c.mustDo(bulk("11.5"), "ZADD", "z", "INCR", "10", "a")
+	c.mustDo(null, "ZADD", "z", "LT", "INCR", "1", "a")
+	c.mustDo("-ERR XX and NX options at the same time are not compatible\r\n", "ZADD", "z", "NX", "XX", "1", "a")
+	c.mustDo("-ERR GT, LT, and/or NX options at the same time are not compatible\r\n", "ZADD", "z", "NX", "GT", "1", "a")
+	c.mustDo("-ERR syntax error\r\n", "ZADD", "z", "1", "a", "2")
+	c.mustDo("-ERR value is not a valid float\r\n", "ZADD", "z", "1", "x", "nan", "y")
+	c.mustDo(null, "ZSCORE", "z", "x")
+	c.mustDo(bulk("-2.5"), "ZINCRBY", "z", "-5.5", "c")
+	c.mustDo(integer(3), "ZCARD", "z")
+	
+	// a:11.5 b:2 c:-2.5
+	c.mustDo(array(bulk("c"), bulk("b"), bulk("a")), "ZRANGE", "z", "0", "-1")
+	c.mustDo(array(bulk("a"), bulk("11.5"), bulk("b"), bulk("2")), "ZREVRANGE", "z", "0", "1", "WITHSCORES")
+	c.mustDo(array(), "ZRANGE", "z", "5", "10")
+	c.mustDo(array(bulk("b"), bulk("a")), "ZRANGEBYSCORE", "z", "(-2.5", "+inf")
+	c.mustDo(array(bulk("a")), "ZRANGE", "z", "+inf", "0", "BYSCORE", "REV", "LIMIT", "0", "1")
+	c.mustDo(array(bulk("b"), bulk("c")), "ZREVRANGEBYSCORE", "z", "2", "-inf")
+	c.mustDo("-ERR min or max is not a float\r\n", "ZRANGEBYSCORE", "z", "x", "1")
+	c.mustDo("-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n",
+		"ZRANGE", "z", "0", "1", "LIMIT", "0", "1")
+	c.mustDo(integer(2), "ZCOUNT", "z", "-inf", "(11.5")
+	c.mustDo(integer(0), "ZRANK", "z", "c")
+	c.mustDo(integer(0), "ZREVRANK", "z", "a")
+	c.mustDo(array(integer(2), bulk("11.5")), "ZRANK", "z", "a", "WITHSCORE")
+	c.mustDo(null, "ZRANK", "z", "x")
+	c.mustDo("*-1\r\n", "ZRANK", "z", "x", "WITHSCORE")
+	c.mustDo(array(bulk("2"), null), "ZMSCORE", "z", "b", "x")
+	c.mustDo("-ERR value is out of range\r\n", "ZRANDMEMBER", "z", "-9223372036854775808")
+	c.mustDo("-ERR value is out of range\r\n", "ZRANDMEMBER", "z", "4611686018427387904")
+	c.mustDo("-ERR value is out of range\r\n", "ZRANDMEMBER", "z", strconv.Itoa(-maxRandMemberCount-1))
+	
+	// 字典序范围
+	c.mustDo(integer(5), "ZADD", "lex", "0", "a", "0", "b", "0", "c", "0", "d", "0", "e")
+	c.mustDo(array(bulk("b"), bulk("c")), "ZRANGEBYLEX", "lex", "[b", "(d")
+	c.mustDo(array(bulk("e"), bulk("d")), "ZREVRANGEBYLEX", "lex", "+", "(c")
+	c.mustDo(array(bulk("b")), "ZRANGE", "lex", "-", "+", "BYLEX", "LIMIT", "1", "1")
+	c.mustDo(integer(5), "ZLEXCOUNT", "lex", "-", "+")
+	c.mustDo(integer(0), "ZLEXCOUNT", "lex", "+", "-")
+	c.mustDo("-ERR min or max not valid string range item\r\n", "ZLEXCOUNT", "lex", "a", "+")
+	c.mustDo(integer(2), "ZREMRANGEBYLEX", "lex", "[d", "+")
+	
+	// 删除
+	c.mustDo(integer(1), "ZREMRANGEBYRANK", "lex", "-1", "-1")
+	c.mustDo("-ERR wrong number of arguments for 'zremrangebyscore' command\r\n", "ZREMRANGEBYSCORE", "lex", "0")
+	c.mustDo(integer(2), "ZREMRANGEBYSCORE", "lex", "-inf", "inf")
+	c.mustDo(integer(0), "EXISTS", "lex")
+	c.mustDo(array(bulk("c"), bulk("-2.5")), "ZPOPMIN", "z")
+	c.mustDo(array(bulk("a"), bulk("11.5"), bulk("b"), bulk("2")), "ZPOPMAX", "z", "5")
+	c.mustDo(array(), "ZPOPMIN", "z")
+	c.mustDo("+none\r\n", "TYPE", "z")
+	c.mustDo(integer(0), "ZREM", "z2", "x")
+	c.mustDo(integer(2), "ZADD", "z2", "1", "a", "2", "b")
+	c.mustDo(integer(1), "ZREM", "z2", "a", "x")
+	c.mustDo("+zset\r\n", "TYPE", "z2")
+	c.mustDo(array(bulk("z2")), "KEYS", "z?")
+	c.mustDo(integer(1), "DEL", "z2", "z3")
+	c.mustDo(integer(0), "DBSIZE")
+	c.mustDo("-ERR unknown command 'SET', with args beginning with: 'k' 'v' \r\n", "SET", "k", "v")
+}
+
+func TestZCommandsRESP3(t *testing.T) {
+	_, addr := startServer(t)
+	c := dial(t, addr)
+	
+	c.mustDo("-NOPROTO unsupported protocol version\r\n", "HELLO", "4")
+	c.mustDo("%7\r\n"+bulk("server")+bulk("redis")+bulk("version")+bulk("7.0.0")+bulk("proto")+integer(3)+
+		bulk("id")+integer(1)+bulk("mode")+bulk("standalone")+bulk("role")+bulk("master")+bulk("modules")+array(),
+		"HELLO", "3", "SETNAME", "test")
+	c.mustDo(bulk("test"), "CLIENT", "GETNAME")
+	c.mustDo(integer(2), "ZADD", "z", "1", "a", "-inf", "b")
+	c.mustDo(",1\r\n", "ZSCORE", "z", "a")
+	c.mustDo("_\r\n", "ZSCORE", "z", "x")
+	c.mustDo(array(array(bulk("b"), ",-inf\r\n"), array(bulk("a"), ",1\r\n")), "ZRANGE", "z", "0", "-1", "WITHSCORES")
+	c.mustDo(array(bulk("b"), ",-inf\r\n"), "ZPOPMIN", "z")
+	c.mustDo(array(array(bulk("a"), ",1\r\n")), "ZPOPMAX", "z", "1")
+	c.mustDo(",inf\r\n", "ZINCRBY", "z", "inf", "a")
+	c.mustDo("-ERR resulting score is not a number (NaN)\r\n", "ZADD", "z", "INCR", "-inf", "a")
+	c.mustDo("-ERR resulting score is not a number (NaN)\r\n", "ZINCRBY", "z", "-inf", "a")
+	c.mustDo(",inf\r\n", "ZSCORE", "z", "a")
+}
+
+// 管道和内联命令
+func TestPipelineAndInline(t *testing.T) {
+	server, addr := startServer(t)
+	c := dial(t, addr)
+	
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		b.WriteString(encodeCommand("ZADD", "z", strconv.Itoa(i), "m"+strconv.Itoa(i)))
+	}
+	b.WriteString("ZCARD z\r\n")
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if reply := c.readReply(); reply != integer(1) {
+			t.Fatalf("第%d条命令的回复不正确: %q", i, reply)
+		}
+	}
+	if reply := c.readReply(); reply != integer(100) {
+		t.Fatalf("内联命令的回复不正确: %q", reply)
+	}
+	
+	server.Update("z", func(ss *ZSet) *ZSet {
+		if ss.Length() != 100 || ss.GetByRank(1).Key != "m0" {
+			t.Errorf("有序集合的内容不正确")
+		}
+		ss.DeleteRangeByRank(1, 50)
+		return ss
+	})
+	c.mustDo(array(bulk("m50")), "ZRANGE", "z", "0", "0")
+	
+	// 超过读缓冲区的参数需要分多块读取
+	big := strings.Repeat("x", maxInlineSize*3+1)
+	c.mustDo(integer(1), "ZADD", "big", "1", big)
+	c.mustDo(array(bulk(big)), "ZRANGE", "big", "0", "0")
+	
+	c.mustDo(ok, "QUIT")
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("QUIT之后连接需要被关闭: %v", err)
+	}
+	
+	// 协议错误之后连接会被关闭
+	c = dial(t, addr)
+	c.conn.Write([]byte("*1\r\n+PING\r\n"))
+	if reply := c.readReply(); !strings.HasPrefix(reply, "-ERR Protocol error") {
+		t.Errorf("协议错误的回复不正确: %q", reply)
+	}
+	
+	// 批量字符串的结尾不是\r\n
+	c = dial(t, addr)
+	c.conn.Write([]byte("*1\r\n$4\r\nPINGxx"))
+	if reply := c.readReply(); reply != "-ERR Protocol error: invalid bulk terminator\r\n" {
+		t.Errorf("协议错误的回复不正确: %q", reply)
+	}
+}
@@ -0,0 +1,171 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 有序集合的集合运算(参考redis的ZUNIONSTORE/ZINTERSTORE/ZDIFF)
+// 运算结果是一个新的有序集合，原有的有序集合不会被修改
+// 结果中元素的卫星数据取自该元素第一次出现的有序集合
+// 结果的参数(比较器、提升结点高度的概率、分数和的维护、紧凑编码的阈值)取自第一个有序集合
+// 和逐个插入一样，结果中不同的元素不能有相同的(分数, 卫星数据):
+// 并集和交集加权、聚合之后可能出现这样的元素(比如两个元素的卫星数据相等，分数之和也相等)，这时返回ErrBulkLoadOrder
+// 差集是第一个有序集合的子集，不会出现这样的元素，所以不返回错误
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"math"
+)
+
+// 聚合方式(同一个元素在多个有序集合中出现时，如何计算结果的分数)
+type Aggregate int32
+
+const (
+	AggregateSum Aggregate = iota // 0 求和
+	AggregateMin                  // 1 取最小值
+	AggregateMax                  // 2 取最大值
+)
+
+func aggregateScore(agg Aggregate, a float64, b float64) float64 {
+	switch agg {
+	case AggregateSum:
+		// 正无穷加负无穷的结果是NaN,和redis一样视为0
+		return nanToZero(a + b)
+	case AggregateMin:
+		return math.Min(a, b)
+	case AggregateMax:
+		return math.Max(a, b)
+	default:
+		panic("unsupported aggregate")
+	}
+}
+
+func nanToZero(f float64) float64 {
+	if math.IsNaN(f) {
+		return 0
+	}
+	return f
+}
+
+// 权重为nil时，所有有序集合的权重都是1
+func weightOf(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
+}
+
+func checkSetOpArgs[K comparable, V any](sets []*SortedSet[K, V], weights []float64) {
+	assert.Assert(len(sets) > 0, "至少需要一个有序集合")
+	assert.Assert(weights == nil || len(weights) == len(sets),
+		"权重数量和有序集合数量不一致, weights:", len(weights), " sets:", len(sets))
+	for _, w := range weights {
+		assert.Assert(!math.IsNaN(w), "weight is not a number")
+	}
+	// 先删除已经过期的元素，后面直接访问哈希表和跳跃表
+	for _, ss := range sets {
+		ss.expireDue()
+	}
+}
+
+// 并集
+// 每个元素的分数乘以所在有序集合的权重，再按照聚合方式计算结果的分数
+func Union[K comparable, V any](sets []*SortedSet[K, V], weights []float64, agg Aggregate) (*SortedSet[K, V], error) {
+	checkSetOpArgs(sets, weights)
+	
+	result := make(map[K]*NodeData[K, V], sets[0].Length())
+	for i, ss := range sets {
+		w := weightOf(weights, i)
+		ss.list().walk(func(_ int, data *NodeData[K, V]) bool {
+			// 无穷乘以0的结果是NaN,和redis一样视为0
+			score := nanToZero(data.Score * w)
+			if one, has := result[data.Key]; has {
+				one.Score = aggregateScore(agg, one.Score, score)
+			} else {
+				result[data.Key] = NewNodeData(data.Key, score, data.Val)
+			}
+			return true
+		})
+	}
+	
+	datas := make([]*NodeData[K, V], 0, len(result))
+	for _, data := range result {
+		datas = append(datas, data)
+	}
+	return newSortedSetFromDatas(sets[0], datas, false)
+}
+
+// 交集
+// 只保留在所有有序集合中都出现的元素
+func Inter[K comparable, V any](sets []*SortedSet[K, V], weights []float64, agg Aggregate) (*SortedSet[K, V], error) {
+	checkSetOpArgs(sets, weights)
+	
+	// 从最小的有序集合开始遍历，减少查找次数
+	smallest := 0
+	for i, ss := range sets {
+		if ss.Length() < sets[smallest].Length() {
+			smallest = i
+		}
+	}
+	
+	datas := make([]*NodeData[K, V], 0, sets[smallest].Length())
+	sets[smallest].list().walk(func(_ int, first *NodeData[K, V]) bool {
+		key := first.Key
+		var data *NodeData[K, V]
+		for i, ss := range sets {
+			one, has := ss.lookup(key)
+			if !has {
+				data = nil
+				break
+			}
+			score := nanToZero(one.Score * weightOf(weights, i))
+			if data == nil {
+				data = NewNodeData(key, score, one.Val)
+			} else {
+				data.Score = aggregateScore(agg, data.Score, score)
+			}
+		}
+		if data != nil {
+			datas = append(datas, data)
+		}
+		return true
+	})
+	return newSortedSetFromDatas(sets[0], datas, false)
+}
+
+// 差集
+// 只保留在第一个有序集合中出现，且不在其他有序集合中出现的元素(分数不变)
+func Diff[K comparable, V any](sets []*SortedSet[K, V]) *SortedSet[K, V] {
+	checkSetOpArgs(sets, nil)
+	
+	datas := make([]*NodeData[K, V], 0, sets[0].Length())
+	// 按顺序遍历第一个有序集合，结果天然有序
+	sets[0].list().walk(func(_ int, data *NodeData[K, V]) bool {
+		found := false
+		for _, ss := range sets[1:] {
+			if _, found = ss.lookup(data.Key); found {
+				break
+			}
+		}
+		if !found {
+			datas = append(datas, NewNodeData(data.Key, data.Score, data.Val))
+		}
+		return true
+	})
+	result, err := newSortedSetFromDatas(sets[0], datas, true)
+	assert.Assert(err == nil, "差集不会出现相同的(分数, 卫星数据):", err)
+	return result
+}
@@ -24,56 +24,46 @@ import (
 	"math"
 )
 
-type SkipList struct {
-	Head        *Node   // 头结点(哨兵结点)
-	Tail        *Node   // 尾结点
-	Length      int     // 结点总数(不包含头结点)
-	Level       int     // 链表中当前结点的最大高度(除开头结点的其他结点中的最高的高度)
-	LevelUpProb float32 // 提升结点高度的概率
-}
-
-type SkipListLevel struct {
-	Forward *Node// 同一高度下，指向的下一个结点
-	Span    int // 同一高度下, 结点之间的跨度(方便取结点的排名) 跨度是基于1的
-}
-
-type Value interface {
-	LessThan(other Value) bool
-	EqualTo(other Value) bool
-}
-
-// 不小于也不等于,那么就是大于
-func ValueGreaterThan(one Value, other Value) bool {
-	return !one.LessThan(other) && !one.EqualTo(other)
-}
-
-type NodeData struct {
-	Key   interface{}
+type SkipList[K comparable, V any] struct {
+	Head        *Node[K, V]    // 头结点(哨兵结点)
+	Tail        *Node[K, V]    // 尾结点
+	Length      int            // 结点总数(不包含头结点)
+	Level       int            // 链表中当前结点的最大高度(除开头结点的其他结点中的最高的高度)
+	LevelUpProb float32        // 提升结点高度的概率
+	Cmp         Comparator[V]  // 分数相同时，比较卫星数据的大小
+	SumEnabled  bool           // 是否维护每一层的分数和(通过EnableSum开启)
+	undo        *undoLog[K, V] // 有视图时，修改结点之前记录结点的状态(视图中是创建视图时的日志，见view.go)
+	view        *viewRef       // 只有视图才有:读取结点时需要跳过创建视图之后的修改
+}
+
+type SkipListLevel[K comparable, V any] struct {
+	Forward *Node[K, V] // 同一高度下，指向的下一个结点
+	Span    int         // 同一高度下, 结点之间的跨度(方便取结点的排名) 跨度是基于1的
+	Sum     float64     // 同一高度下, 跨度覆盖的结点(不包含自身)的分数和(开启SumEnabled时才维护)
+}
+
+// 比较器
+// a小于b时返回负数，a等于b时返回0，a大于b时返回正数
+type Comparator[V any] func(a, b V) int
+
+type NodeData[K comparable, V any] struct {
+	Key   K
 	Score float64 // 分数(跳跃表根据该数值来对节点进行有序排列)
-	Val   Value   // 卫星数据
+	Val   V       // 卫星数据(分数相同时，通过比较器比较卫星数据来决定顺序)
 }
 
-func NewNodeData(key interface{}, score float64, val Value) *NodeData {
-	return &NodeData{
+func NewNodeData[K comparable, V any](key K, score float64, val V) *NodeData[K, V] {
+	return &NodeData[K, V]{
 		Key:   key,
 		Score: score,
 		Val:   val,
 	}
 }
 
-func (this *NodeData) LessThan(other *NodeData) bool {
-	return this.Score < other.Score ||
-		this.Score == other.Score && this.Val.LessThan(other.Val)
-}
-
-func (this *NodeData) EqualTo(other *NodeData) bool {
-	return this.Score == other.Score && this.Val.EqualTo(other.Val)
-}
-
-type Node struct {
-	Levels   []*SkipListLevel // 向前的(每个高度的下一个)结点数组
-	Backward *Node            // 上一个结点(这样最下层就是双向链表，方便向后的遍历)
-	Data     *NodeData        // 结点携带的数据(包含分数)
+type Node[K comparable, V any] struct {
+	Levels   []*SkipListLevel[K, V] // 向前的(每个高度的下一个)结点数组
+	Backward *Node[K, V]            // 上一个结点(这样最下层就是双向链表，方便向后的遍历)
+	Data     *NodeData[K, V]        // 结点携带的数据(包含分数)
 }
 
 type RangeSpecifiedBase struct {
@@ -89,24 +79,39 @@ type RangeSpecified struct {
 }
 
 // 比较值时，指定值范围和边界(开闭区间)
-type ValueRangeSpecified struct {
+// 参考redis的ZRANGEBYLEX:只有当所有元素的分数都相同时，按值查找的结果才有意义
+type ValueRangeSpecified[V any] struct {
 	RangeSpecifiedBase
-	Min Value
-	Max Value
+	Min    V
+	Max    V
+	MinInf bool // true:没有下界(相当于redis中的"-"),此时忽略Min和MinExclusive
+	MaxInf bool // true:没有上界(相当于redis中的"+"),此时忽略Max和MaxExclusive
+}
+
+// 创建有上下界的值范围
+func NewValueRange[V any](min V, minEx bool, max V, maxEx bool) *ValueRangeSpecified[V] {
+	return &ValueRangeSpecified[V]{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
 }
 
 /*
 	method of Node
 */
-func CreateNode(level int, data *NodeData) *Node {
-	levelArr := make([]*SkipListLevel, level)
+func CreateNode[K comparable, V any](level int, data *NodeData[K, V]) *Node[K, V] {
+	levelArr := make([]*SkipListLevel[K, V], level)
 	for i := 0; i < level; i++ {
-		levelArr[i] = &SkipListLevel{
+		levelArr[i] = &SkipListLevel[K, V]{
 			Forward: nil,
 			Span:    0,
 		}
 	}
-	node := &Node{
+	node := &Node[K, V]{
 		Levels:   levelArr,
 		Backward: nil,
 		Data:     data,
@@ -114,34 +119,55 @@ func CreateNode(level int, data *NodeData) *Node {
 	return node
 }
 
-func (this *Node) High() int {
+func (this *Node[K, V]) High() int {
 	return len(this.Levels)
 }
 
 /*
 	method of SkipList
 */
-func NewSkipList() *SkipList {
-	return NewSkipListByParams(DEFAULT_LEVELUP_PROBABILITY)
+func NewSkipList[K comparable, V any](cmp Comparator[V]) *SkipList[K, V] {
+	return NewSkipListByParams[K, V](cmp, DEFAULT_LEVELUP_PROBABILITY)
 }
 
-func NewSkipListByParams(nodeLevelUpProb float32) *SkipList {
+func NewSkipListByParams[K comparable, V any](cmp Comparator[V], nodeLevelUpProb float32) *SkipList[K, V] {
+	assert.Assert(cmp != nil, "比较器不能为nil")
 	assert.Assert(nodeLevelUpProb >= 0 && nodeLevelUpProb < 1,
 		"提升节点高度概率不正确:", nodeLevelUpProb, "正常范围:[0.0,1)")
 	
-	skipList := &SkipList{
-		Head: CreateNode(SKIPLIST_MAXLEVEL, &NodeData{
-			Score: 0,
-			Val:   nil,
-		}),
+	skipList := &SkipList[K, V]{
+		// 头结点不携带有效数据
+		Head:        CreateNode[K, V](SKIPLIST_MAXLEVEL, &NodeData[K, V]{}),
 		Tail:        nil,
 		Length:      0,
 		Level:       0,
 		LevelUpProb: nodeLevelUpProb,
+		Cmp:         cmp,
 	}
 	return skipList
 }
 
+// 比较两个结点数据的大小
+// 先比较分数，分数相同时再通过比较器比较卫星数据
+// a小于b时返回负数，a等于b时返回0，a大于b时返回正数
+func (this *SkipList[K, V]) CompareData(a, b *NodeData[K, V]) int {
+	if a.Score < b.Score {
+		return -1
+	}
+	if a.Score > b.Score {
+		return 1
+	}
+	return this.Cmp(a.Val, b.Val)
+}
+
+func (this *SkipList[K, V]) dataLessThan(a, b *NodeData[K, V]) bool {
+	return this.CompareData(a, b) < 0
+}
+
+func (this *SkipList[K, V]) dataEqualTo(a, b *NodeData[K, V]) bool {
+	return this.CompareData(a, b) == 0
+}
+
 /*
  跳跃表基本操作（增删改查）
 */
@@ -149,7 +175,7 @@ func NewSkipListByParams(nodeLevelUpProb float32) *SkipList {
 // 根据分数和数据查找结点
 // 时间复杂度为O(logn)
 // 空间复杂度为O(1)
-func (this *SkipList) Get(score float64, data *NodeData) (*Node, bool) {
+func (this *SkipList[K, V]) Get(score float64, data *NodeData[K, V]) (*Node[K, V], bool) {
 	// 断言(判断传入的分数值)
 	assert.Assert(!math.IsNaN(score), "score is not a number:", score)
 	// 断言(不允许传入nil)
@@ -160,13 +186,13 @@ func (this *SkipList) Get(score float64, data *NodeData) (*Node, bool) {
 		// 满足两个条件，查找就继续在当前高度向前继续查找：
 		// 1.指定分数大于当前结点的分数，说明要查找的节点一定在当前结点的前方;
 		// 2.当前节点不能是(哨兵)尾结点
-		current := prev.Levels[i].Forward
-		for current != nil && current.Data.LessThan(data) {
+		current := this.state(prev).Levels[i].Forward
+		for current != nil && this.dataLessThan(this.state(current).Data, data) {
 			// 双指针继续向前移动
 			prev = current
-			current = prev.Levels[i].Forward
+			current = this.state(prev).Levels[i].Forward
 		}
-		if current != nil && current.Data.EqualTo(data) {
+		if current != nil && this.dataEqualTo(this.state(current).Data, data) {
 			// 找到了
 			return current, true
 		}
@@ -181,7 +207,7 @@ func (this *SkipList) Get(score float64, data *NodeData) (*Node, bool) {
 // 需要由调用者保证不插入重复的结点,如果结点已存在则会插入失败
 // 时间复杂度为O(logn)
 // 空间复杂度为O(1)
-func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
+func (this *SkipList[K, V]) Insert(data *NodeData[K, V]) (*Node[K, V], bool) {
 	// 断言(不允许传入nil)
 	assert.Assert(data != nil, "data must not be nil")
 	// 断言(判断传入的分数值)
@@ -189,7 +215,7 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 	
 	//注意这里，使用数组而不是切片，避免不必要的堆内存分配(插入操作可能会很频繁)
 	// 当前这种情况，(只要该函数不返回数组)数组就是分配在栈上的
-	prevNodes := [SKIPLIST_MAXLEVEL]*Node{}
+	prevNodes := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
 	rank := [SKIPLIST_MAXLEVEL]int{}
 	
 	//	跨度是基于1的(即跨度单位是1)
@@ -204,7 +230,7 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 		}
 		
 		current := prev.Levels[i].Forward
-		for current != nil && current.Data.LessThan(data) {
+		for current != nil && this.dataLessThan(current.Data, data) {
 			// 指针向前行进说明，要插入的结点在当前结点的前方
 			// 那么加上当前结点的跨度
 			rank[i] += prev.Levels[i].Span
@@ -214,7 +240,7 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 			// 当前层链表，当前指针也前进一个节点
 			current = prev.Levels[i].Forward
 		}
-		if current != nil && current.Data.EqualTo(data) {
+		if current != nil && this.dataEqualTo(current.Data, data) {
 			// 如果逻辑走到这里，意味着将插入重复元素(不允许插入重复元素)
 			// 返回已存在的元素
 			// BTW,如果调用者能够保证不会插入重复的元素，那么这里的判断就是不必要的
@@ -228,6 +254,7 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 	
 	level := randomLevel(this.LevelUpProb)
 	if level > this.Level {
+		this.save(this.Head)
 		for i := this.Level; i < level; i++ {
 			// 比跳跃表原有的结点高度还高,则需要将头结点作为高高度的前置结点
 			prevNodes[i] = this.Head
@@ -240,7 +267,9 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 	
 	//	1.创建新的结点,设置相关数据;2.并插入指定位置,并且更新和维护结点每一层的索引关系
 	newNode := CreateNode(level, data)
+	this.created(newNode)
 	for i := 0; i < level; i++ {
+		this.save(prevNodes[i])
 		// 将每一层向前(方向)的链表都重新链接起来
 		newNode.Levels[i].Forward = prevNodes[i].Levels[i].Forward
 		prevNodes[i].Levels[i].Forward = newNode
@@ -260,6 +289,7 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 	}
 	// (如果有)更高高度结点的跨度需要加一
 	for i := level; i < this.Level; i++ {
+		this.save(prevNodes[i])
 		prevNodes[i].Levels[i].Span++
 	}
 	
@@ -274,12 +304,16 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 	}
 	
 	if newNode.Levels[0].Forward != nil {
+		this.save(newNode.Levels[0].Forward)
 		newNode.Levels[0].Forward.Backward = newNode
 	} else {
 		this.Tail = newNode
 	}
 	// 更新跳跃表中的结点总数
 	this.Length++
+	if this.SumEnabled {
+		this.updateSums(&prevNodes, newNode)
+	}
 	
 	// 至此，结点正确插入到跳跃表中
 	// 并且结点间的索引关系也得到正确维护
@@ -288,11 +322,11 @@ func (this *SkipList) Insert(data *NodeData) (*Node, bool) {
 
 // 通过分数和值查找指定结点，并更新前置结点数组
 // 注意和Get方法区分：Get方法找到结点就返回，这个方法还要更新前置结点数组，更消耗一些
-func (this *SkipList) findNode(data *NodeData, prevNodes *[SKIPLIST_MAXLEVEL]*Node) (*Node, bool) {
+func (this *SkipList[K, V]) findNode(data *NodeData[K, V], prevNodes *[SKIPLIST_MAXLEVEL]*Node[K, V]) (*Node[K, V], bool) {
 	prev := this.Head
 	for i := this.Level - 1; i >= 0; i-- {
 		current := prev.Levels[i].Forward
-		for current != nil && current.Data.LessThan(data) {
+		for current != nil && this.dataLessThan(current.Data, data) {
 			prev = current
 			current = prev.Levels[i].Forward
 		}
@@ -301,7 +335,7 @@ func (this *SkipList) findNode(data *NodeData, prevNodes *[SKIPLIST_MAXLEVEL]*No
 		// 继续循环，到下一高度查找和处理
 	}
 	current := prev.Levels[0].Forward
-	if current != nil && current.Data.EqualTo(data) {
+	if current != nil && this.dataEqualTo(current.Data, data) {
 		return current, true
 	}
 	// 找不到指定的结点(必须要分数和数据都等，才算是同一个结点)
@@ -311,10 +345,11 @@ func (this *SkipList) findNode(data *NodeData, prevNodes *[SKIPLIST_MAXLEVEL]*No
 // 注意，go和C/C++甚至Java不同的地方
 // 在go中，数组是值传递——传递给一个函数时，是拷贝原数组而不是传递的指针(引用)
 // 为了避免拷贝这里传递数组的指针
-func (this *SkipList) deleteNode(current *Node, prevNodes *[SKIPLIST_MAXLEVEL]*Node) *Node{
+func (this *SkipList[K, V]) deleteNode(current *Node[K, V], prevNodes *[SKIPLIST_MAXLEVEL]*Node[K, V]) *Node[K, V]{
 	//	1.移除结点
 	//	2.处理结点每一层的索引关系
 	for i := 0; i < this.Level; i++ {
+		this.save(prevNodes[i])
 		if prevNodes[i].Levels[i].Forward == current {
 			// 更新当前高度的前置结点到下一结点的跨度
 			// 减一是因为删除一个结点，跨度当然就会减一
@@ -328,6 +363,7 @@ func (this *SkipList) deleteNode(current *Node, prevNodes *[SKIPLIST_MAXLEVEL]*N
 		}
 	}
 	if current.Levels[0].Forward != nil {
+		this.save(current.Levels[0].Forward)
 		current.Levels[0].Forward.Backward = current.Backward
 	} else {
 		// 被删除的结点是尾结点，那么其前置结点成为新的尾结点
@@ -341,6 +377,9 @@ func (this *SkipList) deleteNode(current *Node, prevNodes *[SKIPLIST_MAXLEVEL]*N
 	}
 	// 结点总数减一
 	this.Length--
+	if this.SumEnabled {
+		this.updateSums(prevNodes, nil)
+	}
 	// 至此，指定某个结点已被删除
 	// 并正确维护了剩余结点间的关系
 	return current
@@ -349,7 +388,7 @@ func (this *SkipList) deleteNode(current *Node, prevNodes *[SKIPLIST_MAXLEVEL]*N
 // 根据分数和值，删除指定结点
 // 时间复杂度为O(logn)
 // 空间复杂度为O(1)
-func (this *SkipList) Delete(data *NodeData) (*Node, bool) {
+func (this *SkipList[K, V]) Delete(data *NodeData[K, V]) (*Node[K, V], bool) {
 	// 断言(不允许传入nil)
 	assert.Assert(data != nil, "val must not be nil")
 	// 断言(判断传入的分数值)
@@ -357,7 +396,7 @@ func (this *SkipList) Delete(data *NodeData) (*Node, bool) {
 	
 	// 注意这里，使用数组而不是切片，避免不必要的堆内存分配
 	// 当前这种情况，(只要该函数不返回数组)数组就是分配在栈上的
-	prevNodes := [SKIPLIST_MAXLEVEL]*Node{}
+	prevNodes := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
 	current, ok := this.findNode(data, &prevNodes)
 	if !ok {
 		return current, ok
@@ -370,7 +409,7 @@ func (this *SkipList) Delete(data *NodeData) (*Node, bool) {
 */
 
 // 这个方法的实现和Get()几乎一模一样
-func (this *SkipList) GetRank(data *NodeData) int {
+func (this *SkipList[K, V]) GetRank(data *NodeData[K, V]) int {
 	// 断言(不允许传入nil)
 	assert.Assert(data != nil, "val must not be nil")
 	// 断言(判断传入的分数值)
@@ -382,16 +421,16 @@ func (this *SkipList) GetRank(data *NodeData) int {
 		// 满足两个条件，查找就继续在当前高度向前继续查找：
 		// 1.指定分数大于当前结点的分数，说明要查找的节点一定在当前结点的前方;
 		// 2.当前节点不能是(哨兵)尾结点
-		current := prev.Levels[i].Forward
-		for current != nil && (current.Data.LessThan(data) || current.Data.EqualTo(data)) {
+		current := this.state(prev).Levels[i].Forward
+		for current != nil && this.CompareData(this.state(current).Data, data) <= 0 {
 			// 累计跨度
-			rank += prev.Levels[i].Span
+			rank += this.state(prev).Levels[i].Span
 			
 			// 双指针继续向前移动
 			prev = current
-			current = prev.Levels[i].Forward
+			current = this.state(prev).Levels[i].Forward
 		}
-		if prev != nil && prev.Data.EqualTo(data) {
+		if prev != this.Head && this.dataEqualTo(this.state(prev).Data, data) {
 			// 找到了
 			return rank
 		}
@@ -403,22 +442,22 @@ func (this *SkipList) GetRank(data *NodeData) int {
 	return 0
 }
 
-func (this *SkipList) GetNodeByRank(rank int) *Node {
+func (this *SkipList[K, V]) GetNodeByRank(rank int) *Node[K, V] {
 	assert.Assert(rank > 0, "rank must >= 0,rank:", rank)
 	
 	traversed := 0
 	prev := this.Head
 	for i := this.Level - 1; i >= 0; i-- {
-		current := prev.Levels[i].Forward
+		current := this.state(prev).Levels[i].Forward
 		// 当在当前高度，累计的跨度小于等于指定排名时继续向右查找
 		for current != nil &&
-			(traversed+prev.Levels[i].Span) <= rank {
+			(traversed+this.state(prev).Levels[i].Span) <= rank {
 			// 累加跨度
-			traversed += prev.Levels[i].Span
+			traversed += this.state(prev).Levels[i].Span
 			
 			// 指针前进
 			prev = current
-			current = prev.Levels[i].Forward
+			current = this.state(prev).Levels[i].Forward
 		}
 		if traversed == rank {
 			return prev
@@ -428,32 +467,64 @@ func (this *SkipList) GetNodeByRank(rank int) *Node {
 	return nil
 }
 
-func (this *SkipList) GetRangeByRank(start int, end int) []*NodeData {
+func (this *SkipList[K, V]) GetRangeByRank(start int, end int) []*NodeData[K, V] {
 	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
 	
 	// 找到在指定范围中最小的结点(如果没有就是nil)
 	current := this.GetNodeByRank(start)
 	traversed := start
-	datas := make([]*NodeData, 0, 4)
+	datas := make([]*NodeData[K, V], 0, 4)
 	// 在给定的排名范围内，依次遍历结点
 	for current != nil && traversed <= end {
-		next := current.Levels[0].Forward
-		datas = append(datas, current.Data)
+		state := this.state(current)
+		datas = append(datas, state.Data)
 		traversed++
-		current = next
+		current = state.Levels[0].Forward
 	}
 	return datas
 }
 
-func (this *SkipList) DeleteRangeByRank(start int, end int) []*NodeData {
+// 获取逆序排名(分数最高的结点逆序排名为1)
+// 没有找到返回0
+func (this *SkipList[K, V]) GetRevRank(data *NodeData[K, V]) int {
+	rank := this.GetRank(data)
+	if rank == 0 {
+		return 0
+	}
+	return this.Length - rank + 1
+}
+
+// 获得指定逆序排名范围的数据(按分数从高到低返回)
+// 借助最下层的Backward指针向后遍历
+func (this *SkipList[K, V]) GetRevRangeByRank(start int, end int) []*NodeData[K, V] {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	
+	datas := make([]*NodeData[K, V], 0, 4)
+	if start > this.Length {
+		return datas
+	}
+	// 逆序排名为start的结点，其正序排名就是Length-start+1
+	current := this.GetNodeByRank(this.Length - start + 1)
+	traversed := start
+	// 在给定的排名范围内，依次向后遍历结点
+	for current != nil && traversed <= end {
+		state := this.state(current)
+		datas = append(datas, state.Data)
+		traversed++
+		current = state.Backward
+	}
+	return datas
+}
+
+func (this *SkipList[K, V]) DeleteRangeByRank(start int, end int) []*NodeData[K, V] {
 	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
 	
 	// 注意这里，使用数组而不是切片，避免不必要的堆内存分配
 	// 当前这种情况，(只要该函数不返回数组)数组就是分配在栈上的
-	prevNodes := [SKIPLIST_MAXLEVEL]*Node{}
+	prevNodes := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
 	traversed := 0
 	prev := this.Head
-	var current *Node = nil
+	var current *Node[K, V] = nil
 	for i := this.Level - 1; i >= 0; i-- {
 		current = prev.Levels[i].Forward
 		// 走过的跨度小于指定的起始位置时继续在当前高度向右前进
@@ -474,7 +545,7 @@ func (this *SkipList) DeleteRangeByRank(start int, end int) []*NodeData {
 	assert.Assert(traversed == start,
 		"traversed must equal to start. traversed:", traversed, " start:", start)
 	// 在给定的排名范围内，依次删除结点
-	deleted := make([]*NodeData, 0, 4)
+	deleted := make([]*NodeData[K, V], 0, 4)
 	for current != nil && traversed <= end {
 		next := current.Levels[0].Forward
 		this.deleteNode(current, &prevNodes)
@@ -489,7 +560,7 @@ func (this *SkipList) DeleteRangeByRank(start int, end int) []*NodeData {
  Score相关操作
  */
 
-func (this *SkipList) UpdateScore(data *NodeData, newScore float64) (*Node, bool) {
+func (this *SkipList[K, V]) UpdateScore(data *NodeData[K, V], newScore float64) (*Node[K, V], bool) {
 	// 断言(不允许传入nil)
 	assert.Assert(data != nil, "data must not be nil")
 	// 断言(判断传入的分数值)
@@ -498,7 +569,7 @@ func (this *SkipList) UpdateScore(data *NodeData, newScore float64) (*Node, bool
 	
 	// 注意这里，使用数组而不是切片，避免不必要的堆内存分配
 	// 当前这种情况，(只要该函数不返回数组)数组就是分配在栈上的
-	prevNodes := [SKIPLIST_MAXLEVEL]*Node{}
+	prevNodes := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
 	current, ok := this.findNode(data, &prevNodes)
 	if !ok {
 		// 找不到指定结点，就返回
@@ -508,9 +579,14 @@ func (this *SkipList) UpdateScore(data *NodeData, newScore float64) (*Node, bool
 	// 先看能不能复用之前的结点对象
 	// 如果新分数和旧的分数的位置一样不会变化的话就可以复用之前的旧结点
 	// 那么就只需要更新结点的分数即可
-	if (current.Backward == nil || current.Backward.Data.Score < newScore) &&
-		(current.Levels[0].Forward == nil || current.Levels[0].Forward.Data.Score > newScore) {
+	// 分数相同时还要比较卫星数据(排行榜中分数相同的情况很常见)
+	updated := NodeData[K, V]{Key: data.Key, Score: newScore, Val: data.Val}
+	if (current.Backward == nil || this.dataLessThan(current.Backward.Data, &updated)) &&
+		(current.Levels[0].Forward == nil || this.dataLessThan(&updated, current.Levels[0].Forward.Data)) {
 		current.Data.Score = newScore
+		if this.SumEnabled {
+			this.updateSums(&prevNodes, nil)
+		}
 		return current, true
 	}
 	
@@ -537,7 +613,7 @@ func scoreLessThanMax(score float64, r *RangeSpecified) bool{
 	}
 }
 
-func (this *SkipList) isInRange(r *RangeSpecified) bool{
+func (this *SkipList[K, V]) isInRange(r *RangeSpecified) bool{
 	if r.Min > r.Max ||
 		(r.Min == r.Max &&
 			(r.MinExclusive || r.MaxExclusive)) {
@@ -549,37 +625,37 @@ func (this *SkipList) isInRange(r *RangeSpecified) bool{
 	// 即尾结点的分数要比范围的最小值大才合法
 	last := this.Tail
 	if last == nil ||
-		!scoreGeaterThanMin(last.Data.Score, r) {
+		!scoreGeaterThanMin(this.state(last).Data.Score, r) {
 		return false
 	}
 	
 	// 再判断最左边值是否合法
 	// 即第一个结点的分数要比范围的最大值小才合法
-	first := this.Head.Levels[0].Forward
+	first := this.state(this.Head).Levels[0].Forward
 	if first == nil ||
-		!scoreLessThanMax(first.Data.Score, r) {
+		!scoreLessThanMax(this.state(first).Data.Score, r) {
 		return false
 	}
 	
 	return true
 }
 
-func (this *SkipList) FirstInRange(r *RangeSpecified) *Node{
+func (this *SkipList[K, V]) FirstInRange(r *RangeSpecified) *Node[K, V]{
 	assert.Assert(r != nil, "r range cannot be nil")
 	if !this.isInRange(r) {
 		return nil
 	}
 	
 	prev := this.Head
-	var current *Node = nil
+	var current *Node[K, V] = nil
 	for i := this.Level-1; i >= 0; i-- {
-		current = prev.Levels[i].Forward
+		current = this.state(prev).Levels[i].Forward
 		// 如果当前结点的分数小于指定范围的最小分数
 		// 则继续在当前高度向右查找
 		for current != nil &&
-			!scoreGeaterThanMin(current.Data.Score, r) {
+			!scoreGeaterThanMin(this.state(current).Data.Score, r) {
 			prev = current
-			current = prev.Levels[i].Forward
+			current = this.state(prev).Levels[i].Forward
 		}
 		// 进入下一层继续查找
 	}
@@ -589,13 +665,13 @@ func (this *SkipList) FirstInRange(r *RangeSpecified) *Node{
 	
 	// 再判断一下找到的结点的分数
 	// 一定要比指定范围的最大值小才行
-	if !scoreLessThanMax(current.Data.Score, r) {
+	if !scoreLessThanMax(this.state(current).Data.Score, r) {
 		return nil
 	}
 	return current
 }
 
-func (this *SkipList) LastInRange(r *RangeSpecified) *Node{
+func (this *SkipList[K, V]) LastInRange(r *RangeSpecified) *Node[K, V]{
 	assert.Assert(r != nil, "r range cannot be nil")
 	if !this.isInRange(r) {
 		return nil
@@ -603,13 +679,13 @@ func (this *SkipList) LastInRange(r *RangeSpecified) *Node{
 	
 	prev := this.Head
 	for i := this.Level-1; i >= 0; i-- {
-		current := prev.Levels[i].Forward
+		current := this.state(prev).Levels[i].Forward
 		// 如果当前结点的分数小于指定范围的最大分数
 		// 则继续在当前高度向右查找
 		for current != nil &&
-			scoreLessThanMax(current.Data.Score, r) {
+			scoreLessThanMax(this.state(current).Data.Score, r) {
 			prev = current
-			current = prev.Levels[i].Forward
+			current = this.state(prev).Levels[i].Forward
 		}
 		// 进入下一层继续查找
 	}
@@ -619,31 +695,110 @@ func (this *SkipList) LastInRange(r *RangeSpecified) *Node{
 	
 	// 再判断一下找到的结点的分数
 	// 一定要比指定范围的最小值大才行
-	if !scoreGeaterThanMin(prev.Data.Score, r) {
+	if !scoreGeaterThanMin(this.state(prev).Data.Score, r) {
 		return nil
 	}
 	return prev
 }
 
-func (this *SkipList) GetRangeByScore(r *RangeSpecified) []*NodeData {
+func (this *SkipList[K, V]) GetRangeByScore(r *RangeSpecified) []*NodeData[K, V] {
 	current := this.FirstInRange(r)
-	datas := make([]*NodeData, 0, 4)
+	datas := make([]*NodeData[K, V], 0, 4)
 	// 从范围中最小的结点开始向右遍历，依次遍历结点
 	// 直到范围结束
-	for current != nil && scoreLessThanMax(current.Data.Score, r) {
-		next := current.Levels[0].Forward
-		datas = append(datas, current.Data)
-		current = next
+	for current != nil && scoreLessThanMax(this.state(current).Data.Score, r) {
+		state := this.state(current)
+		datas = append(datas, state.Data)
+		current = state.Levels[0].Forward
+	}
+	return datas
+}
+
+// 按分数从高到低返回指定分数范围内的数据
+func (this *SkipList[K, V]) GetRevRangeByScore(r *RangeSpecified) []*NodeData[K, V] {
+	current := this.LastInRange(r)
+	datas := make([]*NodeData[K, V], 0, 4)
+	// 从范围中最大的结点开始向左遍历，依次遍历结点
+	// 直到范围结束
+	for current != nil && scoreGeaterThanMin(this.state(current).Data.Score, r) {
+		state := this.state(current)
+		datas = append(datas, state.Data)
+		current = state.Backward
 	}
 	return datas
 }
 
-func (this *SkipList) DeleteRangeByScore(r *RangeSpecified) []*NodeData {
+// 分数范围内首尾结点的排名
+// 范围内没有结点时返回(0, 0)
+func (this *SkipList[K, V]) rankRangeByScore(r *RangeSpecified) (int, int) {
+	first := this.FirstInRange(r)
+	if first == nil {
+		return 0, 0
+	}
+	last := this.LastInRange(r)
+	assert.Assert(last != nil, "first存在时last一定存在")
+	return this.GetRank(this.state(first).Data), this.GetRank(this.state(last).Data)
+}
+
+// 统计分数范围内的结点数量
+// 通过范围内首尾结点的排名相减得到,不需要遍历范围内的结点
+// 时间复杂度O(logn)
+func (this *SkipList[K, V]) CountByScore(r *RangeSpecified) int {
+	assert.Assert(r != nil, "r range cannot be nil")
+	start, end := this.rankRangeByScore(r)
+	if start == 0 {
+		return 0
+	}
+	return end - start + 1
+}
+
+// 分页获取分数范围内的数据(参考redis的ZRANGEBYSCORE ... LIMIT offset count)
+// 跳过范围内的前offset个结点,最多返回count个结点(count小于0表示不限制数量)
+// 借助跨度直接定位到第offset个结点，不需要在最下层逐个跳过，时间复杂度O(logn+count)
+func (this *SkipList[K, V]) GetRangeByScoreLimit(r *RangeSpecified, offset int, count int) []*NodeData[K, V] {
+	assert.Assert(r != nil, "r range cannot be nil")
+	assert.Assert(offset >= 0, "offset must not be negative:", offset)
+	
+	start, end := this.rankRangeByScore(r)
+	start += offset
+	if start == offset || start > end || count == 0 {
+		// 范围内没有结点，或者跳过了所有结点
+		return []*NodeData[K, V]{}
+	}
+	if count > 0 {
+		end = min(end, start+count-1)
+	}
+	return this.GetRangeByRank(start, end)
+}
+
+// 分页获取分数范围内的数据,按分数从高到低返回(参考redis的ZREVRANGEBYSCORE ... LIMIT offset count)
+// 跳过范围内分数最高的offset个结点,最多返回count个结点(count小于0表示不限制数量)
+func (this *SkipList[K, V]) GetRevRangeByScoreLimit(r *RangeSpecified, offset int, count int) []*NodeData[K, V] {
+	assert.Assert(r != nil, "r range cannot be nil")
+	assert.Assert(offset >= 0, "offset must not be negative:", offset)
+	
+	first, last := this.rankRangeByScore(r)
+	if first == 0 || count == 0 {
+		return []*NodeData[K, V]{}
+	}
+	// 转换成逆序排名
+	start := this.Length - last + 1 + offset
+	end := this.Length - first + 1
+	if start > end {
+		return []*NodeData[K, V]{}
+	}
+	if count > 0 {
+		end = min(end, start+count-1)
+	}
+	return this.GetRevRangeByRank(start, end)
+}
+
+func (this *SkipList[K, V]) DeleteRangeByScore(r *RangeSpecified) []*NodeData[K, V] {
 	// 注意这里，使用数组而不是切片，避免不必要的堆内存分配
 	// 当前这种情况，(只要该函数不返回数组)数组就是分配在栈上的
-	prevNodes := [SKIPLIST_MAXLEVEL]*Node{}
+	prevNodes := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
 	prev := this.Head
-	var current *Node = nil
+	var current *Node[K, V] = nil
 	for i := this.Level - 1; i >= 0; i-- {
 		current = prev.Levels[i].Forward
 		// 当前结点的分数小于指定的范围的最小分数就继续在当前高度向右查找
@@ -657,7 +812,7 @@ func (this *SkipList) DeleteRangeByScore(r *RangeSpecified) []*NodeData {
 	}
 	// 循环结束，找到在指定范围中最小的结点(如果没有就是nil)
 	
-	deleted := make([]*NodeData, 0, 4)
+	deleted := make([]*NodeData[K, V], 0, 4)
 	// 从范围中最小的结点开始向右遍历，依次删除结点
 	// 直到范围结束
 	for current != nil && scoreLessThanMax(current.Data.Score, r) {
@@ -673,27 +828,46 @@ func (this *SkipList) DeleteRangeByScore(r *RangeSpecified) []*NodeData {
  Value相关操作
  */
 
-func valueGeaterThanMin(val Value, r *ValueRangeSpecified) bool {
+// 比较器作为参数，其他有序结构(比如树堆)也可以使用
+func valueGeaterThanMin[V any](cmp Comparator[V], val V, r *ValueRangeSpecified[V]) bool {
+	if r.MinInf {
+		// 没有下界
+		return true
+	}
 	if r.MinExclusive {
-		return ValueGreaterThan(val, r.Max)
+		return cmp(val, r.Min) > 0
 	} else {
 		// 不小于，则认为就是大于等于
-		return !val.LessThan(r.Min)
+		return cmp(val, r.Min) >= 0
 	}
 }
 
-func valueLessThanMax(val Value, r *ValueRangeSpecified) bool{
+func valueLessThanMax[V any](cmp Comparator[V], val V, r *ValueRangeSpecified[V]) bool{
+	if r.MaxInf {
+		// 没有上界
+		return true
+	}
 	if r.MaxExclusive {
-		return val.LessThan(r.Max)
+		return cmp(val, r.Max) < 0
 	} else {
 		// 小于或者等于
-		return val.LessThan(r.Max) || val.EqualTo(r.Max)
+		return cmp(val, r.Max) <= 0
 	}
 }
 
-func (this *SkipList) isInValueRange(r *ValueRangeSpecified) bool{
-	if ValueGreaterThan(r.Min, r.Max) ||
-		(r.Min.EqualTo(r.Max) && (r.MinExclusive || r.MaxExclusive)) {
+func (this *SkipList[K, V]) valueGeaterThanMin(val V, r *ValueRangeSpecified[V]) bool {
+	return valueGeaterThanMin(this.Cmp, val, r)
+}
+
+func (this *SkipList[K, V]) valueLessThanMax(val V, r *ValueRangeSpecified[V]) bool{
+	return valueLessThanMax(this.Cmp, val, r)
+}
+
+func (this *SkipList[K, V]) isInValueRange(r *ValueRangeSpecified[V]) bool{
+	// 上下界都存在时，才需要判断范围本身是否合法
+	if !r.MinInf && !r.MaxInf &&
+		(this.Cmp(r.Min, r.Max) > 0 ||
+			(this.Cmp(r.Min, r.Max) == 0 && (r.MinExclusive || r.MaxExclusive))) {
 		// 指定范围不合法
 		return false
 	}
@@ -701,36 +875,36 @@ func (this *SkipList) isInValueRange(r *ValueRangeSpecified) bool{
 	// 判断最右边值边界是否合法
 	// 即尾结点的值要比范围的最小值大才合法
 	last := this.Tail
-	if last == nil || !valueGeaterThanMin(last.Data.Val, r) {
+	if last == nil || !this.valueGeaterThanMin(this.state(last).Data.Val, r) {
 		return false
 	}
 	
 	// 再判断最左边值是否合法
 	// 即第一个结点的值要比范围的最大值小才合法
-	first := this.Head.Levels[0].Forward
-	if first == nil || !valueLessThanMax(first.Data.Val, r) {
+	first := this.state(this.Head).Levels[0].Forward
+	if first == nil || !this.valueLessThanMax(this.state(first).Data.Val, r) {
 		return false
 	}
 	
 	return true
 }
 
-func (this *SkipList) FirstInValueRange(r *ValueRangeSpecified) *Node{
+func (this *SkipList[K, V]) FirstInValueRange(r *ValueRangeSpecified[V]) *Node[K, V]{
 	assert.Assert(r != nil, "r range cannot be nil")
 	if !this.isInValueRange(r) {
 		return nil
 	}
 	
 	prev := this.Head
-	var current *Node = nil
+	var current *Node[K, V] = nil
 	for i := this.Level-1; i >= 0; i-- {
-		current = prev.Levels[i].Forward
+		current = this.state(prev).Levels[i].Forward
 		// 如果当前结点的值小于指定范围的最小值
 		// 则继续在当前高度向右查找
 		for current != nil &&
-			!valueGeaterThanMin(current.Data.Val, r) {
+			!this.valueGeaterThanMin(this.state(current).Data.Val, r) {
 			prev = current
-			current = prev.Levels[i].Forward
+			current = this.state(prev).Levels[i].Forward
 		}
 		// 进入下一层继续查找
 	}
@@ -740,13 +914,13 @@ func (this *SkipList) FirstInValueRange(r *ValueRangeSpecified) *Node{
 	
 	// 再判断一下找到的结点的值
 	// 一定要比指定范围的最大值小才行
-	if !valueLessThanMax(current.Data.Val, r) {
+	if !this.valueLessThanMax(this.state(current).Data.Val, r) {
 		return nil
 	}
 	return current
 }
 
-func (this *SkipList) LastInValueRange(r *ValueRangeSpecified) *Node{
+func (this *SkipList[K, V]) LastInValueRange(r *ValueRangeSpecified[V]) *Node[K, V]{
 	assert.Assert(r != nil, "r range cannot be nil")
 	if !this.isInValueRange(r) {
 		return nil
@@ -754,13 +928,13 @@ func (this *SkipList) LastInValueRange(r *ValueRangeSpecified) *Node{
 	
 	prev := this.Head
 	for i := this.Level-1; i >= 0; i-- {
-		current := prev.Levels[i].Forward
+		current := this.state(prev).Levels[i].Forward
 		// 如果当前结点的值小于指定范围的最大值
 		// 则继续在当前高度向右查找
 		for current != nil &&
-			valueLessThanMax(current.Data.Val, r) {
+			this.valueLessThanMax(this.state(current).Data.Val, r) {
 			prev = current
-			current = prev.Levels[i].Forward
+			current = this.state(prev).Levels[i].Forward
 		}
 		// 进入下一层继续查找
 	}
@@ -770,24 +944,65 @@ func (this *SkipList) LastInValueRange(r *ValueRangeSpecified) *Node{
 	
 	// 再判断一下找到的结点的值
 	// 一定要比指定范围的最小值大才行
-	if !valueGeaterThanMin(prev.Data.Val, r) {
+	if !this.valueGeaterThanMin(this.state(prev).Data.Val, r) {
 		return nil
 	}
 	return prev
 }
 
-func (this *SkipList) DeleteRangeByValue(r *ValueRangeSpecified) []*NodeData {
+func (this *SkipList[K, V]) GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	current := this.FirstInValueRange(r)
+	datas := make([]*NodeData[K, V], 0, 4)
+	// 从范围中最小的结点开始向右遍历，依次遍历结点
+	// 直到范围结束
+	for current != nil && this.valueLessThanMax(this.state(current).Data.Val, r) {
+		state := this.state(current)
+		datas = append(datas, state.Data)
+		current = state.Levels[0].Forward
+	}
+	return datas
+}
+
+// 值范围内首尾结点的排名
+// 范围内没有结点时返回(0, 0)
+func (this *SkipList[K, V]) rankRangeByValue(r *ValueRangeSpecified[V]) (int, int) {
+	first := this.FirstInValueRange(r)
+	if first == nil {
+		return 0, 0
+	}
+	last := this.LastInValueRange(r)
+	assert.Assert(last != nil, "first存在时last一定存在")
+	return this.GetRank(this.state(first).Data), this.GetRank(this.state(last).Data)
+}
+
+// 统计值范围内的结点数量
+// 时间复杂度O(logn)
+func (this *SkipList[K, V]) CountByValue(r *ValueRangeSpecified[V]) int {
+	assert.Assert(r != nil, "r range cannot be nil")
+	start, end := this.rankRangeByValue(r)
+	if start == 0 {
+		return 0
+	}
+	return end - start + 1
+}
+
+func (this *SkipList[K, V]) DeleteRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	assert.Assert(r != nil, "r range cannot be nil")
+	if !this.isInValueRange(r) {
+		return []*NodeData[K, V]{}
+	}
+	
 	// 注意这里，使用数组而不是切片，避免不必要的堆内存分配
 	// 当前这种情况，(只要该函数不返回数组)数组就是分配在栈上的
-	prevNodes := [SKIPLIST_MAXLEVEL]*Node{}
+	prevNodes := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
 	prev := this.Head
-	var current *Node = nil
+	var current *Node[K, V] = nil
 	for i := this.Level - 1; i >= 0; i-- {
 		current = prev.Levels[i].Forward
 		// 当前结点的值小于指定的范围的最小值就继续在当前高度向右查找
 		// !valueGeaterThanMin == ValueLessThanMin
 		for current != nil &&
-			!valueGeaterThanMin(current.Data.Val, r) {
+			!this.valueGeaterThanMin(current.Data.Val, r) {
 			prev = current
 			current = prev.Levels[i].Forward
 		}
@@ -795,10 +1010,10 @@ func (this *SkipList) DeleteRangeByValue(r *ValueRangeSpecified) []*NodeData {
 	}
 	// 循环结束，找到在指定范围中最小的结点(如果没有就是nil)
 	
-	deleted := make([]*NodeData, 0, 4)
+	deleted := make([]*NodeData[K, V], 0, 4)
 	// 从范围中最小的结点开始向右遍历，依次删除结点
 	// 直到范围结束
-	for current != nil && valueLessThanMax(current.Data.Val, r) {
+	for current != nil && this.valueLessThanMax(current.Data.Val, r) {
 		next := current.Levels[0].Forward
 		this.deleteNode(current, &prevNodes)
 		deleted = append(deleted, current.Data)
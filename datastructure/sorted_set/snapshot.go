@@ -0,0 +1,408 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 有序集合的二进制快照
+// 快照格式(整数均为小端序):
+// | 魔数"YYSS"(4字节) | 版本号(2字节) | 提升结点高度的概率(float32,4字节) | 元素数量(uvarint) |
+// | 元素1 | 元素2 | ... | 元素n | 过期时间数量(uvarint,版本2) | 过期时间1 | ... | 过期时间m(版本2) | 校验和(crc32,4字节) |
+// 每个元素:
+// | 分数(float64,8字节) | key长度(uvarint) | key | 卫星数据长度(uvarint) | 卫星数据 |
+// 每个过期时间:
+// | key长度(uvarint) | key | 过期时间(unix纳秒,int64,8字节) |
+// 元素按照分数从低到高依次写入，加载时可以按顺序追加到表尾，O(n)重建跳跃表
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	SNAPSHOT_MAGIC      = "YYSS" // 快照文件的魔数
+	SNAPSHOT_VERSION    = 2      // 当前快照格式的版本号(版本1没有过期时间，仍然可以读取)
+	SNAPSHOT_BACKUP_EXT = ".bak" // 上一次快照的备份文件的后缀(只保留一份)
+)
+
+var (
+	ErrSnapshotMagic    = errors.New("快照:魔数不正确")
+	ErrSnapshotVersion  = errors.New("快照:不支持的版本号")
+	ErrSnapshotChecksum = errors.New("快照:校验和不一致")
+	ErrSnapshotOrder    = errors.New("快照:元素不是严格有序的")
+	ErrSnapshotKey      = errors.New("快照:key重复")
+)
+
+// 写入数据的同时计算校验和
+type checksumWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (this *checksumWriter) write(p []byte) error {
+	this.crc.Write(p)
+	_, err := this.w.Write(p)
+	return err
+}
+
+func (this *checksumWriter) writeUvarint(n uint64) error {
+	l := binary.PutUvarint(this.buf[:], n)
+	return this.write(this.buf[:l])
+}
+
+func (this *checksumWriter) writeBytes(p []byte) error {
+	if err := this.writeUvarint(uint64(len(p))); err != nil {
+		return err
+	}
+	return this.write(p)
+}
+
+// 读取数据的同时计算校验和
+type checksumReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (this *checksumReader) read(p []byte) error {
+	if _, err := io.ReadFull(this.r, p); err != nil {
+		return err
+	}
+	this.crc.Write(p)
+	return nil
+}
+
+func (this *checksumReader) ReadByte() (byte, error) {
+	b, err := this.r.ReadByte()
+	if err != nil {
+		return b, err
+	}
+	this.crc.Write([]byte{b})
+	return b, nil
+}
+
+func (this *checksumReader) readUvarint() (uint64, error) {
+	return binary.ReadUvarint(this)
+}
+
+func (this *checksumReader) readBytes() ([]byte, error) {
+	n, err := this.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("快照:数据长度不正确: %d", n)
+	}
+	// 数据可能损坏，不能直接按照记录的长度分配内存
+	buf := &bytes.Buffer{}
+	if _, err = io.CopyN(buf, this.r, int64(n)); err != nil {
+		return nil, err
+	}
+	this.crc.Write(buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+// 按分数从低到高写入所有元素
+func (this *SortedSet[K, V]) WriteSnapshot(w io.Writer, kc Codec[K], vc Codec[V]) error {
+	this.expireDue()
+	cw := &checksumWriter{
+		w:   bufio.NewWriter(w),
+		crc: crc32.NewIEEE(),
+	}
+	header := make([]byte, 0, 10)
+	header = append(header, SNAPSHOT_MAGIC...)
+	header = binary.LittleEndian.AppendUint16(header, SNAPSHOT_VERSION)
+	_, prob, _ := this.params()
+	header = binary.LittleEndian.AppendUint32(header, math.Float32bits(prob))
+	if err := cw.write(header); err != nil {
+		return err
+	}
+	if err := cw.writeUvarint(uint64(this.length())); err != nil {
+		return err
+	}
+	
+	scoreBuf := make([]byte, 8)
+	var err error
+	this.list().walk(func(_ int, data *NodeData[K, V]) bool {
+		binary.LittleEndian.PutUint64(scoreBuf, math.Float64bits(data.Score))
+		if err = cw.write(scoreBuf); err != nil {
+			return false
+		}
+		var key, val []byte
+		if key, err = kc.Encode(data.Key); err != nil {
+			return false
+		}
+		if err = cw.writeBytes(key); err != nil {
+			return false
+		}
+		if val, err = vc.Encode(data.Val); err != nil {
+			return false
+		}
+		err = cw.writeBytes(val)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	// 过期时间
+	var expireAt map[K]int64
+	if this.expire != nil {
+		expireAt = this.expire.expireAt
+	}
+	if err := cw.writeUvarint(uint64(len(expireAt))); err != nil {
+		return err
+	}
+	for key, at := range expireAt {
+		keyBytes, err := kc.Encode(key)
+		if err != nil {
+			return err
+		}
+		if err = cw.writeBytes(keyBytes); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(scoreBuf, uint64(at))
+		if err = cw.write(scoreBuf); err != nil {
+			return err
+		}
+	}
+	
+	// 校验和本身不参与计算
+	checksum := binary.LittleEndian.AppendUint32(nil, cw.crc.Sum32())
+	if _, err := cw.w.Write(checksum); err != nil {
+		return err
+	}
+	return cw.w.Flush()
+}
+
+// 读取快照，替换有序集合中原有的所有元素
+// 读取失败时，有序集合保持不变
+func (this *SortedSet[K, V]) ReadSnapshot(r io.Reader, kc Codec[K], vc Codec[V]) error {
+	cr := &checksumReader{
+		r:   bufio.NewReader(r),
+		crc: crc32.NewIEEE(),
+	}
+	header := make([]byte, 10)
+	if err := cr.read(header); err != nil {
+		return err
+	}
+	if string(header[:4]) != SNAPSHOT_MAGIC {
+		return ErrSnapshotMagic
+	}
+	version := binary.LittleEndian.Uint16(header[4:6])
+	if version != 1 && version != SNAPSHOT_VERSION {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	prob := math.Float32frombits(binary.LittleEndian.Uint32(header[6:10]))
+	if !(prob >= 0 && prob < 1) {
+		return fmt.Errorf("快照:提升结点高度的概率不正确: %v", prob)
+	}
+	length, err := cr.readUvarint()
+	if err != nil {
+		return err
+	}
+	
+	cmp, _, sumEnabled := this.params()
+	sl := NewSkipListByParams[K, V](cmp, prob)
+	sl.SumEnabled = sumEnabled
+	// 快照数据可能损坏，不能完全相信其中记录的长度
+	hashMap := make(map[K]*NodeData[K, V], min(length, 1<<16))
+	builder := newSkipListBuilder(sl)
+	scoreBuf := make([]byte, 8)
+	for i := uint64(0); i < length; i++ {
+		if err = cr.read(scoreBuf); err != nil {
+			return err
+		}
+		score := math.Float64frombits(binary.LittleEndian.Uint64(scoreBuf))
+		if math.IsNaN(score) {
+			return fmt.Errorf("快照:分数不是数字, index:%d", i)
+		}
+		keyBytes, err := cr.readBytes()
+		if err != nil {
+			return err
+		}
+		key, err := kc.Decode(keyBytes)
+		if err != nil {
+			return err
+		}
+		valBytes, err := cr.readBytes()
+		if err != nil {
+			return err
+		}
+		val, err := vc.Decode(valBytes)
+		if err != nil {
+			return err
+		}
+		
+		data := NewNodeData(key, score, val)
+		if _, has := hashMap[key]; has {
+			return fmt.Errorf("%w: %v", ErrSnapshotKey, key)
+		}
+		if sl.Tail != nil && !sl.dataLessThan(sl.Tail.Data, data) {
+			return fmt.Errorf("%w: index:%d", ErrSnapshotOrder, i)
+		}
+		builder.append(data)
+		hashMap[key] = data
+	}
+	
+	var expireAt map[K]int64
+	if version >= 2 {
+		count, err := cr.readUvarint()
+		if err != nil {
+			return err
+		}
+		expireAt = make(map[K]int64, min(count, 1<<16))
+		for i := uint64(0); i < count; i++ {
+			keyBytes, err := cr.readBytes()
+			if err != nil {
+				return err
+			}
+			key, err := kc.Decode(keyBytes)
+			if err != nil {
+				return err
+			}
+			if _, has := hashMap[key]; !has {
+				return fmt.Errorf("快照:过期时间对应的元素不存在: %v", key)
+			}
+			if err = cr.read(scoreBuf); err != nil {
+				return err
+			}
+			expireAt[key] = int64(binary.LittleEndian.Uint64(scoreBuf))
+		}
+	}
+	
+	expected := cr.crc.Sum32()
+	checksum := make([]byte, 4)
+	if _, err = io.ReadFull(cr.r, checksum); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(checksum) != expected {
+		return ErrSnapshotChecksum
+	}
+	
+	this.Sl = builder.finish()
+	this.Hash = hashMap
+	this.compact = nil
+	// 新的跳跃表、哈希表和数据都不会被视图共享(视图继续使用原来的跳跃表和哈希表或者数组)
+	this.expire = nil
+	for key, at := range expireAt {
+		this.setExpireAt(key, at)
+	}
+	this.lengthMustEqual()
+	this.fitEncoding()
+	return nil
+}
+
+// 序列化为字节数组
+func (this *SortedSet[K, V]) MarshalSnapshot(kc Codec[K], vc Codec[V]) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := this.WriteSnapshot(buf, kc, vc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 从字节数组反序列化
+func (this *SortedSet[K, V]) UnmarshalSnapshot(data []byte, kc Codec[K], vc Codec[V]) error {
+	return this.ReadSnapshot(bytes.NewReader(data), kc, vc)
+}
+
+// 保存快照到指定文件
+// 先写入临时文件，再备份旧的快照文件(硬链接或者复制，不移动旧的快照文件)，最后将临时文件原子地重命名为指定文件
+// 这样无论在哪一步出错或者崩溃，指定文件都是一份完整的快照(旧的或者新的)
+// 备份文件是file加上SNAPSHOT_BACKUP_EXT，只保留上一次的快照
+func (this *SortedSet[K, V]) SaveSnapshot(file string, kc Codec[K], vc Codec[V]) error {
+	tmpFile := file + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if err = this.WriteSnapshot(f, kc, vc); err != nil {
+		f.Close()
+		os.Remove(tmpFile)
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpFile)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	
+	if err = backupSnapshot(file); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	if err = os.Rename(tmpFile, file); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	// 重命名也要刷盘，否则崩溃后目录中可能仍然是旧的快照文件
+	return syncDir(filepath.Dir(file))
+}
+
+// 备份旧的快照文件(旧的快照文件不存在时不需要备份)
+// 优先使用硬链接，文件系统不支持时复制
+func backupSnapshot(file string) error {
+	backup := file + SNAPSHOT_BACKUP_EXT
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err := os.Link(file, backup)
+	if err == nil || os.IsNotExist(err) {
+		return nil
+	}
+	src, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(backup)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(backup)
+		return err
+	}
+	return dst.Close()
+}
+
+// 从指定文件加载快照
+func (this *SortedSet[K, V]) LoadSnapshot(file string, kc Codec[K], vc Codec[V]) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return this.ReadSnapshot(f, kc, vc)
+}
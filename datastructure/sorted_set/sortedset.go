@@ -21,17 +21,29 @@ package sorted_set
 
 import (
 	"github.com/stormYuanYang/yytools/common/assert"
+	"sync/atomic"
+	"time"
 )
 
-type SortedSet struct {
-	Sl   *SkipList
-	Hash map[interface{}]*NodeData
+type SortedSet[K comparable, V any] struct {
+	Sl           *SkipList[K, V]       // 跳跃表编码时的跳跃表(紧凑编码时为nil)
+	Hash         map[K]*NodeData[K, V] // 跳跃表编码时的哈希表(紧凑编码时为nil)
+	compact      *compactList[K, V]    // 紧凑编码时的有序数组(见compact.go)
+	compactMax   int                   // 紧凑编码的最大元素数量(为0时不使用紧凑编码)
+	journal      *Journal[K, V]        // 操作日志(可选)
+	clock        func() time.Time      // 时钟(判断元素是否过期)
+	expire       *expireState[K]       // 元素的过期时间(设置过期时间后才会创建)
+	manualExpire bool                  // true:不做惰性删除，由调用者负责删除过期的元素
+	hooks        *hookState[K, V]      // 排名变化的钩子和阈值监听(注册后才会创建)
+	views        *atomic.Int64         // 没有释放的只读视图的数量(创建过视图后才会创建)
+	view         *viewRef              // 只读视图的引用计数(只读视图才有)
 }
 
-func NewSortedSet() *SortedSet {
-	return &SortedSet{
-		Sl:   NewSkipList(),
-		Hash: map[interface{}]*NodeData{},
+// cmp用于分数相同时比较卫星数据，决定元素的先后顺序
+func NewSortedSet[K comparable, V any](cmp Comparator[V]) *SortedSet[K, V] {
+	return &SortedSet[K, V]{
+		Sl:   NewSkipList[K, V](cmp),
+		Hash: map[K]*NodeData[K, V]{},
 	}
 }
 
@@ -39,103 +51,275 @@ func NewSortedSet() *SortedSet {
 	基本操作
 */
 
-func (this *SortedSet) Get(key interface{}) *NodeData {
-	assert.Assert(key != nil, "key == nil")
-	return this.Hash[key]
+func (this *SortedSet[K, V]) Get(key K) *NodeData[K, V] {
+	this.expireDue()
+	data, _ := this.lookup(key)
+	return data
 }
 
-func (this *SortedSet) Insert(data *NodeData) bool {
+func (this *SortedSet[K, V]) Insert(data *NodeData[K, V]) bool {
 	assert.Assert(data != nil, "data == nil")
-
-	if _, has := this.Hash[data.Key]; has {
+	this.expireDue()
+	
+	if _, has := this.lookup(data.Key); has {
 		// 不能重复插入
 		return false
 	}
 	
-	_, ok := this.Sl.Insert(data)
-	assert.Assert(ok, "insert must success, data.Key:", data.Key)
-	if ok {
-		this.Hash[data.Key] = data
+	this.beforeWrite()
+	ok := true
+	if this.compact != nil {
+		this.compact.insert(data)
+	} else {
+		_, ok = this.Sl.Insert(data)
+		assert.Assert(ok, "insert must success, data.Key:", data.Key)
+		if ok {
+			this.saveKey(data.Key)
+			this.Hash[data.Key] = data
+		}
+	}
+	if ok && this.journal != nil {
+		this.journal.appendInsert(data)
 	}
 	this.lengthMustEqual()
+	this.convertIfNeeded()
+	if ok && this.hooked() {
+		this.notifyChange(&ChangeEvent[K, V]{
+			Type:      ChangeInsert,
+			Data:      data,
+			NewScore:  data.Score,
+			NewRank:   this.list().GetRank(data),
+			OldLength: this.length() - 1,
+			NewLength: this.length(),
+		})
+	}
 	return ok
 }
 
-func (this *SortedSet) Delete(key interface{}) (*NodeData, bool) {
-	assert.Assert(key != nil, "key == nil")
+func (this *SortedSet[K, V]) Delete(key K) (*NodeData[K, V], bool) {
+	this.expireDue()
+	return this.deleteKey(key)
+}
 
-	data, exist := this.Hash[key]
+func (this *SortedSet[K, V]) deleteKey(key K) (*NodeData[K, V], bool) {
+	data, exist := this.lookup(key)
 	if !exist {
 		return nil, false
 	}
 	
-	if node, ok := this.Sl.Delete(data); ok {
+	this.beforeWrite()
+	oldRank := 0
+	if this.hooked() {
+		oldRank = this.list().GetRank(data)
+	}
+	ok := true
+	if this.compact != nil {
+		data = this.compact.remove(this.compact.indexOf(key))
+	} else {
+		var node *Node[K, V]
+		if node, ok = this.Sl.Delete(data); ok {
+			data = node.Data
+		}
+	}
+	if ok {
 		// 同步删除哈希表中的元素
-		delete(this.Hash, key)
+		this.unlinkHash(key)
 		this.lengthMustEqual()
-		return node.Data, ok
+		this.convertIfNeeded()
+		if this.journal != nil {
+			this.journal.appendDelete(key)
+		}
+		if this.hooked() {
+			this.notifyChange(&ChangeEvent[K, V]{
+				Type:      ChangeDelete,
+				Data:      data,
+				OldScore:  data.Score,
+				OldRank:   oldRank,
+				OldLength: this.length() + 1,
+				NewLength: this.length(),
+			})
+		}
+		return data, ok
 	} else {
 		return nil, ok
 	}
 }
 
-func (this *SortedSet) Length() int {
+func (this *SortedSet[K, V]) Length() int {
+	this.expireDue()
+	return this.length()
+}
+
+// 元素数量(不删除过期的元素)
+func (this *SortedSet[K, V]) length() int {
+	if this.compact != nil {
+		return len(this.compact.datas)
+	}
 	return this.Sl.Length
 }
 
-func (this *SortedSet) lengthMustEqual() {
+// 从哈希表中删除元素(同时删除其过期时间)
+// 紧凑编码时没有哈希表，只删除过期时间
+func (this *SortedSet[K, V]) unlinkHash(key K) {
+	if this.Hash != nil {
+		this.saveKey(key)
+		delete(this.Hash, key)
+	}
+	this.forgetExpire(key)
+}
+
+func (this *SortedSet[K, V]) lengthMustEqual() {
+	if this.compact != nil {
+		return
+	}
 	assert.Assert(this.Sl.Length == len(this.Hash),
 		"长度不一致 skiplist length:", this.Sl.Length, " hash length:", this.Hash)
 }
 
+// ZADD的选项(可以组合使用)
+type AddFlag int32
+
+const (
+	AddNX AddFlag = 1 << iota // 只添加新元素，不更新已存在的元素
+	AddXX                     // 只更新已存在的元素，不添加新元素
+	AddGT                     // 只有新分数大于旧分数时才更新(不影响添加新元素)
+	AddLT                     // 只有新分数小于旧分数时才更新(不影响添加新元素)
+	AddCH                     // 返回值表示元素是否发生了变化(添加或者分数被更新)，而不仅仅是否被添加
+)
+
+// 添加或者更新元素(参考redis的ZADD)
+// 元素已存在时只更新分数，卫星数据保持不变
+// 默认返回元素是否被添加；指定AddCH时，返回元素是否被添加或者分数是否被更新
+func (this *SortedSet[K, V]) Add(data *NodeData[K, V], flags AddFlag) bool {
+	return addData[K, V](this, data, flags)
+}
+
+// 增加元素的分数(参考redis的ZINCRBY)
+// 元素不存在时，以delta作为分数添加新元素，val作为其卫星数据(元素存在时忽略val)
+// 返回新的分数和排名；新的分数不是数字时(比如正无穷加负无穷)不做修改，返回false
+func (this *SortedSet[K, V]) IncrScore(key K, delta float64, val V) (float64, int, bool) {
+	return incrScore[K, V](this, key, delta, val)
+}
+
 /*
 	排名相关操作
 */
 
 // 获取排名
-func (this *SortedSet) GetRank(key interface{}) int {
-	assert.Assert(key != nil, "key == nil")
-	
-	data, exist := this.Hash[key]
+func (this *SortedSet[K, V]) GetRank(key K) int {
+	this.expireDue()
+	data, exist := this.lookup(key)
 	if !exist {
 		return 0
 	}
-	rank := this.Sl.GetRank(data)
+	rank := this.list().GetRank(data)
 	// 一定能找到排名(哈希表保证了元素一定存在)
 	assert.Assert(rank != 0, "rank must exist")
 	return rank
 }
 
 // 通过指定排名获得数据
-func (this *SortedSet) GetByRank(rank int) *NodeData {
+func (this *SortedSet[K, V]) GetByRank(rank int) *NodeData[K, V] {
 	assert.Assert(rank > 0, "rank must be positive number")
+	this.expireDue()
+	
+	return this.list().dataByRank(rank)
+}
 
-	node := this.Sl.GetNodeByRank(rank)
-	if node == nil {
+// 获得指定排名范围的数据
+func (this *SortedSet[K, V]) GetRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.expireDue()
+	if start > end {
+		start, end = end, start
+	}
+	return this.list().GetRangeByRank(start, end)
+}
+
+// 获取逆序排名(分数最高的元素逆序排名为1)
+// 参考redis的ZREVRANK
+func (this *SortedSet[K, V]) GetRevRank(key K) int {
+	this.expireDue()
+	rank := this.GetRank(key)
+	if rank == 0 {
+		return 0
+	}
+	return this.Length() - rank + 1
+}
+
+// 通过指定逆序排名获得数据
+func (this *SortedSet[K, V]) GetByRevRank(rank int) *NodeData[K, V] {
+	assert.Assert(rank > 0, "rank must be positive number")
+	this.expireDue()
+	
+	if rank > this.Length() {
 		return nil
 	}
-	return node.Data
+	return this.GetByRank(this.Length() - rank + 1)
 }
 
-// 获得指定排名范围的数据
-func (this *SortedSet) GetRangeByRank(start int, end int) []*NodeData {
+// 获得指定逆序排名范围的数据(按分数从高到低返回)
+// 参考redis的ZREVRANGE
+func (this *SortedSet[K, V]) GetRevRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.expireDue()
 	if start > end {
 		start, end = end, start
 	}
-	return this.Sl.GetRangeByRank(start, end)
+	return this.list().GetRevRangeByRank(start, end)
 }
 
 // 删除指定排名范围的数据
-func (this *SortedSet) DeleteRangeByRank(start int, end int) []*NodeData {
+func (this *SortedSet[K, V]) DeleteRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.expireDue()
 	if start > end {
 		start, end = end, start
 	}
-	deleted := this.Sl.DeleteRangeByRank(start, end)
+	this.beforeWrite()
+	oldLength := this.length()
+	deleted := this.list().DeleteRangeByRank(start, end)
 	// 同步删除哈希表中映射的数据
 	for _, one := range deleted {
-		delete(this.Hash, one.Key)
+		this.unlinkHash(one.Key)
 	}
 	this.lengthMustEqual()
+	this.convertIfNeeded()
+	if this.journal != nil && len(deleted) > 0 {
+		this.journal.appendDeleteRangeByRank(start, end)
+	}
+	if this.hooked() {
+		this.notifyRangeDelete(deleted, start, oldLength)
+	}
+	return deleted
+}
+
+// 删除并返回分数最低的count个元素(按分数从低到高返回)
+// 参考redis的ZPOPMIN
+func (this *SortedSet[K, V]) PopMin(count int) []*NodeData[K, V] {
+	assert.Assert(count > 0, "count must be positive number")
+	this.expireDue()
+	if this.Length() == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.DeleteRangeByRank(1, count)
+}
+
+// 删除并返回分数最高的count个元素(按分数从高到低返回)
+// 参考redis的ZPOPMAX
+func (this *SortedSet[K, V]) PopMax(count int) []*NodeData[K, V] {
+	assert.Assert(count > 0, "count must be positive number")
+	this.expireDue()
+	length := this.Length()
+	if length == 0 {
+		return []*NodeData[K, V]{}
+	}
+	start := length - count + 1
+	if start < 1 {
+		start = 1
+	}
+	deleted := this.DeleteRangeByRank(start, length)
+	// 删除得到的结果是从低到高的，需要反转
+	for i, j := 0, len(deleted)-1; i < j; i, j = i+1, j-1 {
+		deleted[i], deleted[j] = deleted[j], deleted[i]
+	}
 	return deleted
 }
 
@@ -144,21 +328,47 @@ func (this *SortedSet) DeleteRangeByRank(start int, end int) []*NodeData {
 */
 
 // 更新分数
-func (this *SortedSet) UpdateScore(key interface{}, newScore float64) (*NodeData, bool) {
-	data, exist := this.Hash[key]
+func (this *SortedSet[K, V]) UpdateScore(key K, newScore float64) (*NodeData[K, V], bool) {
+	this.expireDue()
+	data, exist := this.lookup(key)
 	if !exist {
 		return nil, false
 	}
-	node, ok := this.Sl.UpdateScore(data, newScore)
-	if !ok {
+	this.beforeWrite()
+	data = this.ownData(data)
+	oldScore, oldRank := data.Score, 0
+	if this.hooked() {
+		oldRank = this.list().GetRank(data)
+	}
+	if this.compact != nil {
+		this.compact.updateScore(data, newScore)
+	} else if node, ok := this.Sl.UpdateScore(data, newScore); !ok {
 		return nil, ok
+	} else {
+		data = node.Data
 	}
 	this.lengthMustEqual()
-	return node.Data, ok
+	if this.journal != nil {
+		this.journal.appendUpdateScore(key, newScore)
+	}
+	if this.hooked() {
+		this.notifyChange(&ChangeEvent[K, V]{
+			Type:      ChangeUpdate,
+			Data:      data,
+			OldScore:  oldScore,
+			NewScore:  data.Score,
+			OldRank:   oldRank,
+			NewRank:   this.list().GetRank(data),
+			OldLength: this.length(),
+			NewLength: this.length(),
+		})
+	}
+	return data, true
 }
 
 // 通过分数范围(开闭区间由调用者指定)得到若干数据
-func (this *SortedSet) GetRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData {
+func (this *SortedSet[K, V]) GetRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V] {
+	this.expireDue()
 	r := &RangeSpecified{
 		RangeSpecifiedBase: RangeSpecifiedBase{
 			MinExclusive: minEx,
@@ -167,11 +377,73 @@ func (this *SortedSet) GetRangeByScore(min float64, minEx bool, max float64, max
 		Min: min,
 		Max: max,
 	}
-	return this.Sl.GetRangeByScore(r)
+	return this.list().GetRangeByScore(r)
+}
+
+// 通过分数范围(开闭区间由调用者指定)得到若干数据,按分数从高到低返回
+// 参考redis的ZREVRANGEBYSCORE,注意参数顺序是先max后min
+func (this *SortedSet[K, V]) GetRevRangeByScore(max float64, maxEx bool, min float64, minEx bool) []*NodeData[K, V] {
+	this.expireDue()
+	r := &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+	return this.list().GetRevRangeByScore(r)
+}
+
+// 分页获取分数范围(开闭区间由调用者指定)内的数据
+// 跳过前offset个数据，最多返回count个(count小于0表示不限制数量)
+// 参考redis的ZRANGEBYSCORE ... LIMIT offset count
+func (this *SortedSet[K, V]) GetRangeByScoreLimit(min float64, minEx bool, max float64, maxEx bool, offset int, count int) []*NodeData[K, V] {
+	this.expireDue()
+	r := &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+	return this.list().GetRangeByScoreLimit(r, offset, count)
+}
+
+// 分页获取分数范围(开闭区间由调用者指定)内的数据,按分数从高到低返回
+// 参考redis的ZREVRANGEBYSCORE ... LIMIT offset count,注意参数顺序是先max后min
+func (this *SortedSet[K, V]) GetRevRangeByScoreLimit(max float64, maxEx bool, min float64, minEx bool, offset int, count int) []*NodeData[K, V] {
+	this.expireDue()
+	r := &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+	return this.list().GetRevRangeByScoreLimit(r, offset, count)
+}
+
+// 统计分数范围(开闭区间由调用者指定)内的数据数量
+// 参考redis的ZCOUNT,不需要遍历范围内的数据
+func (this *SortedSet[K, V]) CountByScore(min float64, minEx bool, max float64, maxEx bool) int {
+	this.expireDue()
+	r := &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+	return this.list().CountByScore(r)
 }
 
 // 通过分数范围(开闭区间由调用者指定)删除若干数据
-func (this *SortedSet) DeleteRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData {
+func (this *SortedSet[K, V]) DeleteRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V] {
+	this.expireDue()
 	r := &RangeSpecified{
 		RangeSpecifiedBase: RangeSpecifiedBase{
 			MinExclusive: minEx,
@@ -180,11 +452,66 @@ func (this *SortedSet) DeleteRangeByScore(min float64, minEx bool, max float64,
 		Min: min,
 		Max: max,
 	}
-	deleted := this.Sl.DeleteRangeByScore(r)
+	this.beforeWrite()
+	oldLength, firstRank := this.length(), 0
+	if this.hooked() {
+		firstRank, _ = this.list().rankRangeByScore(r)
+	}
+	deleted := this.list().DeleteRangeByScore(r)
 	// 同步删除哈希表中映射的数据
 	for _, one := range deleted {
-		delete(this.Hash, one.Key)
+		this.unlinkHash(one.Key)
 	}
 	this.lengthMustEqual()
+	this.convertIfNeeded()
+	if this.journal != nil && len(deleted) > 0 {
+		this.journal.appendDeleteRangeByScore(min, minEx, max, maxEx)
+	}
+	if this.hooked() {
+		this.notifyRangeDelete(deleted, firstRank, oldLength)
+	}
 	return deleted
-}
\ No newline at end of file
+}
+
+/*
+	值相关操作(按字典序的范围操作)
+	参考redis的ZRANGEBYLEX/ZLEXCOUNT/ZREMRANGEBYLEX
+	只有当所有元素的分数都相同时(比如同一档位中的成员),结果才有意义
+*/
+
+// 通过值范围(开闭区间、是否有界由调用者指定)得到若干数据
+func (this *SortedSet[K, V]) GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	this.expireDue()
+	return this.list().GetRangeByValue(r)
+}
+
+// 统计值范围内的数据数量
+// 通过范围内首尾结点的排名相减得到,不需要遍历范围内的结点
+func (this *SortedSet[K, V]) CountByValue(r *ValueRangeSpecified[V]) int {
+	this.expireDue()
+	return this.list().CountByValue(r)
+}
+
+// 通过值范围(开闭区间、是否有界由调用者指定)删除若干数据
+func (this *SortedSet[K, V]) DeleteRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	this.expireDue()
+	this.beforeWrite()
+	oldLength, firstRank := this.length(), 0
+	if this.hooked() {
+		firstRank, _ = this.list().rankRangeByValue(r)
+	}
+	deleted := this.list().DeleteRangeByValue(r)
+	// 同步删除哈希表中映射的数据
+	for _, one := range deleted {
+		this.unlinkHash(one.Key)
+	}
+	this.lengthMustEqual()
+	this.convertIfNeeded()
+	if this.journal != nil && len(deleted) > 0 {
+		this.journal.appendDeleteRangeByValue(r)
+	}
+	if this.hooked() {
+		this.notifyRangeDelete(deleted, firstRank, oldLength)
+	}
+	return deleted
+}
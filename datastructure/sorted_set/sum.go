@@ -0,0 +1,201 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 跳跃表的分数和(增强的跳跃表)
+// 和跨度一样，每一层额外记录跨度覆盖的结点(不包含自身)的分数和：
+// 1.最下层的分数和就是下一结点的分数
+// 2.上一层的分数和等于下一层从当前结点到(上一层)下一结点之间各个结点的分数和之和
+// 插入、删除、更新分数时，自下而上重新计算路径上前置结点的分数和(不做减法，避免浮点数误差累积)
+// 这样统计排名范围或者分数范围内分数的和只需要O(logn)
+// 分数是有序的，范围内分数的最小值和最大值就是首尾结点的分数，不需要额外维护
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+)
+
+// 开启分数和的维护(会重新计算所有结点的分数和,时间复杂度O(n))
+func (this *SkipList[K, V]) EnableSum() {
+	if this.SumEnabled {
+		return
+	}
+	this.SumEnabled = true
+	this.rebuildSums()
+}
+
+// 重新计算结点在指定高度的分数和(下一层的分数和需要已经是正确的)
+func (this *SkipList[K, V]) recomputeSum(node *Node[K, V], i int) {
+	this.save(node)
+	level := node.Levels[i]
+	if i == 0 {
+		if level.Forward != nil {
+			level.Sum = level.Forward.Data.Score
+		} else {
+			level.Sum = 0
+		}
+		return
+	}
+	sum := 0.0
+	for current := node; current != level.Forward; current = current.Levels[i-1].Forward {
+		sum += current.Levels[i-1].Sum
+	}
+	level.Sum = sum
+}
+
+// 插入、删除结点或者更新分数后，自下而上重新计算每一高度前置结点(和新结点)的分数和
+func (this *SkipList[K, V]) updateSums(prevNodes *[SKIPLIST_MAXLEVEL]*Node[K, V], newNode *Node[K, V]) {
+	for i := 0; i < this.Level; i++ {
+		this.recomputeSum(prevNodes[i], i)
+		if newNode != nil && i < newNode.High() {
+			this.recomputeSum(newNode, i)
+		}
+	}
+}
+
+// 重新计算所有结点的分数和
+func (this *SkipList[K, V]) rebuildSums() {
+	for i := 0; i < this.Level; i++ {
+		for current := this.Head; current != nil; current = current.Levels[i].Forward {
+			this.recomputeSum(current, i)
+		}
+	}
+}
+
+// 排名在(from, to]中的结点的分数和
+// 先定位到排名为from的结点，再尽量使用高层的分数和向前累加，时间复杂度O(logn)
+func (this *SkipList[K, V]) sumRange(from int, to int) float64 {
+	assert.Assert(this.SumEnabled, "需要先开启分数和的维护(EnableSum)")
+	assert.Assert(from >= 0 && from <= to && to <= this.Length, "rank范围不合法, from:", from, " to:", to)
+	
+	current := this.Head
+	if from > 0 {
+		current = this.GetNodeByRank(from)
+	}
+	rank := from
+	sum := 0.0
+	for rank < to {
+		// 找到跨度不超出范围的最高的一层
+		state := this.state(current)
+		i := min(state.High(), this.Level) - 1
+		for i > 0 && rank+state.Levels[i].Span > to {
+			i--
+		}
+		sum += state.Levels[i].Sum
+		rank += state.Levels[i].Span
+		current = state.Levels[i].Forward
+	}
+	return sum
+}
+
+// 指定排名范围内结点的分数和
+// 时间复杂度O(logn)
+func (this *SkipList[K, V]) SumByRank(start int, end int) float64 {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	end = min(end, this.Length)
+	if start > end {
+		return 0
+	}
+	return this.sumRange(start-1, end)
+}
+
+// 指定分数范围内结点的分数和
+// 时间复杂度O(logn)
+func (this *SkipList[K, V]) SumByScore(r *RangeSpecified) float64 {
+	assert.Assert(r != nil, "r range cannot be nil")
+	start, end := this.rankRangeByScore(r)
+	if start == 0 {
+		return 0
+	}
+	return this.sumRange(start-1, end)
+}
+
+/*
+	有序集合的分数统计
+*/
+
+// 开启分数和的维护，之后才能统计分数的和以及平均值
+func (this *SortedSet[K, V]) EnableSum() {
+	if this.compact != nil {
+		// 紧凑编码直接遍历求和，转换成跳跃表时再维护分数和
+		this.compact.sumEnabled = true
+		return
+	}
+	if !this.Sl.SumEnabled {
+		this.beforeWrite()
+	}
+	this.Sl.EnableSum()
+}
+
+// 统计排名范围内数据的分数和
+// 返回分数和以及数据数量
+func (this *SortedSet[K, V]) SumByRank(start int, end int) (float64, int) {
+	this.expireDue()
+	if start > end {
+		start, end = end, start
+	}
+	count := max(min(end, this.length())-start+1, 0)
+	return this.list().SumByRank(start, end), count
+}
+
+// 统计分数范围(开闭区间由调用者指定)内数据的分数和
+// 返回分数和以及数据数量
+func (this *SortedSet[K, V]) SumByScore(min float64, minEx bool, max float64, maxEx bool) (float64, int) {
+	this.expireDue()
+	r := &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+	return this.list().SumByScore(r), this.list().CountByScore(r)
+}
+
+// 排名范围内数据的平均分数(范围内没有数据时返回false)
+func (this *SortedSet[K, V]) AvgByRank(start int, end int) (float64, bool) {
+	sum, count := this.SumByRank(start, end)
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// 分数范围内数据的平均分数(范围内没有数据时返回false)
+func (this *SortedSet[K, V]) AvgByScore(min float64, minEx bool, max float64, maxEx bool) (float64, bool) {
+	sum, count := this.SumByScore(min, minEx, max, maxEx)
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// 排名范围内数据的最低分数和最高分数(范围内没有数据时返回false)
+// 分数是有序的，就是范围内首尾数据的分数，不需要开启分数和的维护
+func (this *SortedSet[K, V]) MinMaxByRank(start int, end int) (float64, float64, bool) {
+	assert.Assert(start > 0 && end > 0, "rank must be positive number, start:", start, " end:", end)
+	this.expireDue()
+	if start > end {
+		start, end = end, start
+	}
+	end = min(end, this.length())
+	if start > end {
+		return 0, 0, false
+	}
+	return this.list().dataByRank(start).Score, this.list().dataByRank(end).Score, true
+}
@@ -18,10 +18,19 @@
 package sorted_set
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/stormYuanYang/yytools/algorithm/math_tools/probability_distribution"
 	random2 "github.com/stormYuanYang/yytools/algorithm/math_tools/random"
 	"github.com/stormYuanYang/yytools/common/assert"
+	"iter"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -41,14 +50,77 @@ func NewVal() *Val {
 	}
 }
 
-func (this *Val) LessThan(data Value) bool {
-	x := data.(*Val)
-	return this.ID < x.ID
+// 分数相同时按照唯一id排序
+func CompareVal(a, b *Val) int {
+	if a.ID < b.ID {
+		return -1
+	}
+	if a.ID > b.ID {
+		return 1
+	}
+	return 0
+}
+
+// Val的编解码器(测试快照使用)
+type ValCodec struct{}
+
+func (ValCodec) Encode(v *Val) ([]byte, error) {
+	buf := binary.LittleEndian.AppendUint64(nil, uint64(v.ID))
+	buf = binary.AppendUvarint(buf, uint64(len(v.Name)))
+	buf = append(buf, v.Name...)
+	buf = append(buf, v.Meta...)
+	return buf, nil
+}
+
+func (ValCodec) Decode(data []byte) (*Val, error) {
+	if len(data) < 8 {
+		return nil, ErrCodecDataLength
+	}
+	v := &Val{ID: int64(binary.LittleEndian.Uint64(data))}
+	n, l := binary.Uvarint(data[8:])
+	if l <= 0 || uint64(len(data)-8-l) < n {
+		return nil, ErrCodecDataLength
+	}
+	v.Name = string(data[8+l : 8+l+int(n)])
+	v.Meta = string(data[8+l+int(n):])
+	return v, nil
+}
+
+// 测试使用的有序集合(以唯一id作为key)
+type TestSortedSet = SortedSet[int64, *Val]
+
+func NewTestSortedSet() *TestSortedSet {
+	ss := NewSortedSet[int64, *Val](CompareVal)
+	if random2.RandInt(0, 1) == 0 {
+		// 一半的有序集合开启分数和的维护，保证各种操作都能正确维护分数和
+		ss.EnableSum()
+	}
+	return ss
+}
+
+// 不同实现共用的测试操作使用接口
+type TestISortedSet = ISortedSet[int64, *Val]
+
+func NewTestTreapSortedSet() *TreapSortedSet[int64, *Val] {
+	return NewTreapSortedSet[int64, *Val](CompareVal)
 }
 
-func (this *Val) EqualTo(data Value) bool {
-	x := data.(*Val)
-	return this.ID == x.ID
+// 紧凑编码的阈值很小，随机操作时会频繁地转换编码(阈值为0时不使用紧凑编码)
+func NewTestCompactSortedSet() *TestSortedSet {
+	ss := NewTestSortedSet()
+	ss.SetCompactMaxEntries(random2.RandInt(0, 16))
+	return ss
+}
+
+// 比较两个数据的先后顺序(先比较分数，分数相同时比较卫星数据)
+func compareTestData(a, b *NodeData[int64, *Val]) int {
+	if a.Score != b.Score {
+		if a.Score < b.Score {
+			return -1
+		}
+		return 1
+	}
+	return CompareVal(a.Val, b.Val)
 }
 
 const (
@@ -56,28 +128,97 @@ const (
 	TEST_SORTED_SET_SCORE_MAX = 750
 )
 
-func SortedSetMustLegal(ss *SortedSet) {
+func SortedSetMustLegal(ss *TestSortedSet) {
 	ss.lengthMustEqual()
+	err := ss.Validate()
+	assert.Assert(err == nil, "有序集合的结构不合法:", err)
 	
-	current := ss.Sl.Head.Levels[0].Forward
-	rank := 1
-	for current != nil {
-		//print(int(current.Data.Score), "->")
-		if current.Levels[0].Forward != nil {
-			assert.Assert(current.Data.LessThan(current.Levels[0].Forward.Data),
-				"跳跃表表必须是有序的:", fmt.Sprintf("current:%+v, forward:%+v", current, current.Levels[0].Forward))
-
-			data := ss.GetByRank(rank)
-			assert.Assert(data != nil, "rank实现有问题:", rank)
-			assert.Assert(data.EqualTo(current.Data), "rank实现有问题", rank)
-			rank++
+	var prev *NodeData[int64, *Val]
+	for rank, data := range ss.All() {
+		if prev != nil {
+			assert.Assert(compareTestData(prev, data) < 0,
+				"跳跃表表必须是有序的:", fmt.Sprintf("prev:%+v, current:%+v", prev, data))
+		}
+		one := ss.GetByRank(rank)
+		assert.Assert(one != nil, "rank实现有问题:", rank)
+		assert.Assert(compareTestData(one, data) == 0, "rank实现有问题", rank)
+		assert.Assert(ss.Get(data.Key) == data && ss.GetRank(data.Key) == rank, "key和rank不一致:", data.Key)
+		prev = data
+	}
+	// 紧凑编码时数量不超过阈值；跳跃表编码时数量必须超过阈值的一半
+	if ss.Encoding() == EncodingCompact {
+		assert.Assert(ss.Sl == nil && ss.Hash == nil, "紧凑编码时跳跃表和哈希表必须是nil")
+		assert.Assert(ss.Length() <= ss.compactMax, "应该转换成跳跃表, length:", ss.Length())
+		return
+	}
+	assert.Assert(ss.compact == nil, "跳跃表编码时数组必须是nil")
+	assert.Assert(ss.compactMax == 0 || ss.Length() > ss.compactMax/2, "应该转换成紧凑编码, length:", ss.Length())
+	if ss.Sl.SumEnabled {
+		skipListSumMustLegal(ss.Sl)
+	}
+}
+
+// 树堆必须满足:中序遍历有序、优先级满足堆的性质、子树大小正确、和哈希表一致
+func TreapSortedSetMustLegal(ss *TreapSortedSet[int64, *Val]) {
+	ss.lengthMustEqual()
+	
+	var prev *NodeData[int64, *Val]
+	var walk func(node *TreapNode[int64, *Val]) int
+	walk = func(node *TreapNode[int64, *Val]) int {
+		if node == nil {
+			return 0
+		}
+		for _, child := range []*TreapNode[int64, *Val]{node.Left, node.Right} {
+			assert.Assert(child == nil || child.Priority <= node.Priority, "优先级不满足堆的性质:", node.Data.Key)
+		}
+		size := walk(node.Left)
+		if prev != nil {
+			assert.Assert(compareTestData(prev, node.Data) < 0,
+				"树堆必须是有序的:", fmt.Sprintf("prev:%+v, current:%+v", prev, node.Data))
+		}
+		prev = node.Data
+		assert.Assert(ss.Get(node.Data.Key) == node.Data, "哈希表不一致:", node.Data.Key)
+		size += walk(node.Right) + 1
+		assert.Assert(node.Size == size, "子树大小不正确:", node.Size, " ", size)
+		return size
+	}
+	walk(ss.Tp.Root)
+	
+	for rank, data := range ss.All() {
+		assert.Assert(ss.GetByRank(rank) == data && ss.GetRank(data.Key) == rank, "rank实现有问题:", rank)
+	}
+}
+
+// 根据具体的实现检查有序集合是否合法
+func ISortedSetMustLegal(ss TestISortedSet) {
+	switch one := ss.(type) {
+	case *TestSortedSet:
+		SortedSetMustLegal(one)
+	case *TreapSortedSet[int64, *Val]:
+		TreapSortedSetMustLegal(one)
+	default:
+		assert.Assert(false, "未知的有序集合实现")
+	}
+}
+
+// 每一层的分数和都要等于跨度覆盖的结点的分数之和(测试的分数都是整数，求和没有误差)
+func skipListSumMustLegal(sl *SkipList[int64, *Val]) {
+	for i := 0; i < sl.Level; i++ {
+		for current := sl.Head; current != nil; current = current.Levels[i].Forward {
+			sum := 0.0
+			next := current.Levels[0].Forward
+			for j := 0; j < current.Levels[i].Span; j++ {
+				sum += next.Data.Score
+				next = next.Levels[0].Forward
+			}
+			assert.Assert(current.Levels[i].Sum == sum, "分数和不正确, level:", i,
+				" sum:", current.Levels[i].Sum, " expected:", sum)
 		}
-		current = current.Levels[0].Forward
 	}
 }
 
 // 插入
-func SortedSetOp_Insert(ss *SortedSet, num int) {
+func SortedSetOp_Insert(ss TestISortedSet, num int) {
 	for i := 0; i < num; i++ {
 		n := random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX)
 		val := NewVal()
@@ -87,7 +228,7 @@ func SortedSetOp_Insert(ss *SortedSet, num int) {
 }
 
 // 删除
-func SortedSetOp_Delete(ss *SortedSet, num int) {
+func SortedSetOp_Delete(ss TestISortedSet, num int) {
 	for i := 0; i < num; i++ {
 		if ss.Length() > 0 {
 			randomRank := random2.RandInt(1, ss.Length())
@@ -99,7 +240,7 @@ func SortedSetOp_Delete(ss *SortedSet, num int) {
 }
 
 // 更新分数
-func SortedSetOp_UpdateScore(ss *SortedSet, num int) {
+func SortedSetOp_UpdateScore(ss TestISortedSet, num int) {
 	for i := 0; i < num; i++ {
 		if ss.Length() > 0 {
 			randomRank := random2.RandInt(1, ss.Length())
@@ -113,8 +254,99 @@ func SortedSetOp_UpdateScore(ss *SortedSet, num int) {
 	}
 }
 
+// 按照ZADD的选项添加或者更新元素
+func SortedSetOp_Add(ss TestISortedSet, num int) {
+	flagsList := []AddFlag{0, AddNX, AddXX, AddGT, AddLT, AddCH, AddXX | AddGT | AddCH, AddLT | AddCH, AddNX | AddCH}
+	for i := 0; i < num; i++ {
+		var data *NodeData[int64, *Val]
+		if ss.Length() > 0 && random2.RandInt(0, 1) == 1 {
+			// 更新已存在的元素
+			old := ss.GetByRank(random2.RandInt(1, ss.Length()))
+			data = NewNodeData(old.Key, old.Score, old.Val)
+		} else {
+			val := NewVal()
+			data = NewNodeData(val.ID, 0, val)
+		}
+		data.Score = float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		flags := flagsList[random2.RandInt(0, len(flagsList)-1)]
+		
+		old := ss.Get(data.Key)
+		oldScore := float64(0)
+		if old != nil {
+			oldScore = old.Score
+		}
+		ret := ss.Add(data, flags)
+		
+		// 验证结果
+		one := ss.Get(data.Key)
+		if old == nil {
+			added := flags&AddXX == 0
+			assert.Assert(ret == added && (one != nil) == added, "添加结果不正确, flags:", flags)
+			continue
+		}
+		shouldUpdate := flags&AddNX == 0 &&
+			(flags&AddGT == 0 || data.Score > oldScore) &&
+			(flags&AddLT == 0 || data.Score < oldScore)
+		if shouldUpdate {
+			assert.Assert(one.Score == data.Score, "分数需要被更新, flags:", flags)
+		} else {
+			assert.Assert(one.Score == oldScore, "分数不能被更新, flags:", flags)
+		}
+		changed := shouldUpdate && data.Score != oldScore
+		assert.Assert(ret == (flags&AddCH != 0 && changed), "返回值不正确, flags:", flags)
+	}
+}
+
+// 增加分数
+func SortedSetOp_IncrScore(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		var key int64
+		var val *Val
+		if ss.Length() > 0 && random2.RandInt(0, 3) != 0 {
+			key = ss.GetByRank(random2.RandInt(1, ss.Length())).Key
+		} else {
+			val = NewVal()
+			key = val.ID
+		}
+		oldScore := float64(0)
+		if old := ss.Get(key); old != nil {
+			oldScore = old.Score
+		}
+		delta := float64(random2.RandInt(0, 100) - 50)
+		newScore, rank, ok := ss.IncrScore(key, delta, val)
+		assert.Assert(ok && newScore == oldScore+delta, "分数不正确:", newScore, " ", oldScore+delta)
+		assert.Assert(ss.Get(key).Score == newScore && ss.GetRank(key) == rank, "排名不正确:", rank)
+	}
+}
+
+// 弹出分数最低或者最高的若干元素
+func SortedSetOp_Pop(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		length := ss.Length()
+		count := random2.RandInt(1, 5)
+		expectedLen := min(count, length)
+		var expected, datas []*NodeData[int64, *Val]
+		if random2.RandInt(0, 1) == 0 {
+			if length > 0 {
+				expected = ss.GetRangeByRank(1, count)
+			}
+			datas = ss.PopMin(count)
+		} else {
+			if length > 0 {
+				expected = ss.GetRevRangeByRank(1, count)
+			}
+			datas = ss.PopMax(count)
+		}
+		assert.Assert(len(datas) == expectedLen && ss.Length() == length-expectedLen, "弹出数量不正确:", len(datas))
+		for j, one := range datas {
+			assert.Assert(one == expected[j], "弹出的元素不正确:", one.Key)
+			assert.Assert(ss.Get(one.Key) == nil, "弹出的元素需要被删除:", one.Key)
+		}
+	}
+}
+
 // 通过分数范围获得多个元素
-func SortedSetOp_GetRangeByScore(ss *SortedSet, num int) {
+func SortedSetOp_GetRangeByScore(ss TestISortedSet, num int) {
 	if ss.Length() > 0 {
 		for i := 0; i < num; i++ {
 			min := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
@@ -132,16 +364,16 @@ func SortedSetOp_GetRangeByScore(ss *SortedSet, num int) {
 			if random2.RandInt(0, 1) == 1 {
 				maxEx = true
 			}
-
+			
 			datas := ss.GetRangeByScore(min, minEx, max, maxEx)
-
+			
 			// 判断是否有序
 			for j := 0; j < len(datas); j++ {
 				if j+1 < len(datas) {
-					assert.Assert(datas[j].LessThan(datas[j+1]), "返回的元素必须是有序的")
+					assert.Assert(compareTestData(datas[j], datas[j+1]) < 0, "返回的元素必须是有序的")
 				}
 			}
-
+			
 			// 判断返回的元素是否在指定范围内
 			for _, one := range datas {
 				if minEx {
@@ -159,7 +391,153 @@ func SortedSetOp_GetRangeByScore(ss *SortedSet, num int) {
 	}
 }
 
-func SortedSetOp_DeleteRangeByScore(ss *SortedSet, num int) {
+// 统计分数范围内的元素数量，和遍历统计的结果必须一致
+func SortedSetOp_CountByScore(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		min := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		max := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		minEx := random2.RandInt(0, 1) == 1
+		maxEx := random2.RandInt(0, 1) == 1
+		
+		expected := 0
+		for _, one := range ss.All() {
+			if (one.Score > min || !minEx && one.Score == min) && (one.Score < max || !maxEx && one.Score == max) {
+				expected++
+			}
+		}
+		count := ss.CountByScore(min, minEx, max, maxEx)
+		assert.Assert(count == expected, "数量不一致:", count, " ", expected, " min:", min, " max:", max)
+		assert.Assert(count == len(ss.GetRangeByScore(min, minEx, max, maxEx)), "数量和范围查询的结果不一致")
+	}
+}
+
+// 分页获取分数范围内的元素，结果必须和完整结果的对应片段一致
+func SortedSetOp_GetRangeByScoreLimit(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		min := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		max := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		minEx := random2.RandInt(0, 1) == 1
+		maxEx := random2.RandInt(0, 1) == 1
+		offset := random2.RandInt(0, ss.Length()+1)
+		count := random2.RandInt(0, 50) - 1
+		
+		var all, datas []*NodeData[int64, *Val]
+		if random2.RandInt(0, 1) == 0 {
+			all = ss.GetRangeByScore(min, minEx, max, maxEx)
+			datas = ss.GetRangeByScoreLimit(min, minEx, max, maxEx, offset, count)
+		} else {
+			all = ss.GetRevRangeByScore(max, maxEx, min, minEx)
+			datas = ss.GetRevRangeByScoreLimit(max, maxEx, min, minEx, offset, count)
+		}
+		expected := []*NodeData[int64, *Val]{}
+		if offset < len(all) {
+			expected = all[offset:]
+		}
+		if count >= 0 && count < len(expected) {
+			expected = expected[:count]
+		}
+		assert.Assert(len(datas) == len(expected), "分页数量不一致:", len(datas), " ", len(expected),
+			" offset:", offset, " count:", count)
+		for j, one := range datas {
+			assert.Assert(one == expected[j], "分页的元素不一致, index:", j)
+		}
+	}
+}
+
+// 统计排名范围和分数范围内的分数和，和遍历统计的结果必须一致
+func SortedSetOp_Sum(ss *TestSortedSet, num int) {
+	if _, _, sumEnabled := ss.params(); !sumEnabled {
+		return
+	}
+	for i := 0; i < num; i++ {
+		start := random2.RandInt(1, ss.Length()+1)
+		end := random2.RandInt(start, ss.Length()+1)
+		expected := 0.0
+		for _, one := range ss.GetRangeByRank(start, end) {
+			expected += one.Score
+		}
+		sum, count := ss.SumByRank(start, end)
+		assert.Assert(sum == expected && count == len(ss.GetRangeByRank(start, end)),
+			"排名范围的分数和不正确:", sum, " ", expected, " start:", start, " end:", end)
+		if minScore, maxScore, ok := ss.MinMaxByRank(start, end); ok {
+			datas := ss.GetRangeByRank(start, end)
+			assert.Assert(minScore == datas[0].Score && maxScore == datas[len(datas)-1].Score, "最低最高分数不正确")
+		}
+		
+		min := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		max := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		minEx := random2.RandInt(0, 1) == 1
+		maxEx := random2.RandInt(0, 1) == 1
+		expected = 0.0
+		datas := ss.GetRangeByScore(min, minEx, max, maxEx)
+		for _, one := range datas {
+			expected += one.Score
+		}
+		sum, count = ss.SumByScore(min, minEx, max, maxEx)
+		assert.Assert(sum == expected && count == len(datas), "分数范围的分数和不正确:", sum, " ", expected)
+		avg, ok := ss.AvgByScore(min, minEx, max, maxEx)
+		assert.Assert(ok == (count > 0) && (!ok || avg == sum/float64(count)), "平均分数不正确:", avg)
+	}
+}
+
+// 百分位、分位数和分桶，和根据全部数据计算的结果必须一致
+func SortedSetOp_Percentile(ss *TestSortedSet, num int) {
+	for i := 0; i < num; i++ {
+		length := ss.Length()
+		p := float64(random2.RandInt(0, 1000)) / 10
+		all := ss.GetRangeByRank(1, max(length, 1))
+		if length == 0 {
+			assert.Assert(ss.GetByPercentile(p) == nil && ss.GetByRevPercentile(p) == nil, "空的有序集合没有百分位")
+			_, ok := ss.ScoreQuantile(p / 100)
+			assert.Assert(!ok, "空的有序集合没有分位数")
+			continue
+		}
+		// 最近排名法:排名不超过它的数据至少占p%的最小排名(p只有一位小数，换算成整数比较)
+		rank := 1
+		for rank*1000 < int(math.Round(p*10))*length {
+			rank++
+		}
+		assert.Assert(ss.GetByPercentile(p) == all[rank-1], "百分位的数据不正确, p:", p, " rank:", rank)
+		assert.Assert(ss.GetByRevPercentile(p) == all[length-rank], "逆序百分位的数据不正确, p:", p)
+		
+		one := all[random2.RandInt(0, length-1)]
+		percentile, ok := ss.GetPercentile(one.Key)
+		revPercentile, revOk := ss.GetRevPercentile(one.Key)
+		assert.Assert(ok && revOk && percentile > 0 && percentile <= 100 && revPercentile > 0 && revPercentile <= 100,
+			"key的百分位不正确:", percentile, " ", revPercentile)
+		// 自身的百分位对应的就是自身
+		assert.Assert(ss.GetByPercentile(percentile) == one && ss.GetByRevPercentile(revPercentile) == one,
+			"百分位不一致, key:", one.Key)
+		
+		q := p / 100
+		score, ok := ss.ScoreQuantile(q)
+		h := q * float64(length-1)
+		lower := int(math.Floor(h))
+		expected := all[lower].Score
+		if lower+1 < length {
+			expected += (h - float64(lower)) * (all[lower+1].Score - all[lower].Score)
+		}
+		assert.Assert(ok && math.Abs(score-expected) < 1e-9, "分位数不正确:", score, " ", expected)
+		
+		n := random2.RandInt(1, 20)
+		buckets := ss.Buckets(n)
+		assert.Assert(len(buckets) == min(n, length), "桶的数量不正确:", len(buckets))
+		next := 1
+		for index, bucket := range buckets {
+			size := bucket.End - bucket.Start + 1
+			assert.Assert(bucket.Index == index && bucket.Start == next, "桶不连续, index:", index)
+			assert.Assert(size == length/n || size == length/n+1, "桶的大小不正确:", size)
+			assert.Assert(bucket.First == all[bucket.Start-1] && bucket.Last == all[bucket.End-1], "桶的首尾数据不正确")
+			for rank := bucket.Start; rank <= bucket.End; rank++ {
+				assert.Assert(ss.BucketOf(all[rank-1].Key, n) == index, "数据所在的桶不正确, rank:", rank)
+			}
+			next = bucket.End + 1
+		}
+		assert.Assert(next == length+1, "所有数据都要在桶中")
+	}
+}
+
+func SortedSetOp_DeleteRangeByScore(ss TestISortedSet, num int) {
 	for i := 0; i < num; i++ {
 		if ss.Length() == 0 {
 			break
@@ -185,7 +563,7 @@ func SortedSetOp_DeleteRangeByScore(ss *SortedSet, num int) {
 		// 判断是否有序
 		for j := 0; j < len(datas); j++ {
 			if j+1 < len(datas) {
-				assert.Assert(datas[j].LessThan(datas[j+1]), "返回的元素必须是有序的")
+				assert.Assert(compareTestData(datas[j], datas[j+1]) < 0, "返回的元素必须是有序的")
 			}
 		}
 		
@@ -206,7 +584,7 @@ func SortedSetOp_DeleteRangeByScore(ss *SortedSet, num int) {
 }
 
 // 获取排名 和 通过排名获取元素 互相验证
-func SortedSetOp_GetRank(ss *SortedSet, num int) {
+func SortedSetOp_GetRank(ss TestISortedSet, num int) {
 	for i := 0; i < num; i++ {
 		if ss.Length() > 0 {
 			randomRank := random2.RandInt(1, ss.Length())
@@ -220,7 +598,7 @@ func SortedSetOp_GetRank(ss *SortedSet, num int) {
 }
 
 // 通过排名范围获取元素
-func SortedSetOp_GetRangeByRank(ss *SortedSet, num int) {
+func SortedSetOp_GetRangeByRank(ss TestISortedSet, num int) {
 	for i := 0; i < num; i++ {
 		length := ss.Length()
 		if length == 0 {
@@ -237,13 +615,436 @@ func SortedSetOp_GetRangeByRank(ss *SortedSet, num int) {
 			assert.Assert(rank == start+j, "排名不正确", rank, " ", start+j)
 			
 			if j < len(datas)-1 {
-				assert.Assert(datas[j].LessThan(datas[j+1]), "返回的元素必须是有序的")
+				assert.Assert(compareTestData(datas[j], datas[j+1]) < 0, "返回的元素必须是有序的")
+			}
+		}
+	}
+}
+
+// 获取逆序排名 和 通过逆序排名获取元素 互相验证
+func SortedSetOp_GetRevRank(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		if ss.Length() > 0 {
+			randomRank := random2.RandInt(1, ss.Length())
+			data := ss.GetByRevRank(randomRank)
+			assert.Assert(data != nil, "data 不能为nil, rank:", randomRank)
+			
+			rank := ss.GetRevRank(data.Key)
+			assert.Assert(randomRank == rank, "逆序排名不一致, randomRank:", randomRank, " rank:", rank, " key:", data.Key)
+			assert.Assert(ss.GetRank(data.Key)+rank == ss.Length()+1, "正序排名和逆序排名不匹配:", data.Key)
+		}
+	}
+}
+
+// 通过逆序排名范围获取元素
+func SortedSetOp_GetRevRangeByRank(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		length := ss.Length()
+		if length == 0 {
+			return
+		}
+		start := random2.RandInt(1, length)
+		end := random2.RandInt(1, length+10)
+		if start > end {
+			start, end = end, start
+		}
+		datas := ss.GetRevRangeByRank(start, end)
+		expectedLen := end - start + 1
+		if end > length {
+			expectedLen = length - start + 1
+		}
+		assert.Assert(len(datas) == expectedLen, "数量不正确:", len(datas), " ", expectedLen)
+		for j, one := range datas {
+			rank := ss.GetRevRank(one.Key)
+			assert.Assert(rank == start+j, "逆序排名不正确", rank, " ", start+j)
+			
+			if j < len(datas)-1 {
+				assert.Assert(compareTestData(datas[j], datas[j+1]) > 0, "返回的元素必须是逆序的")
 			}
 		}
 	}
 }
 
-func SortedSetOp_DeleteRangeByRank(ss *SortedSet, num int) {
+// 通过分数范围逆序获取元素,和正序获取的结果互相验证
+func SortedSetOp_GetRevRangeByScore(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		min := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		max := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		if min > max {
+			min, max = max, min
+		}
+		minEx := random2.RandInt(0, 1) == 1
+		maxEx := random2.RandInt(0, 1) == 1
+		
+		datas := ss.GetRangeByScore(min, minEx, max, maxEx)
+		revDatas := ss.GetRevRangeByScore(max, maxEx, min, minEx)
+		assert.Assert(len(datas) == len(revDatas), "数量不一致:", len(datas), " ", len(revDatas))
+		for j, one := range revDatas {
+			assert.Assert(one == datas[len(datas)-1-j], "逆序结果不正确:", one.Key)
+		}
+	}
+}
+
+// 游标定位和移动
+func SortedSetOp_Iterator(ss *TestSortedSet, num int) {
+	for i := 0; i < num; i++ {
+		length := ss.Length()
+		if length == 0 {
+			return
+		}
+		it := ss.NewIterator()
+		randomRank := random2.RandInt(1, length)
+		data := ss.GetByRank(randomRank)
+		// 按key定位
+		assert.Assert(it.SeekKey(data.Key), "按key定位不能失败:", data.Key)
+		assert.Assert(it.Data() == data && it.Rank() == randomRank, "按key定位不正确:", data.Key)
+		// 按分数定位:定位到的一定是该分数的第一个元素
+		assert.Assert(it.SeekScore(data.Score), "按分数定位不能失败:", data.Score)
+		assert.Assert(it.Data().Score == data.Score && it.Rank() <= randomRank, "按分数定位不正确:", data.Score)
+		assert.Assert(it.Rank() == 1 || ss.GetByRank(it.Rank()-1).Score < data.Score, "按分数定位不正确:", data.Score)
+		// 按分数逆序定位:定位到的一定是该分数的最后一个元素
+		assert.Assert(it.SeekRevScore(data.Score), "按分数定位不能失败:", data.Score)
+		assert.Assert(it.Data().Score == data.Score && it.Rank() >= randomRank, "按分数定位不正确:", data.Score)
+		// 按排名定位,然后前后移动
+		assert.Assert(it.SeekRank(randomRank), "按排名定位不能失败:", randomRank)
+		steps := random2.RandInt(0, 100)
+		for j := 0; j < steps && it.Next(); j++ {
+			assert.Assert(ss.GetRank(it.Data().Key) == it.Rank(), "游标排名不正确:", it.Rank())
+		}
+		for j := 0; j < steps && it.Prev(); j++ {
+			assert.Assert(ss.GetRank(it.Data().Key) == it.Rank(), "游标排名不正确:", it.Rank())
+		}
+	}
+}
+
+// 迭代器和按范围获取的结果互相验证
+func SortedSetOp_IterRange(ss TestISortedSet, num int) {
+	for i := 0; i < num; i++ {
+		length := ss.Length()
+		if length == 0 {
+			return
+		}
+		start := random2.RandInt(1, length)
+		end := random2.RandInt(1, length)
+		datas := ss.GetRangeByRank(start, end)
+		j := 0
+		for rank, one := range ss.IterRangeByRank(start, end) {
+			assert.Assert(one == datas[j] && rank == ss.GetRank(one.Key), "迭代结果不正确:", one.Key)
+			j++
+		}
+		assert.Assert(j == len(datas), "迭代数量不正确:", j, " ", len(datas))
+		
+		min := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		max := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		if min > max {
+			min, max = max, min
+		}
+		datas = ss.GetRangeByScore(min, false, max, true)
+		j = 0
+		for rank, one := range ss.IterRangeByScore(min, false, max, true) {
+			assert.Assert(one == datas[j] && rank == ss.GetRank(one.Key), "迭代结果不正确:", one.Key)
+			j++
+		}
+		assert.Assert(j == len(datas), "迭代数量不正确:", j, " ", len(datas))
+		
+		// 逆序遍历前几个元素
+		for rank, one := range ss.Backward() {
+			assert.Assert(ss.GetRevRank(one.Key) == length-rank+1, "逆序迭代结果不正确:", one.Key)
+			if rank <= length-10 {
+				break
+			}
+		}
+	}
+}
+
+// 判断两个有序集合的元素完全一致
+func sortedSetMustEqual(ss *TestSortedSet, other *TestSortedSet) {
+	assert.Assert(ss.Length() == other.Length(), "长度不一致:", ss.Length(), " ", other.Length())
+	datas := other.GetRangeByRank(1, max(other.Length(), 1))
+	for rank, one := range ss.All() {
+		x := datas[rank-1]
+		assert.Assert(one.Key == x.Key && one.Score == x.Score && *one.Val == *x.Val,
+			"元素不一致, rank:", rank, " key:", one.Key, " ", x.Key)
+		assert.Assert(other.Get(one.Key) == x, "哈希表不一致:", one.Key)
+	}
+}
+
+// 快照序列化和反序列化
+func SortedSetOp_Snapshot(ss *TestSortedSet, num int) {
+	for i := 0; i < num; i++ {
+		data, err := ss.MarshalSnapshot(Int64Codec{}, ValCodec{})
+		assert.Assert(err == nil, "序列化不能失败:", err)
+		other := NewTestCompactSortedSet()
+		err = other.UnmarshalSnapshot(data, Int64Codec{}, ValCodec{})
+		assert.Assert(err == nil, "反序列化不能失败:", err)
+		sortedSetMustEqual(ss, other)
+		SortedSetMustLegal(other)
+		
+		// 随机破坏一个字节,反序列化必须失败,并且不能改变原有的数据
+		if len(data) > 0 {
+			index := random2.RandInt(0, len(data)-1)
+			data[index] ^= byte(random2.RandInt(1, 255))
+			err = other.UnmarshalSnapshot(data, Int64Codec{}, ValCodec{})
+			assert.Assert(err != nil, "数据损坏时反序列化必须失败, index:", index)
+			sortedSetMustEqual(ss, other)
+		}
+	}
+}
+
+// 快照文件保存和加载(旧的快照文件会被备份)
+func SortedSetSnapshotFileTest(ss *TestSortedSet) {
+	dir, err := os.MkdirTemp("", "sortedset")
+	assert.Assert(err == nil, "创建临时目录失败:", err)
+	defer os.RemoveAll(dir)
+	
+	file := filepath.Join(dir, "sortedset.snapshot")
+	var first []byte
+	for i := 0; i < 3; i++ {
+		err = ss.SaveSnapshot(file, Int64Codec{}, ValCodec{})
+		assert.Assert(err == nil, "保存快照失败:", err)
+		other := NewTestCompactSortedSet()
+		err = other.LoadSnapshot(file, Int64Codec{}, ValCodec{})
+		assert.Assert(err == nil, "加载快照失败:", err)
+		sortedSetMustEqual(ss, other)
+		if i == 0 {
+			first, _ = os.ReadFile(file)
+			// 修改之后再保存，备份的是上一次的快照
+			SortedSetOp_Insert(ss, 1)
+		}
+		if i == 1 {
+			backup, _ := os.ReadFile(file + SNAPSHOT_BACKUP_EXT)
+			assert.Assert(bytes.Equal(backup, first), "备份的是上一次保存的快照")
+		}
+	}
+	// 只保留一份备份，没有残留的临时文件
+	files, _ := os.ReadDir(dir)
+	assert.Assert(len(files) == 2, "只能有快照文件和一份备份:", len(files))
+}
+
+// 结点高度达到最大高度
+// 提升结点高度的概率接近1时，几乎每个结点的高度都是SKIPLIST_MAXLEVEL
+// 逐个插入和从快照加载(按顺序构建)都不能超出最大高度
+func SortedSetMaxLevelTest(n int) {
+	ss := &TestSortedSet{
+		Sl:   NewSkipListByParams[int64, *Val](CompareVal, 0.9999),
+		Hash: map[int64]*NodeData[int64, *Val]{},
+	}
+	SortedSetOp_Insert(ss, n)
+	assert.Assert(ss.Sl.Level == SKIPLIST_MAXLEVEL, "高度没有达到最大高度:", ss.Sl.Level)
+	SortedSetMustLegal(ss)
+	
+	data, err := ss.MarshalSnapshot(Int64Codec{}, ValCodec{})
+	assert.Assert(err == nil, "序列化不能失败:", err)
+	other := NewTestSortedSet()
+	err = other.UnmarshalSnapshot(data, Int64Codec{}, ValCodec{})
+	assert.Assert(err == nil, "反序列化不能失败:", err)
+	assert.Assert(other.Sl.Level == SKIPLIST_MAXLEVEL, "高度没有达到最大高度:", other.Sl.Level)
+	SortedSetMustLegal(other)
+	sortedSetMustEqual(ss, other)
+}
+
+// 操作日志记录和重放
+// 在挂载了操作日志的有序集合上随机执行操作，然后重放日志得到的有序集合必须和原来的一致
+func SortedSetJournalTest(n int, opCnt int) {
+	dir, err := os.MkdirTemp("", "sortedset")
+	assert.Assert(err == nil, "创建临时目录失败:", err)
+	defer os.RemoveAll(dir)
+	
+	file := filepath.Join(dir, "sortedset.journal")
+	policy := FsyncPolicy(random2.RandInt(int(FsyncAlways), int(FsyncNo)))
+	j, err := OpenJournal[int64, *Val](file, Int64Codec{}, ValCodec{}, policy)
+	assert.Assert(err == nil, "打开操作日志失败:", err)
+	ss := NewTestCompactSortedSet()
+	ss.AttachJournal(j)
+	SortedSetOp_Insert(ss, n)
+	for i := 0; i < opCnt; i++ {
+		if random2.RandInt(0, 9) == 0 {
+			fn := SortedSetOp_RangeHandlers[random2.RandInt(0, len(SortedSetOp_RangeHandlers)-1)]
+			fn(ss, 1)
+		} else {
+			fn := SortedSetOp_Handlers[random2.RandInt(0, len(SortedSetOp_Handlers)-1)]
+			fn(ss, 1)
+		}
+		if ss.Length() > 0 && random2.RandInt(0, 9) == 0 {
+			// 设置或者移除过期时间(足够长，测试期间不会过期)
+			key := ss.GetByRank(random2.RandInt(1, ss.Length())).Key
+			if random2.RandInt(0, 1) == 0 {
+				ss.Expire(key, time.Duration(random2.RandInt(1, 100))*time.Hour)
+			} else {
+				ss.Persist(key)
+			}
+		}
+		if i == opCnt/2 {
+			// 中途压缩一次日志
+			assert.Assert(j.Rewrite(ss) == nil, "重写操作日志失败")
+		}
+	}
+	assert.Assert(j.Close() == nil, "关闭操作日志失败:", j.Err())
+	
+	// 模拟崩溃:最后一条记录只写入了一部分
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Assert(err == nil, "打开操作日志失败:", err)
+	f.Write([]byte{100, 0, 0, 0, 1, 2})
+	f.Close()
+	
+	j, err = OpenJournal[int64, *Val](file, Int64Codec{}, ValCodec{}, policy)
+	assert.Assert(err == nil, "打开操作日志失败:", err)
+	other := NewTestCompactSortedSet()
+	_, err = j.Replay(other)
+	assert.Assert(err == nil, "重放操作日志失败:", err)
+	sortedSetMustEqual(ss, other)
+	SortedSetMustLegal(other)
+	for _, data := range ss.All() {
+		at, has := ss.GetExpireAt(data.Key)
+		otherAt, otherHas := other.GetExpireAt(data.Key)
+		assert.Assert(has == otherHas && at.Equal(otherAt), "过期时间不一致, key:", data.Key)
+	}
+	
+	// 截断不完整的记录后，可以继续追加记录
+	other.AttachJournal(j)
+	SortedSetOp_Insert(other, 10)
+	assert.Assert(j.Close() == nil, "关闭操作日志失败:", j.Err())
+	j, err = OpenJournal[int64, *Val](file, Int64Codec{}, ValCodec{}, policy)
+	assert.Assert(err == nil, "打开操作日志失败:", err)
+	defer j.Close()
+	replayed := NewTestCompactSortedSet()
+	_, err = j.Replay(replayed)
+	assert.Assert(err == nil, "重放操作日志失败:", err)
+	sortedSetMustEqual(other, replayed)
+}
+
+// 重放操作日志时不能删除过期的元素
+// 按排名删除的记录(比如PopMin)必须在和记录时相同的元素上重放，即使重放时元素已经过期
+func SortedSetJournalExpireTest() {
+	dir, err := os.MkdirTemp("", "sortedset")
+	assert.Assert(err == nil, "创建临时目录失败:", err)
+	defer os.RemoveAll(dir)
+	
+	file := filepath.Join(dir, "sortedset.journal")
+	j, err := OpenJournal[int64, *Val](file, Int64Codec{}, ValCodec{}, FsyncNo)
+	assert.Assert(err == nil, "打开操作日志失败:", err)
+	now := time.Unix(1000, 0)
+	ss := NewTestCompactSortedSet()
+	ss.SetClock(func() time.Time { return now })
+	ss.AttachJournal(j)
+	for i := int64(1); i <= 3; i++ {
+		assert.Assert(ss.Insert(NewNodeData(i, float64(i), &Val{ID: i})), "插入不会失败")
+	}
+	ss.ExpireAt(1, now.Add(10*time.Second))
+	popped := ss.PopMin(1)
+	assert.Assert(len(popped) == 1 && popped[0].Key == 1, "删除的是分数最低的元素")
+	assert.Assert(j.Close() == nil, "关闭操作日志失败:", j.Err())
+	
+	// 一小时之后重放(按重放时的时钟，被删除的元素已经过期)
+	j, err = OpenJournal[int64, *Val](file, Int64Codec{}, ValCodec{}, FsyncNo)
+	assert.Assert(err == nil, "打开操作日志失败:", err)
+	defer j.Close()
+	replayed := NewTestCompactSortedSet()
+	replayed.SetClock(func() time.Time { return now.Add(time.Hour) })
+	_, err = j.Replay(replayed)
+	assert.Assert(err == nil, "重放操作日志失败:", err)
+	assert.Assert(!replayed.manualExpire, "重放之后恢复惰性删除")
+	sortedSetMustEqual(ss, replayed)
+}
+
+// 集合运算(并集、交集、差集)
+// 多个有序集合的key取自同一个范围，保证有足够多的重复元素
+func SortedSetSetOpsTest(n int) {
+	setCnt := random2.RandInt(1, 4)
+	sets := make([]*TestSortedSet, 0, setCnt)
+	weights := make([]float64, 0, setCnt)
+	for i := 0; i < setCnt; i++ {
+		ss := NewTestCompactSortedSet()
+		for j := 0; j < n; j++ {
+			key := int64(random2.RandInt(1, n*2))
+			score := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+			ss.Insert(NewNodeData(key, score, &Val{ID: key}))
+		}
+		sets = append(sets, ss)
+		weights = append(weights, float64(random2.RandInt(0, 4))-1)
+	}
+	agg := Aggregate(random2.RandInt(int(AggregateSum), int(AggregateMax)))
+	
+	// 暴力计算期望的结果
+	union := map[int64]float64{}
+	counts := map[int64]int{}
+	for i, ss := range sets {
+		for _, data := range ss.All() {
+			key := data.Key
+			score := data.Score * weights[i]
+			if old, has := union[key]; has {
+				union[key] = aggregateScore(agg, old, score)
+			} else {
+				union[key] = score
+			}
+			counts[key]++
+		}
+	}
+	mustMatch := func(result *TestSortedSet, err error, expected map[int64]float64) {
+		// 卫星数据中包含key,不会出现(分数, 卫星数据)相同的元素
+		assert.Assert(err == nil, "集合运算失败:", err)
+		SortedSetMustLegal(result)
+		assert.Assert(result.Length() == len(expected), "数量不一致:", result.Length(), " ", len(expected))
+		for key, score := range expected {
+			data := result.Get(key)
+			assert.Assert(data != nil && data.Score == score, "分数不一致, key:", key)
+		}
+	}
+	
+	result, err := Union(sets, weights, agg)
+	mustMatch(result, err, union)
+	inter := map[int64]float64{}
+	for key, score := range union {
+		if counts[key] == setCnt {
+			inter[key] = score
+		}
+	}
+	result, err = Inter(sets, weights, agg)
+	mustMatch(result, err, inter)
+	diff := map[int64]float64{}
+	for _, data := range sets[0].All() {
+		if counts[data.Key] == 1 {
+			diff[data.Key] = data.Score
+		}
+	}
+	mustMatch(Diff(sets), nil, diff)
+	
+	// 结果使用第一个有序集合的参数
+	_, prob, sumEnabled := sets[0].params()
+	for _, one := range []*TestSortedSet{result, Diff(sets)} {
+		_, p, sum := one.params()
+		assert.Assert(p == prob && sum == sumEnabled && one.compactMax == sets[0].compactMax, "结果的参数和第一个有序集合不一致")
+	}
+}
+
+// 集合运算的结果中出现(分数, 卫星数据)相同的元素
+// 两个有序集合各自都是合法的，但是分数求和之后，key为1和2的元素的分数和卫星数据都相同
+func SortedSetSetOpsTieTest() {
+	a := NewTestSortedSet()
+	a.Insert(NewNodeData(int64(1), 10, &Val{ID: 7}))
+	a.Insert(NewNodeData(int64(2), 20, &Val{ID: 7}))
+	b := NewTestSortedSet()
+	b.Insert(NewNodeData(int64(1), 10, &Val{ID: 7}))
+	b.Insert(NewNodeData(int64(2), 0, &Val{ID: 7}))
+	sets := []*TestSortedSet{a, b}
+	
+	result, err := Union(sets, nil, AggregateSum)
+	assert.Assert(result == nil && errors.Is(err, ErrBulkLoadOrder), "并集需要返回错误:", err)
+	result, err = Inter(sets, nil, AggregateSum)
+	assert.Assert(result == nil && errors.Is(err, ErrBulkLoadOrder), "交集需要返回错误:", err)
+	// 取最大值时没有相同的元素
+	result, err = Union(sets, nil, AggregateMax)
+	assert.Assert(err == nil && result.Length() == 2, "并集不能失败:", err)
+	SortedSetMustLegal(result)
+	result = Diff(sets[:1])
+	assert.Assert(result.Length() == 2, "差集的长度不正确:", result.Length())
+	SortedSetMustLegal(result)
+	// 原有的有序集合不受影响
+	SortedSetMustLegal(a)
+	SortedSetMustLegal(b)
+}
+
+func SortedSetOp_DeleteRangeByRank(ss TestISortedSet, num int) {
 	for i := 0; i < num; i++ {
 		length := ss.Length()
 		if length > 0 {
@@ -258,25 +1059,1119 @@ func SortedSetOp_DeleteRangeByRank(ss *SortedSet, num int) {
 				assert.Assert(rank == 0, "排名不正确:", rank)
 				
 				if j < len(datas)-1 {
-					assert.Assert(datas[j].LessThan(datas[j+1]), "返回的元素必须是有序的")
+					assert.Assert(compareTestData(datas[j], datas[j+1]) < 0, "返回的元素必须是有序的")
 				}
 			}
 		}
 	}
 }
 
-var SortedSetOp_Handlers = []func(ss *SortedSet, num int){
+// 构造一个随机的值范围(上下界都可能不存在)
+func randomValueRange(minID, maxID int64) *ValueRangeSpecified[*Val] {
+	min := &Val{ID: int64(random2.RandInt(int(minID), int(maxID)))}
+	max := &Val{ID: int64(random2.RandInt(int(minID), int(maxID)))}
+	if min.ID > max.ID {
+		min, max = max, min
+	}
+	r := NewValueRange(min, random2.RandInt(0, 1) == 1, max, random2.RandInt(0, 1) == 1)
+	r.MinInf = random2.RandInt(0, 9) == 0
+	r.MaxInf = random2.RandInt(0, 9) == 0
+	return r
+}
+
+// 判断值是否在指定的值范围内(暴力判断，用来验证跳跃表的实现)
+func valInRange(val *Val, r *ValueRangeSpecified[*Val]) bool {
+	if !r.MinInf {
+		if r.MinExclusive && val.ID <= r.Min.ID || !r.MinExclusive && val.ID < r.Min.ID {
+			return false
+		}
+	}
+	if !r.MaxInf {
+		if r.MaxExclusive && val.ID >= r.Max.ID || !r.MaxExclusive && val.ID > r.Max.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// 按值范围操作
+// 值范围只有在所有元素分数相同时才有意义，所以单独构造一个分数都相同的有序集合来测试
+func SortedSetValueRangeTest(ss TestISortedSet, n int) {
+	minID := uniq + 1
+	for i := 0; i < n; i++ {
+		val := NewVal()
+		assert.Assert(ss.Insert(NewNodeData(val.ID, TEST_SORTED_SET_SCORE_MIN, val)), "插入不会失败:", val.ID)
+	}
+	maxID := uniq
+	for i := 0; i < 100 && ss.Length() > 0; i++ {
+		r := randomValueRange(minID, maxID)
+		// 暴力计算出范围内的元素
+		expected := make([]*NodeData[int64, *Val], 0, 4)
+		for _, one := range ss.GetRangeByRank(1, ss.Length()) {
+			if valInRange(one.Val, r) {
+				expected = append(expected, one)
+			}
+		}
+		
+		count := ss.CountByValue(r)
+		assert.Assert(count == len(expected), "数量不一致, count:", count, " expected:", len(expected))
+		
+		var datas []*NodeData[int64, *Val]
+		if random2.RandInt(0, 4) == 0 {
+			datas = ss.DeleteRangeByValue(r)
+			for _, one := range datas {
+				assert.Assert(ss.Get(one.Key) == nil, "删除后不能再获取到:", one.Key)
+			}
+		} else {
+			datas = ss.GetRangeByValue(r)
+		}
+		assert.Assert(len(datas) == len(expected), "数量不一致, len:", len(datas), " expected:", len(expected))
+		for j, one := range datas {
+			assert.Assert(one == expected[j], "元素不一致:", one.Key, " ", expected[j].Key)
+		}
+	}
+	ISortedSetMustLegal(ss)
+}
+
+// 随机获取元素
+// 检查返回的元素都在指定范围内、数量正确、不重复时没有重复的元素
+func SortedSetOp_RandMember(ss *TestSortedSet, num int) {
+	for i := 0; i < num; i++ {
+		count := random2.RandInt(1, 20)
+		repeat := random2.RandInt(0, 1) == 0
+		mode := RandMode(random2.RandInt(int(RandUniform), int(RandByScore)))
+		if first := ss.GetByRank(1); first != nil && first.Score < 0 {
+			// 按分数加权时分数不能为负数
+			mode = RandUniform
+		}
+		start, end := 1, max(ss.Length(), 1)
+		minScore, maxScore := math.Inf(-1), math.Inf(1)
+		var datas []*NodeData[int64, *Val]
+		switch random2.RandInt(0, 2) {
+		case 0:
+			datas = ss.RandMember(count, repeat, mode)
+		case 1:
+			start = random2.RandInt(1, ss.Length()+1)
+			end = random2.RandInt(start, ss.Length()+1)
+			datas = ss.RandMemberByRank(start, end, count, repeat, mode)
+		case 2:
+			minScore = float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+			maxScore = float64(random2.RandInt(int(minScore), TEST_SORTED_SET_SCORE_MAX))
+			datas = ss.RandMemberByScore(minScore, false, maxScore, false, count, repeat, mode)
+		}
+		
+		candidates := 0
+		for rank, one := range ss.All() {
+			if rank >= start && rank <= end && one.Score >= minScore && one.Score <= maxScore &&
+				(mode == RandUniform || one.Score > 0) {
+				// 按分数加权时，分数为0的元素不会被选中
+				candidates++
+			}
+		}
+		expectedLen := min(count, candidates)
+		if repeat && candidates > 0 {
+			expectedLen = count
+		}
+		assert.Assert(len(datas) == expectedLen, "随机获取的数量不正确:", len(datas), " ", expectedLen)
+		assert.Assert(datas != nil, "没有结果时返回空切片而不是nil")
+		picked := map[int64]bool{}
+		for _, one := range datas {
+			rank := ss.GetRank(one.Key)
+			assert.Assert(ss.Get(one.Key) == one, "随机获取的元素不存在:", one.Key)
+			assert.Assert(rank >= start && rank <= end && one.Score >= minScore && one.Score <= maxScore,
+				"随机获取的元素不在范围内:", one.Key, " rank:", rank)
+			assert.Assert(repeat || !picked[one.Key], "不能重复获取元素:", one.Key)
+			picked[one.Key] = true
+		}
+	}
+}
+
+// 只有基于跳跃表的SortedSet才支持的操作，其他实现直接跳过
+func sortedSetOnly(fn func(ss *TestSortedSet, num int)) func(ss TestISortedSet, num int) {
+	return func(ss TestISortedSet, num int) {
+		if one, ok := ss.(*TestSortedSet); ok {
+			fn(one, num)
+		}
+	}
+}
+
+var SortedSetOp_Handlers = []func(ss TestISortedSet, num int){
 	SortedSetOp_Insert,
 	SortedSetOp_Delete,
 	SortedSetOp_UpdateScore,
 	SortedSetOp_GetRank,
+	SortedSetOp_GetRevRank,
+	SortedSetOp_Add,
+	SortedSetOp_IncrScore,
+	SortedSetOp_Pop,
 }
 
-var SortedSetOp_RangeHandlers = []func(ss *SortedSet, num int){
+var SortedSetOp_RangeHandlers = []func(ss TestISortedSet, num int){
 	SortedSetOp_GetRangeByScore,
 	SortedSetOp_DeleteRangeByScore,
 	SortedSetOp_GetRangeByRank,
 	SortedSetOp_DeleteRangeByRank,
+	SortedSetOp_GetRevRangeByRank,
+	SortedSetOp_GetRevRangeByScore,
+	sortedSetOnly(SortedSetOp_Iterator),
+	SortedSetOp_IterRange,
+	sortedSetOnly(SortedSetOp_Snapshot),
+	sortedSetOnly(SortedSetOp_RandMember),
+	SortedSetOp_CountByScore,
+	SortedSetOp_GetRangeByScoreLimit,
+	sortedSetOnly(SortedSetOp_Sum),
+	sortedSetOnly(SortedSetOp_Percentile),
+}
+
+// 元素的过期时间
+// 使用假的时钟，随机插入元素、设置或移除过期时间、推进时间，结果必须和暴力模拟的一致
+func SortedSetExpireTest(n int, opCnt int) {
+	now := time.Unix(1000, 0)
+	ss := NewTestCompactSortedSet()
+	ss.SetClock(func() time.Time { return now })
+	members := map[int64]bool{}
+	expireAt := map[int64]time.Time{}
+	// 暴力删除所有过期的元素
+	expireModel := func() {
+		for key, at := range expireAt {
+			if !at.After(now) {
+				delete(members, key)
+				delete(expireAt, key)
+			}
+		}
+	}
+	
+	for i := 0; i < opCnt; i++ {
+		key := int64(random2.RandInt(1, n))
+		switch random2.RandInt(1, 6) {
+		case 1:
+			score := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+			ok := ss.Insert(NewNodeData(key, score, &Val{ID: key}))
+			expireModel()
+			assert.Assert(ok == !members[key], "插入结果不一致, key:", key)
+			members[key] = true
+		case 2:
+			ttl := time.Duration(random2.RandInt(0, 100)) * time.Second
+			ok := ss.Expire(key, ttl)
+			expireModel()
+			assert.Assert(ok == members[key], "设置过期时间结果不一致, key:", key)
+			if ok {
+				expireAt[key] = now.Add(ttl)
+			}
+		case 3:
+			ok := ss.Persist(key)
+			expireModel()
+			_, has := expireAt[key]
+			assert.Assert(ok == has, "移除过期时间结果不一致, key:", key)
+			delete(expireAt, key)
+		case 4:
+			now = now.Add(time.Duration(random2.RandInt(0, 20)) * time.Second)
+		case 5:
+			// 主动删除不影响访问的结果
+			ss.Sweep(random2.RandInt(0, 3))
+		case 6:
+			_, ok := ss.Delete(key)
+			expireModel()
+			assert.Assert(ok == members[key], "删除结果不一致, key:", key)
+			delete(members, key)
+			delete(expireAt, key)
+		}
+		
+		expireModel()
+		at, has := ss.GetExpireAt(key)
+		expected, expectedHas := expireAt[key]
+		assert.Assert(has == expectedHas && at.Equal(expected), "过期时间不一致, key:", key)
+		if has {
+			ttl, _ := ss.TTL(key)
+			assert.Assert(ttl == expected.Sub(now) && ttl > 0, "剩余时间不一致, key:", key, " ttl:", ttl)
+		}
+		assert.Assert(ss.Length() == len(members), "长度不一致:", ss.Length(), " ", len(members))
+		assert.Assert((ss.Get(key) != nil) == members[key], "元素是否存在不一致, key:", key)
+	}
+	SortedSetMustLegal(ss)
+	
+	// 过期时间也保存在快照中
+	data, err := ss.MarshalSnapshot(Int64Codec{}, ValCodec{})
+	assert.Assert(err == nil, "序列化不能失败:", err)
+	other := NewTestCompactSortedSet()
+	other.SetClock(func() time.Time { return now })
+	err = other.UnmarshalSnapshot(data, Int64Codec{}, ValCodec{})
+	assert.Assert(err == nil, "反序列化不能失败:", err)
+	sortedSetMustEqual(ss, other)
+	for key, expected := range expireAt {
+		at, has := other.GetExpireAt(key)
+		assert.Assert(has && at.Equal(expected), "快照中的过期时间不一致, key:", key)
+	}
+	
+	// 迭代器在遍历时才删除过期的元素(先创建迭代器，再推进时间)
+	iters := []iter.Seq2[int, *NodeData[int64, *Val]]{
+		other.All(),
+		other.Backward(),
+		other.IterRangeByScore(TEST_SORTED_SET_SCORE_MIN, false, TEST_SORTED_SET_SCORE_MAX, false),
+	}
+	
+	// 时间推进到所有元素都过期
+	now = now.Add(time.Hour)
+	for _, seq := range iters {
+		cnt := 0
+		for _, data := range seq {
+			_, has := expireAt[data.Key]
+			assert.Assert(!has, "遍历到了过期的元素, key:", data.Key)
+			cnt++
+		}
+		assert.Assert(cnt == len(members)-len(expireAt), "遍历的元素数量不一致:", cnt)
+	}
+	ss.Sweep(0)
+	assert.Assert(ss.length() == len(members)-len(expireAt), "主动删除后长度不一致")
+	assert.Assert(other.Length() == len(members)-len(expireAt), "惰性删除后长度不一致")
+	SortedSetMustLegal(ss)
+	SortedSetMustLegal(other)
+}
+
+// 随机获取元素的概率分布
+// 多次随机，每个元素被选中的次数和期望次数的偏差不能太大(超过5个标准差几乎不可能)
+func SortedSetRandMemberTest() {
+	ss := NewTestCompactSortedSet()
+	scores := []float64{0, 1, 2, 3, 4, 10}
+	total := 0.0
+	for i, score := range scores {
+		ss.Insert(NewNodeData(int64(i), score, &Val{ID: int64(i)}))
+		total += score
+	}
+	mustNear := func(counts map[int64]int, probs []float64, times int) {
+		for i, p := range probs {
+			expected := p * float64(times)
+			diff := math.Abs(float64(counts[int64(i)]) - expected)
+			assert.Assert(diff <= 5*math.Sqrt(expected*(1-p))+1, "随机的分布不正确, key:", i,
+				" 次数:", counts[int64(i)], " 期望:", expected)
+		}
+	}
+	
+	times := 100000
+	uniform := map[int64]int{}
+	weighted := map[int64]int{}
+	for _, one := range ss.RandMember(times, true, RandUniform) {
+		uniform[one.Key]++
+	}
+	for _, one := range ss.RandMember(times, true, RandByScore) {
+		weighted[one.Key]++
+	}
+	uniformProbs := make([]float64, len(scores))
+	weightedProbs := make([]float64, len(scores))
+	for i, score := range scores {
+		uniformProbs[i] = 1 / float64(len(scores))
+		weightedProbs[i] = score / total
+	}
+	mustNear(uniform, uniformProbs, times)
+	mustNear(weighted, weightedProbs, times)
+	
+	// 不重复时，第一个选中的元素也按照分数加权
+	first := map[int64]int{}
+	times = 20000
+	for i := 0; i < times; i++ {
+		datas := ss.RandMember(len(scores), false, RandByScore)
+		// 分数为0的元素不会被选中
+		assert.Assert(len(datas) == len(scores)-1, "数量不正确:", len(datas))
+		first[datas[0].Key]++
+	}
+	mustNear(first, weightedProbs, times)
+}
+
+// 排名变化的通知
+// 每次操作前后暴力记录所有元素的排名，变化钩子报告的排名必须和暴力记录的一致，每个变化的元素都必须有通知
+// 阈值监听通过通知维护前N名的集合，必须和实际的前N名一致
+func SortedSetHooksTest(n int, opCnt int) {
+	now := time.Unix(1000, 0)
+	ss := NewTestCompactSortedSet()
+	ss.SetClock(func() time.Time { return now })
+	SortedSetOp_Insert(ss, n)
+	
+	type state struct {
+		rank  int
+		score float64
+	}
+	states := func() map[int64]state {
+		m := make(map[int64]state, ss.length())
+		for rank, data := range ss.All() {
+			m[data.Key] = state{rank: rank, score: data.Score}
+		}
+		return m
+	}
+	var events []*ChangeEvent[int64, *Val]
+	ss.AddChangeHook(func(e *ChangeEvent[int64, *Val]) {
+		events = append(events, e)
+	})
+	
+	type watch struct {
+		n   int
+		rev bool
+		top map[int64]bool
+	}
+	topOf := func(w *watch) map[int64]bool {
+		var datas []*NodeData[int64, *Val]
+		if w.rev {
+			datas = ss.GetRevRangeByRank(1, w.n)
+		} else {
+			datas = ss.GetRangeByRank(1, w.n)
+		}
+		top := make(map[int64]bool, len(datas))
+		for _, data := range datas {
+			top[data.Key] = true
+		}
+		return top
+	}
+	watches := make([]*watch, 0, 4)
+	for _, rev := range []bool{false, true} {
+		for _, size := range []int{1, random2.RandInt(1, n+1)} {
+			w := &watch{n: size, rev: rev}
+			w.top = topOf(w)
+			ss.AddThresholdWatcher(size, rev, func(e *ThresholdEvent[int64, *Val]) {
+				key := e.Data.Key
+				assert.Assert(w.top[key] != e.Entered, "进入或者离开前N名的通知不正确, key:", key, " entered:", e.Entered)
+				if e.Entered {
+					w.top[key] = true
+				} else {
+					delete(w.top, key)
+				}
+			})
+			watches = append(watches, w)
+		}
+	}
+	// 移除的钩子不会再被调用
+	removed := ss.AddChangeHook(func(e *ChangeEvent[int64, *Val]) {
+		assert.Assert(false, "已经移除的钩子不能被调用")
+	})
+	assert.Assert(ss.RemoveHook(removed), "移除钩子失败")
+	assert.Assert(!ss.RemoveHook(removed), "不能重复移除钩子")
+	
+	ops := []func(){
+		func() { SortedSetOp_Insert(ss, 1) },
+		func() { SortedSetOp_Delete(ss, 1) },
+		func() { SortedSetOp_UpdateScore(ss, 1) },
+		func() { SortedSetOp_Add(ss, 1) },
+		func() { SortedSetOp_IncrScore(ss, 1) },
+		func() { SortedSetOp_Pop(ss, 1) },
+		func() { SortedSetOp_DeleteRangeByRank(ss, 1) },
+		func() { SortedSetOp_DeleteRangeByScore(ss, 1) },
+		func() { ss.DeleteRangeByValue(randomValueRange(0, uniq+1)) },
+		func() {
+			// 过期删除也会通知(每次只让一个元素过期)
+			if ss.Length() > 0 {
+				key := ss.GetByRank(random2.RandInt(1, ss.Length())).Key
+				ttl := time.Duration(random2.RandInt(0, 10)) * time.Second
+				ss.Expire(key, ttl)
+				now = now.Add(ttl)
+			}
+		},
+	}
+	for i := 0; i < opCnt; i++ {
+		if ss.Length() < n/2 {
+			SortedSetOp_Insert(ss, n/2)
+		}
+		before := states()
+		events = events[:0]
+		ops[random2.RandInt(0, len(ops)-1)]()
+		// 推进时间后过期的元素在下一次访问时才会删除
+		ss.Length()
+		after := states()
+		
+		notified := map[int64]bool{}
+		for _, e := range events {
+			key := e.Data.Key
+			notified[key] = true
+			old, has := before[key]
+			assert.Assert(e.OldRank == old.rank && e.OldLength == len(before), "变化前的排名不正确, key:", key,
+				" rank:", e.OldRank, " expected:", old.rank)
+			if has {
+				assert.Assert(e.OldScore == old.score, "变化前的分数不正确, key:", key)
+				assert.Assert(e.OldRevRank() == len(before)-old.rank+1, "变化前的逆序排名不正确, key:", key)
+			}
+			// 每次操作只有一个变化或者一次范围删除，排名和长度都是相对整个操作之前和之后的
+			current, exist := after[key]
+			assert.Assert(e.NewRank == current.rank && e.NewLength == len(after), "变化后的排名不正确, key:", key,
+				" rank:", e.NewRank, " expected:", current.rank)
+			if exist {
+				assert.Assert(e.NewScore == current.score, "变化后的分数不正确, key:", key)
+				assert.Assert(e.NewRevRank() == len(after)-current.rank+1, "变化后的逆序排名不正确, key:", key)
+			}
+			switch e.Type {
+			case ChangeInsert:
+				assert.Assert(!has && e.OldRank == 0 && e.NewRank > 0, "插入的通知不正确, key:", key)
+			case ChangeDelete:
+				assert.Assert(has && e.NewRank == 0, "删除的通知不正确, key:", key)
+			case ChangeUpdate:
+				assert.Assert(has && e.NewRank > 0, "更新分数的通知不正确, key:", key)
+			}
+		}
+		for key, old := range before {
+			if current, has := after[key]; !has || current.score != old.score {
+				assert.Assert(notified[key], "变化的元素没有通知, key:", key)
+			}
+		}
+		for key := range after {
+			if _, has := before[key]; !has {
+				assert.Assert(notified[key], "插入的元素没有通知, key:", key)
+			}
+		}
+		for _, w := range watches {
+			expected := topOf(w)
+			assert.Assert(len(expected) == len(w.top), "前N名的数量不一致, n:", w.n, " rev:", w.rev,
+				" ", len(w.top), " ", len(expected))
+			for key := range expected {
+				assert.Assert(w.top[key], "前N名不一致, n:", w.n, " rev:", w.rev, " key:", key)
+			}
+		}
+	}
+	SortedSetMustLegal(ss)
+}
+
+// 钩子在通知过程中移除自己、移除其他钩子或者注册新的钩子
+// 本次通知按照通知开始时注册的钩子调用，每个钩子恰好调用一次；之后的通知使用修改后的钩子
+func SortedSetHooksRemoveTest() {
+	ss := NewTestCompactSortedSet()
+	calls := map[string]int{}
+	var selfID, otherID int
+	selfID = ss.AddChangeHook(func(e *ChangeEvent[int64, *Val]) {
+		calls["self"]++
+		assert.Assert(ss.RemoveHook(selfID), "移除自己失败")
+		assert.Assert(ss.RemoveHook(otherID), "移除其他钩子失败")
+		ss.AddChangeHook(func(e *ChangeEvent[int64, *Val]) {
+			calls["added"]++
+		})
+	})
+	otherID = ss.AddChangeHook(func(e *ChangeEvent[int64, *Val]) {
+		calls["other"]++
+	})
+	ss.AddChangeHook(func(e *ChangeEvent[int64, *Val]) {
+		calls["last"]++
+	})
+	
+	SortedSetOp_Insert(ss, 1)
+	assert.Assert(calls["self"] == 1 && calls["other"] == 1 && calls["last"] == 1 && calls["added"] == 0,
+		"通知过程中修改钩子不能影响本次通知:", calls)
+	SortedSetOp_Insert(ss, 1)
+	assert.Assert(calls["self"] == 1 && calls["other"] == 1 && calls["last"] == 2 && calls["added"] == 1,
+		"之后的通知需要使用修改后的钩子:", calls)
+}
+
+// 只读视图
+// 随机操作的过程中随机创建视图，并记录创建时的内容，之后视图的内容和查询结果都不能改变
+func SortedSetViewTest(n int, opCnt int) {
+	ss := NewTestCompactSortedSet()
+	SortedSetOp_Insert(ss, n)
+	
+	type frozen struct {
+		view  *SortedSetView[int64, *Val]
+		datas []*NodeData[int64, *Val] // 创建视图时数据的拷贝
+	}
+	take := func() *frozen {
+		f := &frozen{view: ss.Snapshot()}
+		for _, data := range ss.All() {
+			f.datas = append(f.datas, NewNodeData(data.Key, data.Score, data.Val))
+		}
+		return f
+	}
+	mustFrozen := func(f *frozen) {
+		view := f.view
+		assert.Assert(view.Length() == len(f.datas), "视图的长度不能改变:", view.Length(), " ", len(f.datas))
+		for rank, data := range view.All() {
+			expected := f.datas[rank-1]
+			assert.Assert(data.Key == expected.Key && data.Score == expected.Score, "视图的内容不能改变, rank:", rank)
+		}
+		if len(f.datas) == 0 {
+			return
+		}
+		one := f.datas[random2.RandInt(0, len(f.datas)-1)]
+		rank := view.GetRank(one.Key)
+		assert.Assert(f.datas[rank-1].Key == one.Key, "视图中的排名不正确, key:", one.Key)
+		assert.Assert(view.GetRevRank(one.Key) == len(f.datas)-rank+1, "视图中的逆序排名不正确, key:", one.Key)
+		assert.Assert(view.Get(one.Key).Score == one.Score, "视图中的分数不正确, key:", one.Key)
+		count := 0
+		for _, data := range f.datas {
+			if data.Score == one.Score {
+				count++
+			}
+		}
+		assert.Assert(view.CountByScore(one.Score, false, one.Score, false) == count, "视图中按分数统计的数量不正确")
+		if _, _, sumEnabled := view.ss.params(); sumEnabled {
+			// 视图和有序集合共享结点，只能通过视图统计分数和
+			start := random2.RandInt(1, len(f.datas))
+			end := random2.RandInt(start, len(f.datas))
+			expected := 0.0
+			for _, data := range f.datas[start-1 : end] {
+				expected += data.Score
+			}
+			sum, _ := view.SumByRank(start, end)
+			assert.Assert(sum == expected, "视图中的分数和不正确:", sum, " ", expected)
+		}
+	}
+	
+	views := []*frozen{take()}
+	for i := 0; i < opCnt; i++ {
+		if random2.RandInt(0, 9) == 0 {
+			fn := SortedSetOp_RangeHandlers[random2.RandInt(0, len(SortedSetOp_RangeHandlers)-1)]
+			fn(ss, 1)
+		} else {
+			fn := SortedSetOp_Handlers[random2.RandInt(0, len(SortedSetOp_Handlers)-1)]
+			fn(ss, 1)
+		}
+		if random2.RandInt(0, 49) == 0 {
+			views = append(views, take())
+		}
+		if len(views) > 0 && random2.RandInt(0, 99) == 0 {
+			mustFrozen(views[random2.RandInt(0, len(views)-1)])
+		}
+		if len(views) > 0 && random2.RandInt(0, 149) == 0 {
+			// 随机释放视图(全部释放之后不再记录撤销日志)
+			j := random2.RandInt(0, len(views)-1)
+			views[j].view.Release()
+			views = slices.Delete(views, j, j+1)
+		}
+	}
+	SortedSetMustLegal(ss)
+	for _, f := range views {
+		mustFrozen(f)
+	}
+}
+
+// 创建视图之后的修改只复制被修改的结点，数据的归属按日志区分
+// 视图释放之后不再记录撤销日志，更新分数时原地修改数据
+func SortedSetViewCopyTest(n int) {
+	ss := NewTestSortedSet()
+	SortedSetOp_Insert(ss, n)
+	view := ss.Snapshot()
+	// 插入和删除最多修改每一高度的前置结点、头结点和后一个结点
+	bound := 2*ss.Sl.Level + 4
+	SortedSetOp_Insert(ss, 1)
+	assert.Assert(len(ss.Sl.undo.nodes) <= bound, "插入时复制了太多结点:", len(ss.Sl.undo.nodes), " ", bound)
+	
+	one := ss.GetByRank(random2.RandInt(1, n))
+	score := one.Score
+	updated, _ := ss.UpdateScore(one.Key, score+1)
+	assert.Assert(updated != one && one.Score == score && view.Get(one.Key) == one, "视图引用的数据不能被修改")
+	again, _ := ss.UpdateScore(one.Key, score+2)
+	assert.Assert(again == updated, "当前日志中复制出来的数据可以原地修改")
+	assert.Assert(len(ss.Sl.undo.nodes) <= 3*bound, "更新分数时复制了太多结点:", len(ss.Sl.undo.nodes), " ", 3*bound)
+	assert.Assert(view.Length() == n && view.GetByRank(view.GetRank(one.Key)) == one, "视图的内容不能改变")
+	
+	view.Release()
+	view.Release()
+	last, _ := ss.UpdateScore(one.Key, score+3)
+	assert.Assert(ss.Sl.undo == nil && last == again, "视图释放之后不再复制")
+	SortedSetMustLegal(ss)
+	
+	// 释放之后再创建的视图重新开始记录
+	view = ss.Snapshot()
+	first := ss.GetByRank(1)
+	ss.UpdateScore(first.Key, first.Score-1)
+	assert.Assert(ss.Get(first.Key) != first && view.GetByRank(1) == first, "新的视图需要重新记录撤销日志")
+	view.Release()
+}
+
+// 跳跃表的结构必须正确:每一层的跨度、最下层的后退指针、尾结点和高度
+func skipListStructureMustLegal(sl *SkipList[int64, *Val]) {
+	// 每个结点的排名(头结点是0)
+	ranks := map[*Node[int64, *Val]]int{sl.Head: 0}
+	var prev *Node[int64, *Val]
+	rank := 0
+	maxLevel := 0
+	for current := sl.Head.Levels[0].Forward; current != nil; current = current.Levels[0].Forward {
+		rank++
+		ranks[current] = rank
+		assert.Assert(current.Backward == prev, "后退指针不正确, rank:", rank)
+		maxLevel = max(maxLevel, len(current.Levels))
+		prev = current
+	}
+	assert.Assert(rank == sl.Length, "长度不正确:", rank, " ", sl.Length)
+	assert.Assert(sl.Tail == prev, "尾结点不正确")
+	assert.Assert(sl.Level >= maxLevel, "高度不正确:", sl.Level, " ", maxLevel)
+	for i := 0; i < sl.Level; i++ {
+		for current := sl.Head; current != nil; current = current.Levels[i].Forward {
+			next := current.Levels[i].Forward
+			expected := sl.Length - ranks[current]
+			if next != nil {
+				expected = ranks[next] - ranks[current]
+			}
+			assert.Assert(current.Levels[i].Span == expected, "跨度不正确, level:", i,
+				" rank:", ranks[current], " span:", current.Levels[i].Span, " expected:", expected)
+		}
+	}
+}
+
+// 批量构建
+// 构建的结果必须和逐个插入的结果一致，而且结构正确、高度是平衡的；不合法的数据返回错误，有序集合保持不变
+func SortedSetBulkLoadTest(n int) {
+	datas := make([]*NodeData[int64, *Val], 0, n)
+	for i := 0; i < n; i++ {
+		val := NewVal()
+		score := float64(random2.RandInt(TEST_SORTED_SET_SCORE_MIN, TEST_SORTED_SET_SCORE_MAX))
+		datas = append(datas, NewNodeData(val.ID, score, val))
+	}
+	expected := NewTestSortedSet()
+	for _, data := range datas {
+		expected.Insert(data)
+	}
+	
+	ss := NewTestSortedSet()
+	sumEnabled := ss.Sl.SumEnabled
+	assert.Assert(ss.BulkLoad(slices.Clone(datas), false) == nil, "批量构建不会失败")
+	skipListStructureMustLegal(ss.Sl)
+	SortedSetMustLegal(ss)
+	assert.Assert(ss.Sl.SumEnabled == sumEnabled, "分数和的维护不能丢失")
+	assert.Assert(ss.Length() == n, "长度不正确:", ss.Length())
+	for rank, data := range expected.All() {
+		assert.Assert(ss.GetByRank(rank) == data && ss.GetRank(data.Key) == rank, "排名不正确:", rank)
+	}
+	// 高度由排名决定
+	level := 0
+	for rank := 1; rank <= n; rank++ {
+		level = max(level, balancedLevel(rank, ss.Sl.LevelUpProb))
+	}
+	assert.Assert(ss.Sl.Level == level, "高度不正确:", ss.Sl.Level, " ", level)
+	
+	// 不是空的有序集合
+	if n > 0 {
+		err := ss.BulkLoad([]*NodeData[int64, *Val]{}, true)
+		assert.Assert(errors.Is(err, ErrBulkLoadNotEmpty), "必须是空的有序集合:", err)
+	}
+	sortedDatas := make([]*NodeData[int64, *Val], 0, n)
+	for _, data := range expected.All() {
+		sortedDatas = append(sortedDatas, data)
+	}
+	if n >= 2 {
+		// key重复
+		one := NewTestSortedSet()
+		dup := slices.Clone(sortedDatas)
+		i := random2.RandInt(1, n-1)
+		dup[i] = NewNodeData(dup[i-1].Key, dup[i].Score, dup[i].Val)
+		err := one.BulkLoad(dup, true)
+		assert.Assert(errors.Is(err, ErrBulkLoadKey), "key重复时必须失败:", err)
+		assert.Assert(one.Length() == 0 && one.Sl.Tail == nil, "失败时有序集合保持不变")
+		// 不是严格有序的
+		unordered := slices.Clone(sortedDatas)
+		unordered[i-1], unordered[i] = unordered[i], unordered[i-1]
+		err = one.BulkLoad(unordered, true)
+		assert.Assert(errors.Is(err, ErrBulkLoadOrder), "不是严格有序时必须失败:", err)
+		assert.Assert(one.Length() == 0 && one.Sl.Tail == nil, "失败时有序集合保持不变")
+	}
+	
+	// 单独构建跳跃表
+	sl := NewSkipList[int64, *Val](CompareVal)
+	assert.Assert(sl.BulkLoad(sortedDatas) == nil, "批量构建不会失败")
+	skipListStructureMustLegal(sl)
+	for rank, data := range expected.All() {
+		assert.Assert(sl.GetNodeByRank(rank).Data == data, "排名不正确:", rank)
+	}
+	
+	// 构建之后可以正常使用(会修改数据，所以放在最后)
+	for i := 0; i < 100; i++ {
+		SortedSetOp_Handlers[random2.RandInt(0, len(SortedSetOp_Handlers)-1)](ss, 1)
+	}
+	skipListStructureMustLegal(ss.Sl)
+	SortedSetMustLegal(ss)
+}
+
+// 结构校验和结构输出
+// 逐一破坏有序集合的各种结构，Validate必须发现错误；恢复之后必须通过校验
+func SortedSetValidateTest(n int) {
+	ss := NewTestSortedSet()
+	SortedSetOp_Insert(ss, n)
+	for i := 0; i < 100; i++ {
+		SortedSetOp_Handlers[random2.RandInt(0, len(SortedSetOp_Handlers)-1)](ss, 1)
+	}
+	skipListStructureMustLegal(ss.Sl)
+	assert.Assert(ss.Validate() == nil, "结构必须是合法的")
+	mustDetect := func(name string, corrupt func(), restore func()) {
+		corrupt()
+		err := ss.Validate()
+		assert.Assert(errors.Is(err, ErrValidate), "没有发现结构错误:", name, " err:", err)
+		restore()
+		err = ss.Validate()
+		assert.Assert(err == nil, "恢复之后结构必须是合法的:", name, " err:", err)
+	}
+	
+	sl := ss.Sl
+	mustDetect("长度", func() { sl.Length++ }, func() { sl.Length-- })
+	if sl.Level < SKIPLIST_MAXLEVEL {
+		mustDetect("高度", func() { sl.Level++ }, func() { sl.Level-- })
+	}
+	length := ss.Length()
+	if length > 0 {
+		node := sl.GetNodeByRank(random2.RandInt(1, length))
+		i := random2.RandInt(0, node.High()-1)
+		mustDetect("跨度", func() { node.Levels[i].Span++ }, func() { node.Levels[i].Span-- })
+		backward := node.Backward
+		mustDetect("后退指针", func() { node.Backward = node }, func() { node.Backward = backward })
+		tail := sl.Tail
+		mustDetect("尾结点", func() { sl.Tail = nil }, func() { sl.Tail = tail })
+		data := node.Data
+		mustDetect("哈希表中的数据", func() {
+			ss.Hash[data.Key] = NewNodeData(data.Key, data.Score, data.Val)
+		}, func() {
+			ss.Hash[data.Key] = data
+		})
+		mustDetect("哈希表的长度", func() { delete(ss.Hash, data.Key) }, func() { ss.Hash[data.Key] = data })
+		if sl.SumEnabled {
+			mustDetect("分数和", func() { node.Levels[i].Sum++ }, func() { node.Levels[i].Sum-- })
+		}
+	}
+	if length > 1 {
+		rank := random2.RandInt(1, length-1)
+		a, b := sl.GetNodeByRank(rank), sl.GetNodeByRank(rank+1)
+		swap := func() { a.Data, b.Data = b.Data, a.Data }
+		mustDetect("顺序", swap, swap)
+		forward := a.Levels[0].Forward
+		mustDetect("向前指针", func() { a.Levels[0].Forward = nil }, func() { a.Levels[0].Forward = forward })
+	}
+	
+	// 文本格式:长度和高度一行，每一层一行，分数一行
+	var buf bytes.Buffer
+	assert.Assert(ss.DumpText(&buf, 0) == nil, "输出不会失败")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Assert(len(lines) == sl.Level+2, "文本格式的行数不正确:", len(lines))
+	for _, line := range lines[1 : sl.Level+1] {
+		assert.Assert(strings.HasSuffix(line, "NIL"), "每一层都以NIL结束:", line)
+	}
+	// DOT格式:每个结点的每一层各有一条边
+	buf.Reset()
+	assert.Assert(ss.DumpDot(&buf, 0) == nil, "输出不会失败")
+	edges := sl.Level
+	for _, data := range ss.All() {
+		edges += sl.GetNodeByRank(ss.GetRank(data.Key)).High()
+	}
+	assert.Assert(strings.HasPrefix(buf.String(), "digraph skiplist {"), "DOT格式不正确")
+	assert.Assert(strings.Count(buf.String(), " -> ") == edges, "DOT格式的边数不正确:", edges)
+	// 只输出部分结点
+	if length > 3 {
+		buf.Reset()
+		assert.Assert(ss.DumpText(&buf, 3) == nil, "输出不会失败")
+		assert.Assert(strings.Contains(buf.String(), "..."), "超出的结点用...表示")
+	}
+}
+
+// 紧凑编码
+// 和不使用紧凑编码的有序集合执行同样的操作，结果必须一致；元素数量跨过阈值时必须转换编码
+// 转换编码前后创建的视图都必须保持创建时的状态
+func SortedSetCompactTest(maxEntries int, opCnt int) {
+	ss := NewTestSortedSet()
+	ss.SetCompactMaxEntries(maxEntries)
+	expected := NewSortedSet[int64, *Val](CompareVal)
+	// 两个有序集合使用不同的数据(更新分数时会修改数据)
+	insert := func() {
+		val := NewVal()
+		score := float64(random2.RandInt(1, 20))
+		assert.Assert(ss.Insert(NewNodeData(val.ID, score, val)) && expected.Insert(NewNodeData(val.ID, score, val)), "插入不会失败")
+	}
+	randomKey := func() int64 {
+		if expected.Length() == 0 || random2.RandInt(0, 9) == 0 {
+			// 不存在的key
+			return -1
+		}
+		return expected.GetByRank(random2.RandInt(1, expected.Length())).Key
+	}
+	mustMatch := func(datas []*NodeData[int64, *Val], expectedDatas []*NodeData[int64, *Val]) {
+		assert.Assert(len(datas) == len(expectedDatas), "数量不一致:", len(datas), " ", len(expectedDatas))
+		for i, data := range datas {
+			assert.Assert(data.Key == expectedDatas[i].Key && data.Score == expectedDatas[i].Score, "数据不一致, index:", i)
+		}
+	}
+	collect := func(seq iter.Seq2[int, *NodeData[int64, *Val]]) []*NodeData[int64, *Val] {
+		datas := make([]*NodeData[int64, *Val], 0, 4)
+		for _, data := range seq {
+			datas = append(datas, data)
+		}
+		return datas
+	}
+	// 视图和创建视图时的数据(复制一份，不受之后更新分数的影响)
+	type frozen struct {
+		view  *SortedSetView[int64, *Val]
+		datas []*NodeData[int64, *Val]
+	}
+	views := make([]frozen, 0, 4)
+	viewMustMatch := func(f frozen) {
+		mustMatch(f.view.GetRangeByRank(1, max(len(f.datas), 1)), f.datas)
+		mustMatch(collect(f.view.All()), f.datas)
+		for _, data := range f.datas {
+			assert.Assert(f.view.Get(data.Key).Score == data.Score, "视图中的数据被修改了:", data.Key)
+		}
+	}
+	
+	// 超过阈值时转换成跳跃表，减少到阈值的一半时转换回紧凑编码
+	for ss.Length() <= maxEntries {
+		// 阈值为0时总是使用跳跃表
+		assert.Assert(ss.Encoding() == EncodingCompact || maxEntries == 0, "没有超过阈值时是紧凑编码")
+		insert()
+	}
+	assert.Assert(ss.Encoding() == EncodingSkipList, "超过阈值时转换成跳跃表")
+	if maxEntries > 0 {
+		for ss.Length() > maxEntries/2 {
+			assert.Assert(ss.Encoding() == EncodingSkipList, "没有减少到阈值的一半时还是跳跃表")
+			key := randomKey()
+			_, ok1 := ss.Delete(key)
+			_, ok2 := expected.Delete(key)
+			assert.Assert(ok1 == ok2, "删除的结果不一致:", key)
+		}
+		assert.Assert(ss.Encoding() == EncodingCompact, "减少到阈值的一半时转换成紧凑编码")
+	}
+	
+	for i := 0; i < opCnt; i++ {
+		length := expected.Length()
+		min := float64(random2.RandInt(0, 21))
+		max := float64(random2.RandInt(0, 21))
+		minEx, maxEx := random2.RandInt(0, 1) == 0, random2.RandInt(0, 1) == 0
+		start, end := random2.RandInt(1, length+2), random2.RandInt(1, length+2)
+		switch random2.RandInt(0, 13) {
+		case 0, 1, 2:
+			insert()
+		case 3, 4:
+			key := randomKey()
+			data1, ok1 := ss.Delete(key)
+			data2, ok2 := expected.Delete(key)
+			assert.Assert(ok1 == ok2 && (!ok1 || data1.Key == data2.Key), "删除的结果不一致:", key)
+		case 5:
+			key := randomKey()
+			score := float64(random2.RandInt(1, 20))
+			data1, ok1 := ss.UpdateScore(key, score)
+			data2, ok2 := expected.UpdateScore(key, score)
+			assert.Assert(ok1 == ok2 && (!ok1 || data1.Score == data2.Score), "更新分数的结果不一致:", key)
+		case 6:
+			key := randomKey()
+			assert.Assert(ss.GetRank(key) == expected.GetRank(key) && ss.GetRevRank(key) == expected.GetRevRank(key), "排名不一致:", key)
+			if start <= length {
+				mustMatch([]*NodeData[int64, *Val]{ss.GetByRevRank(start)}, []*NodeData[int64, *Val]{expected.GetByRevRank(start)})
+			}
+		case 7:
+			mustMatch(ss.GetRangeByRank(start, end), expected.GetRangeByRank(start, end))
+			mustMatch(ss.GetRevRangeByRank(start, end), expected.GetRevRangeByRank(start, end))
+			mustMatch(collect(ss.IterRangeByRank(start, end)), collect(expected.IterRangeByRank(start, end)))
+		case 8:
+			mustMatch(ss.GetRangeByScore(min, minEx, max, maxEx), expected.GetRangeByScore(min, minEx, max, maxEx))
+			mustMatch(ss.GetRevRangeByScore(max, maxEx, min, minEx), expected.GetRevRangeByScore(max, maxEx, min, minEx))
+			assert.Assert(ss.CountByScore(min, minEx, max, maxEx) == expected.CountByScore(min, minEx, max, maxEx), "数量不一致")
+			mustMatch(collect(ss.IterRangeByScore(min, minEx, max, maxEx)), collect(expected.IterRangeByScore(min, minEx, max, maxEx)))
+		case 9:
+			offset, count := random2.RandInt(0, 5), random2.RandInt(0, 5)-1
+			mustMatch(ss.GetRangeByScoreLimit(min, minEx, max, maxEx, offset, count),
+				expected.GetRangeByScoreLimit(min, minEx, max, maxEx, offset, count))
+			mustMatch(ss.GetRevRangeByScoreLimit(max, maxEx, min, minEx, offset, count),
+				expected.GetRevRangeByScoreLimit(max, maxEx, min, minEx, offset, count))
+		case 10:
+			if random2.RandInt(0, 4) == 0 {
+				mustMatch(ss.DeleteRangeByScore(min, minEx, max, maxEx), expected.DeleteRangeByScore(min, minEx, max, maxEx))
+			} else if start <= length {
+				end = start + random2.RandInt(0, 2)
+				mustMatch(ss.DeleteRangeByRank(start, end), expected.DeleteRangeByRank(start, end))
+			}
+		case 11:
+			count := random2.RandInt(1, 3)
+			if random2.RandInt(0, 1) == 0 {
+				mustMatch(ss.PopMin(count), expected.PopMin(count))
+			} else {
+				mustMatch(ss.PopMax(count), expected.PopMax(count))
+			}
+		case 12:
+			key := randomKey()
+			delta := float64(random2.RandInt(0, 4)) - 2
+			score1, rank1, ok1 := ss.IncrScore(key, delta, &Val{ID: key})
+			score2, rank2, ok2 := expected.IncrScore(key, delta, &Val{ID: key})
+			assert.Assert(score1 == score2 && rank1 == rank2 && ok1 == ok2, "增加分数的结果不一致:", key)
+		case 13:
+			if len(views) > 0 && random2.RandInt(0, 1) == 0 {
+				i := random2.RandInt(0, len(views)-1)
+				viewMustMatch(views[i])
+				views[i].view.Release()
+				views = slices.Delete(views, i, i+1)
+			} else {
+				datas := make([]*NodeData[int64, *Val], 0, length)
+				for _, data := range expected.All() {
+					datas = append(datas, NewNodeData(data.Key, data.Score, data.Val))
+				}
+				views = append(views, frozen{view: ss.Snapshot(), datas: datas})
+			}
+		}
+		SortedSetMustLegal(ss)
+	}
+	mustMatch(collect(ss.All()), collect(expected.All()))
+	mustMatch(collect(ss.Backward()), collect(expected.Backward()))
+	for _, f := range views {
+		viewMustMatch(f)
+		f.view.Release()
+	}
+}
+
+// 复合分数的测试定义:积分降序、胜率升序、名字降序(每个分量的取值范围都很小，保证有大量相同的前缀)
+var testScoreSchema = ScoreSchema{
+	{Name: "rating", Kind: FieldInt, Order: Desc},
+	{Name: "ratio", Kind: FieldFloat, Order: Asc},
+	{Name: "name", Kind: FieldString, Order: Desc},
+}
+
+func randomTestTuple(n int) Tuple {
+	t := Tuple{
+		IntValue(int64(random2.RandInt(0, 10) - 5)),
+		FloatValue(float64(random2.RandInt(0, 4)) / 4),
+		StringValue(string(rune('a' + random2.RandInt(0, 3)))),
+	}
+	return t[:n]
+}
+
+// 暴力比较两个元组的前缀(不使用ScoreSchema.ComparePrefix，用来验证它的实现)
+func compareTestTuple(a, b Tuple) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		var x, y float64
+		switch i {
+		case 0:
+			// 降序
+			x, y = float64(-a[i].Int), float64(-b[i].Int)
+		case 1:
+			x, y = a[i].Float, b[i].Float
+		case 2:
+			// 降序
+			x, y = float64(-int(a[i].Str[0])), float64(-int(b[i].Str[0]))
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// 复合分数
+// 随机插入、删除、更新复合分数，按排名和元组前缀的范围查询，结果必须和暴力排序的一致
+func SortedSetCompositeTest(n int, opCnt int) {
+	ss := NewCompositeSortedSet[int64, int64](testScoreSchema, func(a, b int64) int {
+		return int(a - b)
+	})
+	scores := map[int64]Tuple{}
+	nextKey := int64(0)
+	insert := func() {
+		nextKey++
+		score := randomTestTuple(3)
+		assert.Assert(ss.Insert(nextKey, score, -nextKey), "插入不会失败:", nextKey)
+		scores[nextKey] = score
+	}
+	// 暴力计算出所有元素的先后顺序
+	sorted := func() []int64 {
+		keys := make([]int64, 0, len(scores))
+		for key := range scores {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, func(a, b int64) int {
+			if c := compareTestTuple(scores[a], scores[b]); c != 0 {
+				return c
+			}
+			return int(a - b)
+		})
+		return keys
+	}
+	// 暴力计算出范围内的元素
+	inRange := func(min Tuple, minEx bool, max Tuple, maxEx bool) []int64 {
+		keys := make([]int64, 0, 4)
+		for _, key := range sorted() {
+			c1, c2 := compareTestTuple(scores[key], min), compareTestTuple(scores[key], max)
+			if (min == nil || c1 > 0 || c1 == 0 && !minEx) && (max == nil || c2 < 0 || c2 == 0 && !maxEx) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	mustMatch := func(datas []*CompositeData[int64, int64], keys []int64) {
+		assert.Assert(len(datas) == len(keys), "数量不一致:", len(datas), " ", len(keys))
+		for i, data := range datas {
+			assert.Assert(data.Key == keys[i] && data.Val == -data.Key, "元素不一致:", data.Key, " ", keys[i])
+			assert.Assert(compareTestTuple(data.Score, scores[data.Key]) == 0, "复合分数不一致:", data.Key)
+		}
+	}
+	randomBound := func() (Tuple, bool) {
+		if random2.RandInt(0, 5) == 0 {
+			return nil, false
+		}
+		return randomTestTuple(random2.RandInt(0, 3)), random2.RandInt(0, 1) == 0
+	}
+	
+	for i := 0; i < n; i++ {
+		insert()
+	}
+	for i := 0; i < opCnt; i++ {
+		keys := sorted()
+		switch random2.RandInt(0, 7) {
+		case 0:
+			insert()
+		case 1:
+			if len(keys) > 0 {
+				key := keys[random2.RandInt(0, len(keys)-1)]
+				data, ok := ss.Delete(key)
+				assert.Assert(ok && data.Key == key, "删除不会失败:", key)
+				delete(scores, key)
+			}
+		case 2:
+			if len(keys) > 0 {
+				key := keys[random2.RandInt(0, len(keys)-1)]
+				score := randomTestTuple(3)
+				data, ok := ss.UpdateScore(key, score)
+				assert.Assert(ok && data.Key == key && data.Val == -key, "更新不会失败:", key)
+				scores[key] = score
+			}
+		case 3:
+			// 排名
+			if len(keys) > 0 {
+				rank := random2.RandInt(1, len(keys))
+				key := keys[rank-1]
+				assert.Assert(ss.GetByRank(rank).Key == key && ss.GetRank(key) == rank, "排名不正确:", rank)
+				assert.Assert(ss.GetRevRank(key) == len(keys)-rank+1, "逆序排名不正确:", rank)
+			}
+		case 4:
+			start, end := random2.RandInt(1, len(keys)+1), random2.RandInt(1, len(keys)+1)
+			if start > end {
+				start, end = end, start
+			}
+			expected := keys[min(start, len(keys)+1)-1 : min(end, len(keys))]
+			mustMatch(ss.GetRangeByRank(start, end), expected)
+			reversed := slices.Clone(keys)
+			slices.Reverse(reversed)
+			mustMatch(ss.GetRevRangeByRank(start, end), reversed[min(start, len(keys)+1)-1:min(end, len(keys))])
+		case 5:
+			lo, loEx := randomBound()
+			hi, hiEx := randomBound()
+			expected := inRange(lo, loEx, hi, hiEx)
+			mustMatch(ss.GetRangeByScore(lo, loEx, hi, hiEx), expected)
+			assert.Assert(ss.CountByScore(lo, loEx, hi, hiEx) == len(expected), "数量不一致")
+			offset, count := random2.RandInt(0, len(expected)+1), random2.RandInt(0, 6)-1
+			expected = expected[min(offset, len(expected)):]
+			if count >= 0 && count < len(expected) {
+				expected = expected[:count]
+			}
+			mustMatch(ss.GetRangeByScoreLimit(lo, loEx, hi, hiEx, offset, count), expected)
+		case 6:
+			if random2.RandInt(0, 10) == 0 {
+				lo, loEx := randomBound()
+				hi, hiEx := randomBound()
+				expected := inRange(lo, loEx, hi, hiEx)
+				mustMatch(ss.DeleteRangeByScore(lo, loEx, hi, hiEx), expected)
+				for _, key := range expected {
+					delete(scores, key)
+				}
+			}
+		case 7:
+			if len(keys) > 0 && random2.RandInt(0, 10) == 0 {
+				start := random2.RandInt(1, len(keys))
+				end := random2.RandInt(start, len(keys))
+				mustMatch(ss.DeleteRangeByRank(start, end), keys[start-1:end])
+				for _, key := range keys[start-1 : end] {
+					delete(scores, key)
+				}
+			}
+		}
+	}
+	all := make([]*CompositeData[int64, int64], 0, ss.Length())
+	for _, data := range ss.All() {
+		all = append(all, data)
+	}
+	mustMatch(all, sorted())
+	assert.Assert(ss.Length() == len(scores), "长度不一致:", ss.Length(), " ", len(scores))
 }
 
 func SortedSetTest(total int) {
@@ -286,38 +2181,97 @@ func SortedSetTest(total int) {
 	for a := 1; a <= total; a++ {
 		fmt.Printf("-------第%d轮测试开始-------\n", a)
 		for k, n := range nums {
-			ss := NewSortedSet()
-			// 插入指定数量的元素
-			SortedSetOp_Insert(ss, n)
-			
-			// 基本操作的测试次数
-			opCnt := random2.RandInt(1e5, 1e5)
-			// range相关操作都很耗时，减少测试的量级
-			rangeOpCnt := random2.RandInt(10, 10)
-			opWeights := []int{opCnt, rangeOpCnt}
-			
-			realCnt := []int{0, 0}
-			// 根据操作次数得到对应的执行概率
-			aliasMethod := probability_distribution.ProbFactory(probability_distribution.VoseAlias, opWeights)
-			for i := 0; i < opCnt+rangeOpCnt; i++ {
-				index := aliasMethod.Generate()
-				if index == 0 {
-					op := random2.RandInt(0, len(SortedSetOp_Handlers)-1)
-					fn := SortedSetOp_Handlers[op]
-					fn(ss, 1)
-				} else if index == 1 {
-					rangeOp := random2.RandInt(0, len(SortedSetOp_RangeHandlers)-1)
-					fn := SortedSetOp_RangeHandlers[rangeOp]
-					fn(ss, 1)
-				} else {
-					assert.Assert(false, "不应该执行到这里", opCnt, " ", rangeOpCnt)
+			// 跳跃表、树堆和紧凑编码执行同样的随机测试
+			for _, ss := range []TestISortedSet{NewTestSortedSet(), NewTestTreapSortedSet(), NewTestCompactSortedSet()} {
+				// 插入指定数量的元素
+				SortedSetOp_Insert(ss, n)
+				
+				// 基本操作的测试次数
+				opCnt := random2.RandInt(1e5, 1e5)
+				// range相关操作都很耗时，减少测试的量级
+				rangeOpCnt := random2.RandInt(10, 10)
+				opWeights := []int{opCnt, rangeOpCnt}
+				
+				realCnt := []int{0, 0}
+				// 根据操作次数得到对应的执行概率
+				aliasMethod := probability_distribution.ProbFactory(probability_distribution.VoseAlias, opWeights)
+				for i := 0; i < opCnt+rangeOpCnt; i++ {
+					index := aliasMethod.Generate()
+					if index == 0 {
+						op := random2.RandInt(0, len(SortedSetOp_Handlers)-1)
+						fn := SortedSetOp_Handlers[op]
+						fn(ss, 1)
+					} else if index == 1 {
+						rangeOp := random2.RandInt(0, len(SortedSetOp_RangeHandlers)-1)
+						fn := SortedSetOp_RangeHandlers[rangeOp]
+						fn(ss, 1)
+					} else {
+						assert.Assert(false, "不应该执行到这里", opCnt, " ", rangeOpCnt)
+					}
+					realCnt[index]++
 				}
-				realCnt[index]++
+				ISortedSetMustLegal(ss)
+				if one, ok := ss.(*TestSortedSet); ok {
+					SortedSetSnapshotFileTest(one)
+				}
+				fmt.Printf("测试#%d结束(%T). 初始长度:%d, 当前长度:%d, 执行基本操作:%d次(理论:%d)，执行range操作:%d次(理论:%d)\n",
+					k+1, ss, n, ss.Length(), realCnt[0], opCnt, realCnt[1], rangeOpCnt)
 			}
-			SortedSetMustLegal(ss)
-			fmt.Printf("测试#%d结束. 初始长度:%d, 当前长度:%d, 执行基本操作:%d次(理论:%d)，执行range操作:%d次(理论:%d)\n",
-				k+1, n, ss.Length(), realCnt[0], opCnt, realCnt[1], rangeOpCnt)
 		}
+		for _, n := range nums[:len(nums)-2] {
+			SortedSetValueRangeTest(NewTestSortedSet(), n)
+			SortedSetValueRangeTest(NewTestTreapSortedSet(), n)
+			SortedSetValueRangeTest(NewTestCompactSortedSet(), n)
+		}
+		fmt.Printf("按值范围操作测试结束\n")
+		for _, n := range nums[:len(nums)-3] {
+			SortedSetJournalTest(n, 1000)
+		}
+		SortedSetJournalExpireTest()
+		fmt.Printf("操作日志测试结束\n")
+		for _, n := range nums[:len(nums)-2] {
+			SortedSetSetOpsTest(n)
+		}
+		SortedSetSetOpsTieTest()
+		fmt.Printf("集合运算测试结束\n")
+		for _, n := range nums[:len(nums)-3] {
+			SortedSetExpireTest(n, 1000)
+		}
+		fmt.Printf("过期时间测试结束\n")
+		SortedSetRandMemberTest()
+		fmt.Printf("随机获取元素测试结束\n")
+		for _, n := range nums[:len(nums)-3] {
+			SortedSetHooksTest(n, 1000)
+		}
+		SortedSetHooksRemoveTest()
+		fmt.Printf("排名变化通知测试结束\n")
+		for _, n := range nums[:len(nums)-3] {
+			SortedSetViewTest(n, 1000)
+		}
+		for _, n := range nums[9 : len(nums)-2] {
+			SortedSetViewCopyTest(n)
+		}
+		fmt.Printf("只读视图测试结束\n")
+		for _, n := range nums[:len(nums)-3] {
+			SortedSetCompositeTest(n, 1000)
+		}
+		fmt.Printf("复合分数测试结束\n")
+		for _, n := range nums[:len(nums)-1] {
+			SortedSetBulkLoadTest(n)
+		}
+		fmt.Printf("批量构建测试结束\n")
+		for _, n := range nums[:len(nums)-4] {
+			SortedSetMaxLevelTest(n)
+		}
+		fmt.Printf("最大高度测试结束\n")
+		for _, n := range nums[:len(nums)-3] {
+			SortedSetValidateTest(n)
+		}
+		fmt.Printf("结构校验测试结束\n")
+		for _, maxEntries := range []int{0, 1, 2, 3, 8, 64, 128} {
+			SortedSetCompactTest(maxEntries, 10000)
+		}
+		fmt.Printf("紧凑编码测试结束\n")
 		fmt.Printf("-------第%d轮测试结束-------\n\n", a)
 	}
 	println("有序集合测试结束...")
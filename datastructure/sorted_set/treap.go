@@ -0,0 +1,404 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 树堆(treap)
+// 按数据有序的二叉搜索树，同时结点的随机优先级满足堆的性质(父结点的优先级不小于子结点)，期望高度O(logn)
+// 每个结点记录子树的大小(顺序统计树)，通过子树大小计算排名，排名相关的操作都是O(logn)
+// 和跳跃表相比，每个结点占用的内存是固定的(两个子结点指针、子树大小、优先级)，没有多层索引
+// 插入、删除、按排名范围删除都通过分裂(split)和合并(merge)实现
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"iter"
+	"math"
+)
+
+type TreapNode[K comparable, V any] struct {
+	Left     *TreapNode[K, V] // 左子树(数据都比当前结点小)
+	Right    *TreapNode[K, V] // 右子树(数据都比当前结点大)
+	Size     int              // 子树的结点数量(包含自身)
+	Priority int32            // 随机优先级
+	Data     *NodeData[K, V]  // 结点携带的数据(包含分数)
+}
+
+type Treap[K comparable, V any] struct {
+	Root *TreapNode[K, V] // 根结点(空树时为nil)
+	Cmp  Comparator[V]    // 分数相同时，比较卫星数据的大小
+}
+
+func NewTreap[K comparable, V any](cmp Comparator[V]) *Treap[K, V] {
+	assert.Assert(cmp != nil, "比较器不能为nil")
+	return &Treap[K, V]{
+		Cmp: cmp,
+	}
+}
+
+// 子树的结点数量(空树为0)
+func treapSize[K comparable, V any](node *TreapNode[K, V]) int {
+	if node == nil {
+		return 0
+	}
+	return node.Size
+}
+
+// 子结点变化后重新计算子树的大小
+func (this *TreapNode[K, V]) update() {
+	this.Size = treapSize(this.Left) + treapSize(this.Right) + 1
+}
+
+func (this *Treap[K, V]) Length() int {
+	return treapSize(this.Root)
+}
+
+// 比较两个结点数据的大小(和跳跃表的顺序一致)
+func (this *Treap[K, V]) CompareData(a, b *NodeData[K, V]) int {
+	if a.Score < b.Score {
+		return -1
+	}
+	if a.Score > b.Score {
+		return 1
+	}
+	return this.Cmp(a.Val, b.Val)
+}
+
+// 分裂:比data小的结点在左边的树中，其余的结点在右边的树中
+func (this *Treap[K, V]) split(node *TreapNode[K, V], data *NodeData[K, V]) (*TreapNode[K, V], *TreapNode[K, V]) {
+	if node == nil {
+		return nil, nil
+	}
+	if this.CompareData(node.Data, data) < 0 {
+		left, right := this.split(node.Right, data)
+		node.Right = left
+		node.update()
+		return node, right
+	}
+	left, right := this.split(node.Left, data)
+	node.Left = right
+	node.update()
+	return left, node
+}
+
+// 按排名分裂:前k个结点在左边的树中，其余的结点在右边的树中
+func splitTreapByRank[K comparable, V any](node *TreapNode[K, V], k int) (*TreapNode[K, V], *TreapNode[K, V]) {
+	if node == nil {
+		return nil, nil
+	}
+	if leftSize := treapSize(node.Left); k <= leftSize {
+		left, right := splitTreapByRank(node.Left, k)
+		node.Left = right
+		node.update()
+		return left, node
+	} else {
+		left, right := splitTreapByRank(node.Right, k-leftSize-1)
+		node.Right = left
+		node.update()
+		return node, right
+	}
+}
+
+// 合并:需要由调用者保证a中的数据都比b中的数据小
+func mergeTreap[K comparable, V any](a *TreapNode[K, V], b *TreapNode[K, V]) *TreapNode[K, V] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	// 优先级高的结点作为根
+	if a.Priority >= b.Priority {
+		a.Right = mergeTreap(a.Right, b)
+		a.update()
+		return a
+	}
+	b.Left = mergeTreap(a, b.Left)
+	b.update()
+	return b
+}
+
+// 查找数据对应的结点(没有找到返回nil)
+// 时间复杂度O(logn)
+func (this *Treap[K, V]) Find(data *NodeData[K, V]) *TreapNode[K, V] {
+	node := this.Root
+	for node != nil {
+		c := this.CompareData(data, node.Data)
+		if c == 0 {
+			return node
+		}
+		if c < 0 {
+			node = node.Left
+		} else {
+			node = node.Right
+		}
+	}
+	return nil
+}
+
+// 插入新结点(结点已存在时插入失败)
+// 时间复杂度O(logn)
+func (this *Treap[K, V]) Insert(data *NodeData[K, V]) bool {
+	assert.Assert(data != nil, "data must not be nil")
+	assert.Assert(!math.IsNaN(data.Score), "score is not a number:", data.Score)
+	if this.Find(data) != nil {
+		return false
+	}
+	node := &TreapNode[K, V]{
+		Size:     1,
+		Priority: random(),
+		Data:     data,
+	}
+	this.Root = this.insert(this.Root, node)
+	return true
+}
+
+// 沿着查找路径向下，直到新结点的优先级比子树的根高，再把子树分裂成新结点的左右子树
+func (this *Treap[K, V]) insert(root *TreapNode[K, V], node *TreapNode[K, V]) *TreapNode[K, V] {
+	if root == nil {
+		return node
+	}
+	if node.Priority > root.Priority {
+		node.Left, node.Right = this.split(root, node.Data)
+		node.update()
+		return node
+	}
+	if this.CompareData(node.Data, root.Data) < 0 {
+		root.Left = this.insert(root.Left, node)
+	} else {
+		root.Right = this.insert(root.Right, node)
+	}
+	root.update()
+	return root
+}
+
+// 删除结点(结点不存在时删除失败)
+// 时间复杂度O(logn)
+func (this *Treap[K, V]) Delete(data *NodeData[K, V]) bool {
+	assert.Assert(data != nil, "data must not be nil")
+	if this.Find(data) == nil {
+		return false
+	}
+	this.Root = this.delete(this.Root, data)
+	return true
+}
+
+// 找到结点后，合并它的左右子树来代替它
+func (this *Treap[K, V]) delete(root *TreapNode[K, V], data *NodeData[K, V]) *TreapNode[K, V] {
+	c := this.CompareData(data, root.Data)
+	if c == 0 {
+		return mergeTreap(root.Left, root.Right)
+	}
+	if c < 0 {
+		root.Left = this.delete(root.Left, data)
+	} else {
+		root.Right = this.delete(root.Right, data)
+	}
+	root.update()
+	return root
+}
+
+// 获取排名(没有找到返回0)
+// 时间复杂度O(logn)
+func (this *Treap[K, V]) GetRank(data *NodeData[K, V]) int {
+	rank := 0
+	node := this.Root
+	for node != nil {
+		c := this.CompareData(data, node.Data)
+		if c < 0 {
+			node = node.Left
+			continue
+		}
+		// 左子树和当前结点都排在前面
+		rank += treapSize(node.Left) + 1
+		if c == 0 {
+			return rank
+		}
+		node = node.Right
+	}
+	return 0
+}
+
+// 通过排名获取结点(排名超出范围时返回nil)
+// 时间复杂度O(logn)
+func (this *Treap[K, V]) GetNodeByRank(rank int) *TreapNode[K, V] {
+	assert.Assert(rank > 0, "rank must be positive number, rank:", rank)
+	node := this.Root
+	for node != nil {
+		leftSize := treapSize(node.Left)
+		if rank == leftSize+1 {
+			return node
+		}
+		if rank <= leftSize {
+			node = node.Left
+		} else {
+			rank -= leftSize + 1
+			node = node.Right
+		}
+	}
+	return nil
+}
+
+// 删除指定排名范围的结点(按排名从低到高返回)
+// 分裂出排名范围对应的子树，再合并剩下的两棵树，时间复杂度O(logn+m)(m是删除的结点数量)
+func (this *Treap[K, V]) DeleteRangeByRank(start int, end int) []*NodeData[K, V] {
+	assert.Assert(start > 0 && end > 0 && start <= end, "rank范围不合法, start:", start, " end:", end)
+	end = min(end, this.Length())
+	if start > end {
+		return []*NodeData[K, V]{}
+	}
+	left, rest := splitTreapByRank(this.Root, start-1)
+	middle, right := splitTreapByRank(rest, end-start+1)
+	this.Root = mergeTreap(left, right)
+	
+	deleted := make([]*NodeData[K, V], 0, end-start+1)
+	for _, data := range (&Treap[K, V]{Root: middle, Cmp: this.Cmp}).Ascend(1) {
+		deleted = append(deleted, data)
+	}
+	return deleted
+}
+
+// 满足条件的数据的数量
+// 需要由调用者保证条件是单调的:满足条件的数据都排在不满足条件的数据前面
+// 时间复杂度O(logn)
+func (this *Treap[K, V]) countWhile(pred func(data *NodeData[K, V]) bool) int {
+	count := 0
+	node := this.Root
+	for node != nil {
+		if pred(node.Data) {
+			count += treapSize(node.Left) + 1
+			node = node.Right
+		} else {
+			node = node.Left
+		}
+	}
+	return count
+}
+
+// 分数范围内首尾结点的排名
+// 范围内没有结点时返回(0, 0)
+func (this *Treap[K, V]) rankRangeByScore(r *RangeSpecified) (int, int) {
+	assert.Assert(r != nil, "r range cannot be nil")
+	// 分数小于范围最小值的结点都排在前面
+	first := this.countWhile(func(data *NodeData[K, V]) bool {
+		return !scoreGeaterThanMin(data.Score, r)
+	}) + 1
+	last := this.countWhile(func(data *NodeData[K, V]) bool {
+		return scoreLessThanMax(data.Score, r)
+	})
+	if first > last {
+		return 0, 0
+	}
+	return first, last
+}
+
+// 值范围内首尾结点的排名
+// 和跳跃表一样，只有当所有元素的分数都相同时结果才有意义
+func (this *Treap[K, V]) rankRangeByValue(r *ValueRangeSpecified[V]) (int, int) {
+	assert.Assert(r != nil, "r range cannot be nil")
+	first := this.countWhile(func(data *NodeData[K, V]) bool {
+		return !valueGeaterThanMin(this.Cmp, data.Val, r)
+	}) + 1
+	last := this.countWhile(func(data *NodeData[K, V]) bool {
+		return valueLessThanMax(this.Cmp, data.Val, r)
+	})
+	if first > last {
+		return 0, 0
+	}
+	return first, last
+}
+
+/*
+	遍历
+	从指定排名的结点开始中序遍历，用栈记录还没有遍历的祖先结点，遍历m个结点的时间复杂度O(logn+m)
+*/
+
+// 从排名为start的结点开始，按分数从低到高遍历
+func (this *Treap[K, V]) Ascend(start int) iter.Seq2[int, *NodeData[K, V]] {
+	assert.Assert(start > 0, "rank must be positive number, start:", start)
+	
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		// 向左走时，当前结点排在目标结点之后，需要入栈
+		stack := make([]*TreapNode[K, V], 0, 32)
+		node, k := this.Root, start
+		for node != nil {
+			leftSize := treapSize(node.Left)
+			if k <= leftSize {
+				stack = append(stack, node)
+				node = node.Left
+			} else if k == leftSize+1 {
+				stack = append(stack, node)
+				break
+			} else {
+				k -= leftSize + 1
+				node = node.Right
+			}
+		}
+		rank := start
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(rank, top.Data) {
+				return
+			}
+			rank++
+			// 后继结点在右子树的最左边
+			for child := top.Right; child != nil; child = child.Left {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
+
+// 从排名为start的结点开始，按分数从高到低遍历(产生的是正序排名)
+func (this *Treap[K, V]) Descend(start int) iter.Seq2[int, *NodeData[K, V]] {
+	assert.Assert(start > 0, "rank must be positive number, start:", start)
+	
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		if start > this.Length() {
+			return
+		}
+		// 向右走时，当前结点排在目标结点之前，需要入栈
+		stack := make([]*TreapNode[K, V], 0, 32)
+		node, k := this.Root, start
+		for node != nil {
+			leftSize := treapSize(node.Left)
+			if k <= leftSize {
+				node = node.Left
+			} else if k == leftSize+1 {
+				stack = append(stack, node)
+				break
+			} else {
+				stack = append(stack, node)
+				k -= leftSize + 1
+				node = node.Right
+			}
+		}
+		rank := start
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(rank, top.Data) {
+				return
+			}
+			rank--
+			// 前驱结点在左子树的最右边
+			for child := top.Left; child != nil; child = child.Right {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
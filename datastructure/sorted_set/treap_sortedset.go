@@ -0,0 +1,393 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 基于树堆的有序集合
+// 和基于跳跃表的SortedSet语义相同(排名从1开始，分数相同时由比较器决定先后顺序)
+// 只实现ISortedSet接口中的操作，不支持过期时间、操作日志、快照、钩子等附加功能
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"github.com/stormYuanYang/yytools/common/assert"
+	"iter"
+)
+
+type TreapSortedSet[K comparable, V any] struct {
+	Tp   *Treap[K, V]
+	Hash map[K]*NodeData[K, V]
+}
+
+// cmp用于分数相同时比较卫星数据，决定元素的先后顺序
+func NewTreapSortedSet[K comparable, V any](cmp Comparator[V]) *TreapSortedSet[K, V] {
+	return &TreapSortedSet[K, V]{
+		Tp:   NewTreap[K, V](cmp),
+		Hash: map[K]*NodeData[K, V]{},
+	}
+}
+
+/*
+	基本操作
+*/
+
+func (this *TreapSortedSet[K, V]) Get(key K) *NodeData[K, V] {
+	return this.Hash[key]
+}
+
+func (this *TreapSortedSet[K, V]) Insert(data *NodeData[K, V]) bool {
+	assert.Assert(data != nil, "data == nil")
+	if _, has := this.Hash[data.Key]; has {
+		// 不能重复插入
+		return false
+	}
+	ok := this.Tp.Insert(data)
+	assert.Assert(ok, "insert must success, data.Key:", data.Key)
+	this.Hash[data.Key] = data
+	this.lengthMustEqual()
+	return ok
+}
+
+func (this *TreapSortedSet[K, V]) Delete(key K) (*NodeData[K, V], bool) {
+	data, exist := this.Hash[key]
+	if !exist {
+		return nil, false
+	}
+	ok := this.Tp.Delete(data)
+	assert.Assert(ok, "delete must success, key:", key)
+	delete(this.Hash, key)
+	this.lengthMustEqual()
+	return data, ok
+}
+
+func (this *TreapSortedSet[K, V]) Length() int {
+	return this.Tp.Length()
+}
+
+func (this *TreapSortedSet[K, V]) lengthMustEqual() {
+	assert.Assert(this.Tp.Length() == len(this.Hash),
+		"长度不一致 treap length:", this.Tp.Length(), " hash length:", len(this.Hash))
+}
+
+// 添加或者更新元素(参考redis的ZADD)
+// 元素已存在时只更新分数，卫星数据保持不变
+func (this *TreapSortedSet[K, V]) Add(data *NodeData[K, V], flags AddFlag) bool {
+	return addData[K, V](this, data, flags)
+}
+
+// 增加元素的分数(参考redis的ZINCRBY)
+func (this *TreapSortedSet[K, V]) IncrScore(key K, delta float64, val V) (float64, int, bool) {
+	return incrScore[K, V](this, key, delta, val)
+}
+
+// 更新分数
+// 先从树堆中删除，修改分数后再插入
+func (this *TreapSortedSet[K, V]) UpdateScore(key K, newScore float64) (*NodeData[K, V], bool) {
+	data, exist := this.Hash[key]
+	if !exist {
+		return nil, false
+	}
+	ok := this.Tp.Delete(data)
+	assert.Assert(ok, "delete must success, key:", key)
+	data.Score = newScore
+	ok = this.Tp.Insert(data)
+	assert.Assert(ok, "insert must success, key:", key)
+	this.lengthMustEqual()
+	return data, ok
+}
+
+/*
+	排名相关操作
+*/
+
+// 获取排名
+func (this *TreapSortedSet[K, V]) GetRank(key K) int {
+	data, exist := this.Hash[key]
+	if !exist {
+		return 0
+	}
+	rank := this.Tp.GetRank(data)
+	// 一定能找到排名(哈希表保证了元素一定存在)
+	assert.Assert(rank != 0, "rank must exist")
+	return rank
+}
+
+// 获取逆序排名(分数最高的元素逆序排名为1)
+func (this *TreapSortedSet[K, V]) GetRevRank(key K) int {
+	rank := this.GetRank(key)
+	if rank == 0 {
+		return 0
+	}
+	return this.Length() - rank + 1
+}
+
+// 通过指定排名获得数据
+func (this *TreapSortedSet[K, V]) GetByRank(rank int) *NodeData[K, V] {
+	node := this.Tp.GetNodeByRank(rank)
+	if node == nil {
+		return nil
+	}
+	return node.Data
+}
+
+// 通过指定逆序排名获得数据
+func (this *TreapSortedSet[K, V]) GetByRevRank(rank int) *NodeData[K, V] {
+	assert.Assert(rank > 0, "rank must be positive number")
+	if rank > this.Length() {
+		return nil
+	}
+	return this.GetByRank(this.Length() - rank + 1)
+}
+
+// 获得指定排名范围的数据
+func (this *TreapSortedSet[K, V]) GetRangeByRank(start int, end int) []*NodeData[K, V] {
+	datas := make([]*NodeData[K, V], 0, 4)
+	for _, data := range this.IterRangeByRank(start, end) {
+		datas = append(datas, data)
+	}
+	return datas
+}
+
+// 获得指定逆序排名范围的数据(按分数从高到低返回)
+func (this *TreapSortedSet[K, V]) GetRevRangeByRank(start int, end int) []*NodeData[K, V] {
+	if start > end {
+		start, end = end, start
+	}
+	assert.Assert(start > 0, "rank范围不合法, start:", start, " end:", end)
+	datas := make([]*NodeData[K, V], 0, 4)
+	length := this.Length()
+	if start > length {
+		return datas
+	}
+	end = min(end, length)
+	// 逆序排名为start的结点，其正序排名就是length-start+1
+	for _, data := range this.Tp.Descend(length - start + 1) {
+		if len(datas) > end-start {
+			break
+		}
+		datas = append(datas, data)
+	}
+	return datas
+}
+
+// 删除指定排名范围的数据
+func (this *TreapSortedSet[K, V]) DeleteRangeByRank(start int, end int) []*NodeData[K, V] {
+	if start > end {
+		start, end = end, start
+	}
+	deleted := this.Tp.DeleteRangeByRank(start, end)
+	// 同步删除哈希表中映射的数据
+	for _, one := range deleted {
+		delete(this.Hash, one.Key)
+	}
+	this.lengthMustEqual()
+	return deleted
+}
+
+// 删除并返回分数最低的count个元素(按分数从低到高返回)
+func (this *TreapSortedSet[K, V]) PopMin(count int) []*NodeData[K, V] {
+	assert.Assert(count > 0, "count must be positive number")
+	if this.Length() == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.DeleteRangeByRank(1, count)
+}
+
+// 删除并返回分数最高的count个元素(按分数从高到低返回)
+func (this *TreapSortedSet[K, V]) PopMax(count int) []*NodeData[K, V] {
+	assert.Assert(count > 0, "count must be positive number")
+	length := this.Length()
+	if length == 0 {
+		return []*NodeData[K, V]{}
+	}
+	start := max(length-count+1, 1)
+	deleted := this.DeleteRangeByRank(start, length)
+	// 删除得到的结果是从低到高的，需要反转
+	for i, j := 0, len(deleted)-1; i < j; i, j = i+1, j-1 {
+		deleted[i], deleted[j] = deleted[j], deleted[i]
+	}
+	return deleted
+}
+
+/*
+	分数相关操作
+*/
+
+func newRangeSpecified(min float64, minEx bool, max float64, maxEx bool) *RangeSpecified {
+	return &RangeSpecified{
+		RangeSpecifiedBase: RangeSpecifiedBase{
+			MinExclusive: minEx,
+			MaxExclusive: maxEx,
+		},
+		Min: min,
+		Max: max,
+	}
+}
+
+// 通过分数范围(开闭区间由调用者指定)得到若干数据
+func (this *TreapSortedSet[K, V]) GetRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V] {
+	return this.GetRangeByScoreLimit(min, minEx, max, maxEx, 0, -1)
+}
+
+// 通过分数范围(开闭区间由调用者指定)得到若干数据,按分数从高到低返回
+func (this *TreapSortedSet[K, V]) GetRevRangeByScore(max float64, maxEx bool, min float64, minEx bool) []*NodeData[K, V] {
+	return this.GetRevRangeByScoreLimit(max, maxEx, min, minEx, 0, -1)
+}
+
+// 分页获取分数范围(开闭区间由调用者指定)内的数据
+// 跳过前offset个数据，最多返回count个(count小于0表示不限制数量)
+func (this *TreapSortedSet[K, V]) GetRangeByScoreLimit(min float64, minEx bool, max float64, maxEx bool, offset int, count int) []*NodeData[K, V] {
+	assert.Assert(offset >= 0, "offset must not be negative:", offset)
+	start, end := this.Tp.rankRangeByScore(newRangeSpecified(min, minEx, max, maxEx))
+	start += offset
+	if start == offset || start > end || count == 0 {
+		// 范围内没有结点，或者跳过了所有结点
+		return []*NodeData[K, V]{}
+	}
+	if count > 0 && start+count-1 < end {
+		// 参数min和max遮盖了内置函数
+		end = start + count - 1
+	}
+	return this.GetRangeByRank(start, end)
+}
+
+// 分页获取分数范围(开闭区间由调用者指定)内的数据,按分数从高到低返回
+func (this *TreapSortedSet[K, V]) GetRevRangeByScoreLimit(max float64, maxEx bool, min float64, minEx bool, offset int, count int) []*NodeData[K, V] {
+	assert.Assert(offset >= 0, "offset must not be negative:", offset)
+	first, last := this.Tp.rankRangeByScore(newRangeSpecified(min, minEx, max, maxEx))
+	if first == 0 || count == 0 {
+		return []*NodeData[K, V]{}
+	}
+	// 转换成逆序排名
+	length := this.Length()
+	start := length - last + 1 + offset
+	end := length - first + 1
+	if start > end {
+		return []*NodeData[K, V]{}
+	}
+	if count > 0 && start+count-1 < end {
+		// 参数min和max遮盖了内置函数
+		end = start + count - 1
+	}
+	return this.GetRevRangeByRank(start, end)
+}
+
+// 统计分数范围(开闭区间由调用者指定)内的数据数量
+// 时间复杂度O(logn)
+func (this *TreapSortedSet[K, V]) CountByScore(min float64, minEx bool, max float64, maxEx bool) int {
+	start, end := this.Tp.rankRangeByScore(newRangeSpecified(min, minEx, max, maxEx))
+	if start == 0 {
+		return 0
+	}
+	return end - start + 1
+}
+
+// 通过分数范围(开闭区间由调用者指定)删除若干数据
+func (this *TreapSortedSet[K, V]) DeleteRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V] {
+	start, end := this.Tp.rankRangeByScore(newRangeSpecified(min, minEx, max, maxEx))
+	if start == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.DeleteRangeByRank(start, end)
+}
+
+/*
+	值相关操作(按字典序的范围操作)
+	只有当所有元素的分数都相同时(比如同一档位中的成员),结果才有意义
+*/
+
+// 通过值范围(开闭区间、是否有界由调用者指定)得到若干数据
+func (this *TreapSortedSet[K, V]) GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	start, end := this.Tp.rankRangeByValue(r)
+	if start == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.GetRangeByRank(start, end)
+}
+
+// 统计值范围内的数据数量
+func (this *TreapSortedSet[K, V]) CountByValue(r *ValueRangeSpecified[V]) int {
+	start, end := this.Tp.rankRangeByValue(r)
+	if start == 0 {
+		return 0
+	}
+	return end - start + 1
+}
+
+// 通过值范围(开闭区间、是否有界由调用者指定)删除若干数据
+func (this *TreapSortedSet[K, V]) DeleteRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	start, end := this.Tp.rankRangeByValue(r)
+	if start == 0 {
+		return []*NodeData[K, V]{}
+	}
+	return this.DeleteRangeByRank(start, end)
+}
+
+/*
+	遍历
+	遍历过程中不能修改有序集合
+*/
+
+// 按分数从低到高遍历所有数据
+func (this *TreapSortedSet[K, V]) All() iter.Seq2[int, *NodeData[K, V]] {
+	return this.Tp.Ascend(1)
+}
+
+// 按分数从高到低遍历所有数据(产生的是正序排名)
+func (this *TreapSortedSet[K, V]) Backward() iter.Seq2[int, *NodeData[K, V]] {
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		if this.Length() == 0 {
+			return
+		}
+		for rank, data := range this.Tp.Descend(this.Length()) {
+			if !yield(rank, data) {
+				return
+			}
+		}
+	}
+}
+
+// 遍历指定排名范围的数据
+func (this *TreapSortedSet[K, V]) IterRangeByRank(start int, end int) iter.Seq2[int, *NodeData[K, V]] {
+	if start > end {
+		start, end = end, start
+	}
+	assert.Assert(start > 0, "rank范围不合法, start:", start, " end:", end)
+	
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		for rank, data := range this.Tp.Ascend(start) {
+			if rank > end || !yield(rank, data) {
+				return
+			}
+		}
+	}
+}
+
+// 遍历指定分数范围的数据
+func (this *TreapSortedSet[K, V]) IterRangeByScore(min float64, minEx bool, max float64, maxEx bool) iter.Seq2[int, *NodeData[K, V]] {
+	r := newRangeSpecified(min, minEx, max, maxEx)
+	
+	return func(yield func(int, *NodeData[K, V]) bool) {
+		start, end := this.Tp.rankRangeByScore(r)
+		if start == 0 {
+			return
+		}
+		for rank, data := range this.Tp.Ascend(start) {
+			if rank > end || !yield(rank, data) {
+				return
+			}
+		}
+	}
+}
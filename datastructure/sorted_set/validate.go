@@ -0,0 +1,218 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 跳跃表和有序集合的结构校验
+// 检查每一层的跨度、后退指针、尾结点、高度、分数和，以及哈希表和跳跃表是否一致
+// 沿最下层遍历一次，同时记录每一高度的最后一个结点和它的排名(和按顺序构建时一样)，时间复杂度O(n)
+// 校验不会修改任何数据(不会删除过期的元素)，可以用来检查从快照加载的数据或者排查线上问题
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrValidate = errors.New("校验:结构不合法")
+
+func validateError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrValidate, fmt.Sprintf(format, args...))
+}
+
+// 校验跳跃表的结构，返回发现的第一个错误(错误信息中包含出错的高度和排名)
+func (this *SkipList[K, V]) Validate() error {
+	if this.Head == nil || this.Head.High() != SKIPLIST_MAXLEVEL {
+		return validateError("头结点不正确")
+	}
+	if this.Level < 0 || this.Level > SKIPLIST_MAXLEVEL {
+		return validateError("高度超出范围, level:%d", this.Level)
+	}
+	if this.Length < 0 {
+		return validateError("长度不能是负数, length:%d", this.Length)
+	}
+	if this.Head.Backward != nil {
+		return validateError("头结点的后退指针必须是nil")
+	}
+	for i := this.Level; i < SKIPLIST_MAXLEVEL; i++ {
+		if this.Head.Levels[i].Forward != nil {
+			return validateError("头结点在超过跳跃表高度的第%d层有后继结点, level:%d", i, this.Level)
+		}
+	}
+	
+	// 每一高度的最后一个结点和它的排名
+	last := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
+	lastRank := [SKIPLIST_MAXLEVEL]int{}
+	for i := 0; i < this.Level; i++ {
+		last[i] = this.Head
+	}
+	var prev *Node[K, V]
+	rank := 0
+	maxHigh := 0
+	for current := this.Head.Levels[0].Forward; current != nil; current = current.Levels[0].Forward {
+		rank++
+		if rank > this.Length {
+			// 也可以避免在有环时死循环
+			return validateError("结点数量超过了长度, length:%d", this.Length)
+		}
+		high := current.High()
+		if high < 1 || high > this.Level {
+			return validateError("结点的高度超出范围, rank:%d high:%d level:%d", rank, high, this.Level)
+		}
+		if current.Data == nil {
+			return validateError("结点的数据是nil, rank:%d", rank)
+		}
+		if math.IsNaN(current.Data.Score) {
+			return validateError("分数不是数字, rank:%d", rank)
+		}
+		if current.Backward != prev {
+			return validateError("后退指针不正确, rank:%d", rank)
+		}
+		if prev != nil && !this.dataLessThan(prev.Data, current.Data) {
+			return validateError("结点不是严格递增的, rank:%d", rank)
+		}
+		for i := 0; i < high; i++ {
+			// 每一高度的最后一个结点必须指向当前结点，跨度就是两者排名之差
+			if last[i].Levels[i].Forward != current {
+				return validateError("第%d层的向前指针不正确, rank:%d", i, lastRank[i])
+			}
+			if last[i].Levels[i].Span != rank-lastRank[i] {
+				return validateError("第%d层的跨度不正确, rank:%d span:%d expected:%d",
+					i, lastRank[i], last[i].Levels[i].Span, rank-lastRank[i])
+			}
+			last[i] = current
+			lastRank[i] = rank
+		}
+		maxHigh = max(maxHigh, high)
+		prev = current
+	}
+	if rank != this.Length {
+		return validateError("长度不正确, length:%d 实际结点数量:%d", this.Length, rank)
+	}
+	if this.Tail != prev {
+		return validateError("尾结点不正确")
+	}
+	// 删除结点之后高度至少是1，所以空的跳跃表的高度可能是0或者1
+	if rank > 0 && this.Level != maxHigh || rank == 0 && this.Level > 1 {
+		return validateError("高度不正确, level:%d 结点的最大高度:%d", this.Level, maxHigh)
+	}
+	for i := 0; i < this.Level; i++ {
+		if last[i].Levels[i].Forward != nil {
+			return validateError("第%d层的最后一个结点有后继结点, rank:%d", i, lastRank[i])
+		}
+		// 最后一个结点到nil的跨度等于长度减去其排名
+		if last[i].Levels[i].Span != this.Length-lastRank[i] {
+			return validateError("第%d层的跨度不正确, rank:%d span:%d expected:%d",
+				i, lastRank[i], last[i].Levels[i].Span, this.Length-lastRank[i])
+		}
+	}
+	if this.SumEnabled {
+		return this.validateSums()
+	}
+	return nil
+}
+
+// 校验每一层的分数和(按和recomputeSum相同的顺序求和，结果必须完全相等)
+func (this *SkipList[K, V]) validateSums() error {
+	for i := 0; i < this.Level; i++ {
+		rank := 0
+		for current := this.Head; current != nil; current = current.Levels[i].Forward {
+			level := current.Levels[i]
+			sum := 0.0
+			if i == 0 {
+				if level.Forward != nil {
+					sum = level.Forward.Data.Score
+				}
+			} else {
+				for one := current; one != level.Forward; one = one.Levels[i-1].Forward {
+					sum += one.Levels[i-1].Sum
+				}
+			}
+			if level.Sum != sum {
+				return validateError("第%d层的分数和不正确, rank:%d sum:%v expected:%v", i, rank, level.Sum, sum)
+			}
+			rank += level.Span
+		}
+	}
+	return nil
+}
+
+// 校验紧凑编码的数组:数据不能为nil，按(分数, 卫星数据)严格递增，key不重复
+func (this *compactList[K, V]) Validate() error {
+	keys := make(map[K]struct{}, len(this.datas))
+	for i, data := range this.datas {
+		if data == nil {
+			return validateError("数据不能为nil, rank:%d", i+1)
+		}
+		if math.IsNaN(data.Score) {
+			return validateError("分数不是数字, rank:%d", i+1)
+		}
+		if i > 0 && this.compareData(this.datas[i-1], data) >= 0 {
+			return validateError("数据不是严格递增的, rank:%d", i+1)
+		}
+		if _, has := keys[data.Key]; has {
+			return validateError("key重复, rank:%d key:%v", i+1, data.Key)
+		}
+		keys[data.Key] = struct{}{}
+	}
+	return nil
+}
+
+// 校验有序集合:跳跃表的结构，以及哈希表、过期时间和跳跃表是否一致
+// 紧凑编码时校验数组，跳跃表和哈希表必须为nil
+func (this *SortedSet[K, V]) Validate() error {
+	if this.compact != nil {
+		if this.Sl != nil || this.Hash != nil {
+			return validateError("紧凑编码时不能有跳跃表和哈希表")
+		}
+		if err := this.compact.Validate(); err != nil {
+			return err
+		}
+		if len(this.compact.datas) > this.compactMax {
+			return validateError("紧凑编码的元素数量超过了阈值, length:%d max:%d", len(this.compact.datas), this.compactMax)
+		}
+		if this.expire != nil {
+			for key := range this.expire.expireAt {
+				if _, has := this.lookup(key); !has {
+					return validateError("设置了过期时间的元素不存在, key:%v", key)
+				}
+			}
+		}
+		return nil
+	}
+	if err := this.Sl.Validate(); err != nil {
+		return err
+	}
+	if len(this.Hash) != this.Sl.Length {
+		return validateError("哈希表和跳跃表的长度不一致, hash:%d skiplist:%d", len(this.Hash), this.Sl.Length)
+	}
+	rank := 0
+	for current := this.Sl.Head.Levels[0].Forward; current != nil; current = current.Levels[0].Forward {
+		rank++
+		if this.Hash[current.Data.Key] != current.Data {
+			return validateError("哈希表中的数据和跳跃表不一致, rank:%d key:%v", rank, current.Data.Key)
+		}
+	}
+	if this.expire != nil {
+		for key := range this.expire.expireAt {
+			if _, has := this.Hash[key]; !has {
+				return validateError("设置了过期时间的元素不存在, key:%v", key)
+			}
+		}
+	}
+	return nil
+}
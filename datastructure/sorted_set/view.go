@@ -0,0 +1,551 @@
+// Package sorted_set.
+
+// 版权所有(Copyright)[yangyuan]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 有序集合的只读视图(多版本的结点)
+// 创建视图时不复制任何数据，视图和有序集合共享同一个跳跃表和哈希表，时间复杂度O(1)
+// 有视图时，有序集合在第一次修改某个结点(或者哈希表中的某个key)之前，把它原来的状态记录到撤销日志(undoLog)中
+// 每次写操作只复制它修改的O(logn)个结点；视图读取结点时，先在日志中查找创建视图时的状态
+// 每创建一个视图开始一段新的日志(上一段日志没有记录时复用)，日志按创建顺序链接起来
+// 视图从创建时的日志开始向后查找，找到的第一个记录就是该结点在创建视图时的状态
+// 结点携带的数据(NodeData)也按日志区分归属:当前日志中复制出来的数据只属于有序集合，可以原地修改
+// 所有视图都释放(Release或者被垃圾回收)之后，下一次修改时丢弃日志，之后不再复制
+// 紧凑编码时，视图直接引用有序集合的数组，有序集合在下一次修改之前复制整个数组(元素很少)，数据的归属同样按代区分
+// 视图和有序集合共享内存，有序集合被修改时不能同时读取视图(并发安全的有序集合创建的视图在读取时会加读锁)
+// 视图中的数据不能被调用者修改
+
+// 作者:  yangyuan
+// 创建日期:2026/10/18
+package sorted_set
+
+import (
+	"io"
+	"iter"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// 撤销日志:记录从日志开始到现在被修改过的结点和key在修改之前的状态
+type undoLog[K comparable, V any] struct {
+	nodes map[*Node[K, V]]*Node[K, V]  // 结点第一次被修改之前的副本(nil表示结点是日志开始之后创建的，视图不会访问到)
+	hash  map[K]*NodeData[K, V]        // key第一次被修改之前的数据(nil表示key之前不存在)
+	owned map[*NodeData[K, V]]struct{} // 日志开始之后复制或者插入的数据(视图不会引用，可以原地修改)
+	next  *undoLog[K, V]               // 下一段日志(之后创建的视图开始的日志)
+}
+
+func newUndoLog[K comparable, V any]() *undoLog[K, V] {
+	return &undoLog[K, V]{
+		nodes: map[*Node[K, V]]*Node[K, V]{},
+		hash:  map[K]*NodeData[K, V]{},
+		owned: map[*NodeData[K, V]]struct{}{},
+	}
+}
+
+func (this *undoLog[K, V]) empty() bool {
+	return len(this.nodes) == 0 && len(this.hash) == 0 && len(this.owned) == 0
+}
+
+// 视图的引用计数
+type viewRef struct {
+	views    *atomic.Int64 // 有序集合当前的视图数量
+	released atomic.Bool   // 已经释放(Release可以重复调用)
+	mu       sync.Locker   // 通过并发安全的有序集合创建时才有(读锁)，读取视图和移动游标时加锁
+}
+
+func (this *viewRef) release() {
+	if this.released.CompareAndSwap(false, true) {
+		this.views.Add(-1)
+	}
+}
+
+func (this *SkipList[K, V]) lockView() {
+	if this.view != nil && this.view.mu != nil {
+		this.view.mu.Lock()
+	}
+}
+
+func (this *SkipList[K, V]) unlockView() {
+	if this.view != nil && this.view.mu != nil {
+		this.view.mu.Unlock()
+	}
+}
+
+// 读取结点时使用的状态
+// 视图中返回创建视图时的状态，否则就是结点本身
+func (this *SkipList[K, V]) state(node *Node[K, V]) *Node[K, V] {
+	if this.view == nil {
+		return node
+	}
+	for log := this.undo; log != nil; log = log.next {
+		if old, ok := log.nodes[node]; ok {
+			if old != nil {
+				return old
+			}
+			break
+		}
+	}
+	return node
+}
+
+// 修改结点之前调用:有视图时，记录结点第一次被修改之前的状态
+func (this *SkipList[K, V]) save(node *Node[K, V]) {
+	if this.undo == nil {
+		return
+	}
+	if _, ok := this.undo.nodes[node]; ok {
+		return
+	}
+	levels := make([]SkipListLevel[K, V], len(node.Levels))
+	old := &Node[K, V]{
+		Levels:   make([]*SkipListLevel[K, V], len(node.Levels)),
+		Backward: node.Backward,
+		Data:     node.Data,
+	}
+	for i, level := range node.Levels {
+		levels[i] = *level
+		old.Levels[i] = &levels[i]
+	}
+	this.undo.nodes[node] = old
+}
+
+// 插入新结点时调用:新结点和它的数据都不会被视图访问，之后不需要记录
+func (this *SkipList[K, V]) created(node *Node[K, V]) {
+	if this.undo == nil {
+		return
+	}
+	this.undo.nodes[node] = nil
+	this.undo.owned[node.Data] = struct{}{}
+}
+
+// 排名对应的数据(排名超出范围时返回nil)
+func (this *SkipList[K, V]) dataByRank(rank int) *NodeData[K, V] {
+	node := this.GetNodeByRank(rank)
+	if node == nil {
+		return nil
+	}
+	return this.state(node).Data
+}
+
+// 替换结点携带的数据(新旧数据的分数和卫星数据必须相同)
+func (this *SkipList[K, V]) replaceData(old *NodeData[K, V], data *NodeData[K, V]) {
+	prevNodes := [SKIPLIST_MAXLEVEL]*Node[K, V]{}
+	node, ok := this.findNode(old, &prevNodes)
+	if ok {
+		this.save(node)
+		node.Data = data
+	}
+}
+
+// 通过key查找数据
+// 视图中返回创建视图时的数据
+func (this *SortedSet[K, V]) lookup(key K) (*NodeData[K, V], bool) {
+	if this.compact != nil {
+		// 紧凑编码的视图中的数组不会被修改，不需要查找日志
+		if i := this.compact.indexOf(key); i >= 0 {
+			return this.compact.datas[i], true
+		}
+		return nil, false
+	}
+	if this.Sl.view != nil {
+		for log := this.Sl.undo; log != nil; log = log.next {
+			if old, ok := log.hash[key]; ok {
+				return old, old != nil
+			}
+		}
+	}
+	data, exist := this.Hash[key]
+	return data, exist
+}
+
+// 修改哈希表中的key之前调用:有视图时，记录key第一次被修改之前的数据
+func (this *SortedSet[K, V]) saveKey(key K) {
+	log := this.Sl.undo
+	if log == nil {
+		return
+	}
+	if _, ok := log.hash[key]; ok {
+		return
+	}
+	log.hash[key] = this.Hash[key]
+}
+
+// 修改跳跃表或者哈希表(紧凑编码时是数组)之前调用
+// 所有视图都已经释放时丢弃日志，之后的修改不再记录
+// 紧凑编码时，数组仍然被视图引用就先复制一份
+func (this *SortedSet[K, V]) beforeWrite() {
+	if this.compact != nil {
+		c := this.compact
+		if c.owned != nil && this.views.Load() == 0 {
+			c.owned, c.shared = nil, false
+		}
+		if c.shared {
+			c.datas = slices.Clone(c.datas)
+			c.shared = false
+		}
+		return
+	}
+	if this.Sl.undo != nil && this.views.Load() == 0 {
+		this.Sl.undo = nil
+	}
+}
+
+// 更新分数之前调用
+// 数据可能被视图引用时(不是当前日志中复制出来的)，复制一份再更新，返回复制的数据
+func (this *SortedSet[K, V]) ownData(data *NodeData[K, V]) *NodeData[K, V] {
+	if c := this.compact; c != nil {
+		if c.owned == nil {
+			return data
+		}
+		if _, ok := c.owned[data]; ok {
+			return data
+		}
+		one := *data
+		c.datas[c.indexOf(data.Key)] = &one
+		c.owned[&one] = struct{}{}
+		return &one
+	}
+	log := this.Sl.undo
+	if log == nil {
+		return data
+	}
+	if _, ok := log.owned[data]; ok {
+		return data
+	}
+	one := *data
+	this.Sl.replaceData(data, &one)
+	this.saveKey(data.Key)
+	this.Hash[data.Key] = &one
+	log.owned[&one] = struct{}{}
+	return &one
+}
+
+// 有序集合的只读视图
+type SortedSetView[K comparable, V any] struct {
+	ss *SortedSet[K, V]
+}
+
+// 创建当前状态的只读视图
+// 已经过期的元素不会出现在视图中；视图中的元素不会再过期
+// 创建过视图之后，更新分数会替换元素的数据，调用者之前持有的数据不会随之更新
+// 删除时返回的数据可能仍然被视图引用，调用者也不能修改
+// 视图不再使用时应该调用Release，否则要等到视图被垃圾回收之后，有序集合才停止记录撤销日志
+func (this *SortedSet[K, V]) Snapshot() *SortedSetView[K, V] {
+	this.expireDue()
+	if this.views == nil {
+		this.views = &atomic.Int64{}
+	}
+	this.views.Add(1)
+	ref := &viewRef{views: this.views}
+	// 迭代器和游标也引用视图的跳跃表(紧凑编码时是视图的有序集合)，它们都不再使用时才会释放
+	runtime.SetFinalizer(ref, (*viewRef).release)
+	if c := this.compact; c != nil {
+		// 紧凑编码:视图引用当前的数组，有序集合修改之前先复制；之后复制或者插入的数据属于新的一代
+		c.shared = true
+		c.owned = map[*NodeData[K, V]]struct{}{}
+		return &SortedSetView[K, V]{
+			ss: &SortedSet[K, V]{
+				compact:    newCompactList(c.cmp, c.levelUpProb, c.sumEnabled, c.datas),
+				compactMax: this.compactMax,
+				view:       ref,
+			},
+		}
+	}
+	if log := this.Sl.undo; log == nil || !log.empty() {
+		// 上一段日志已经有记录，开始新的一段
+		this.Sl.undo = newUndoLog[K, V]()
+		if log != nil {
+			log.next = this.Sl.undo
+		}
+	}
+	sl := *this.Sl
+	sl.view = ref
+	return &SortedSetView[K, V]{
+		ss: &SortedSet[K, V]{
+			Sl:   &sl,
+			Hash: this.Hash,
+			view: ref,
+		},
+	}
+}
+
+// 释放视图(可以重复调用)
+// 释放之后不能再使用视图以及通过视图创建的游标和迭代器
+func (this *SortedSetView[K, V]) Release() {
+	this.ss.view.release()
+}
+
+// 紧凑编码的视图独占数组，读取时不需要加锁
+func (this *SortedSetView[K, V]) lock() {
+	if this.ss.Sl != nil {
+		this.ss.Sl.lockView()
+	}
+}
+
+func (this *SortedSetView[K, V]) unlock() {
+	if this.ss.Sl != nil {
+		this.ss.Sl.unlockView()
+	}
+}
+
+func (this *SortedSetView[K, V]) Length() int {
+	this.lock()
+	defer this.unlock()
+	return this.ss.Length()
+}
+
+func (this *SortedSetView[K, V]) Get(key K) *NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.Get(key)
+}
+
+/*
+	排名相关操作
+*/
+
+func (this *SortedSetView[K, V]) GetRank(key K) int {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRank(key)
+}
+
+func (this *SortedSetView[K, V]) GetByRank(rank int) *NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetByRank(rank)
+}
+
+func (this *SortedSetView[K, V]) GetRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRangeByRank(start, end)
+}
+
+func (this *SortedSetView[K, V]) GetRevRank(key K) int {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRevRank(key)
+}
+
+func (this *SortedSetView[K, V]) GetByRevRank(rank int) *NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetByRevRank(rank)
+}
+
+func (this *SortedSetView[K, V]) GetRevRangeByRank(start int, end int) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRevRangeByRank(start, end)
+}
+
+/*
+	分数相关操作
+*/
+
+func (this *SortedSetView[K, V]) GetRangeByScore(min float64, minEx bool, max float64, maxEx bool) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRangeByScore(min, minEx, max, maxEx)
+}
+
+func (this *SortedSetView[K, V]) GetRevRangeByScore(max float64, maxEx bool, min float64, minEx bool) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRevRangeByScore(max, maxEx, min, minEx)
+}
+
+func (this *SortedSetView[K, V]) GetRangeByScoreLimit(min float64, minEx bool, max float64, maxEx bool, offset int, count int) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRangeByScoreLimit(min, minEx, max, maxEx, offset, count)
+}
+
+func (this *SortedSetView[K, V]) GetRevRangeByScoreLimit(max float64, maxEx bool, min float64, minEx bool, offset int, count int) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRevRangeByScoreLimit(max, maxEx, min, minEx, offset, count)
+}
+
+func (this *SortedSetView[K, V]) CountByScore(min float64, minEx bool, max float64, maxEx bool) int {
+	this.lock()
+	defer this.unlock()
+	return this.ss.CountByScore(min, minEx, max, maxEx)
+}
+
+/*
+	值相关操作
+*/
+
+func (this *SortedSetView[K, V]) GetRangeByValue(r *ValueRangeSpecified[V]) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRangeByValue(r)
+}
+
+func (this *SortedSetView[K, V]) CountByValue(r *ValueRangeSpecified[V]) int {
+	this.lock()
+	defer this.unlock()
+	return this.ss.CountByValue(r)
+}
+
+/*
+	分数统计(有序集合开启了分数和的维护时才能使用)
+*/
+
+func (this *SortedSetView[K, V]) SumByRank(start int, end int) (float64, int) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.SumByRank(start, end)
+}
+
+func (this *SortedSetView[K, V]) SumByScore(min float64, minEx bool, max float64, maxEx bool) (float64, int) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.SumByScore(min, minEx, max, maxEx)
+}
+
+func (this *SortedSetView[K, V]) AvgByRank(start int, end int) (float64, bool) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.AvgByRank(start, end)
+}
+
+func (this *SortedSetView[K, V]) AvgByScore(min float64, minEx bool, max float64, maxEx bool) (float64, bool) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.AvgByScore(min, minEx, max, maxEx)
+}
+
+func (this *SortedSetView[K, V]) MinMaxByRank(start int, end int) (float64, float64, bool) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.MinMaxByRank(start, end)
+}
+
+/*
+	百分位和分桶
+*/
+
+func (this *SortedSetView[K, V]) GetByPercentile(p float64) *NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetByPercentile(p)
+}
+
+func (this *SortedSetView[K, V]) GetByRevPercentile(p float64) *NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetByRevPercentile(p)
+}
+
+func (this *SortedSetView[K, V]) GetPercentile(key K) (float64, bool) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetPercentile(key)
+}
+
+func (this *SortedSetView[K, V]) GetRevPercentile(key K) (float64, bool) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.GetRevPercentile(key)
+}
+
+func (this *SortedSetView[K, V]) ScoreQuantile(q float64) (float64, bool) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.ScoreQuantile(q)
+}
+
+func (this *SortedSetView[K, V]) Buckets(n int) []*Bucket[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.Buckets(n)
+}
+
+func (this *SortedSetView[K, V]) BucketOf(key K, n int) int {
+	this.lock()
+	defer this.unlock()
+	return this.ss.BucketOf(key, n)
+}
+
+/*
+	随机获取元素
+*/
+
+func (this *SortedSetView[K, V]) RandMember(count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.RandMember(count, repeat, mode)
+}
+
+func (this *SortedSetView[K, V]) RandMemberByRank(start int, end int, count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.RandMemberByRank(start, end, count, repeat, mode)
+}
+
+func (this *SortedSetView[K, V]) RandMemberByScore(min float64, minEx bool, max float64, maxEx bool, count int, repeat bool, mode RandMode) []*NodeData[K, V] {
+	this.lock()
+	defer this.unlock()
+	return this.ss.RandMemberByScore(min, minEx, max, maxEx, count, repeat, mode)
+}
+
+/*
+	遍历(通过并发安全的有序集合创建的视图，每次移动游标时加读锁，遍历的过程中可以修改有序集合)
+*/
+
+func (this *SortedSetView[K, V]) NewIterator() *Iterator[K, V] {
+	return this.ss.NewIterator()
+}
+
+func (this *SortedSetView[K, V]) All() iter.Seq2[int, *NodeData[K, V]] {
+	return this.ss.All()
+}
+
+func (this *SortedSetView[K, V]) Backward() iter.Seq2[int, *NodeData[K, V]] {
+	return this.ss.Backward()
+}
+
+func (this *SortedSetView[K, V]) IterRangeByRank(start int, end int) iter.Seq2[int, *NodeData[K, V]] {
+	return this.ss.IterRangeByRank(start, end)
+}
+
+func (this *SortedSetView[K, V]) IterRangeByScore(min float64, minEx bool, max float64, maxEx bool) iter.Seq2[int, *NodeData[K, V]] {
+	return this.ss.IterRangeByScore(min, minEx, max, maxEx)
+}
+
+/*
+	持久化(比如保存结算时刻的排行榜)
+*/
+
+func (this *SortedSetView[K, V]) WriteSnapshot(w io.Writer, kc Codec[K], vc Codec[V]) error {
+	this.lock()
+	defer this.unlock()
+	return this.ss.WriteSnapshot(w, kc, vc)
+}
+
+func (this *SortedSetView[K, V]) MarshalSnapshot(kc Codec[K], vc Codec[V]) ([]byte, error) {
+	this.lock()
+	defer this.unlock()
+	return this.ss.MarshalSnapshot(kc, vc)
+}
+
+func (this *SortedSetView[K, V]) SaveSnapshot(file string, kc Codec[K], vc Codec[V]) error {
+	this.lock()
+	defer this.unlock()
+	return this.ss.SaveSnapshot(file, kc, vc)
+}
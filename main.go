@@ -23,12 +23,16 @@ import (
 	"github.com/stormYuanYang/yytools/algorithm/math_tools/probability_distribution"
 	"github.com/stormYuanYang/yytools/common/assert"
 	"github.com/stormYuanYang/yytools/datastructure/heap"
+	"github.com/stormYuanYang/yytools/datastructure/leaderboard"
 	"github.com/stormYuanYang/yytools/datastructure/queue"
 	"github.com/stormYuanYang/yytools/datastructure/sorted_set"
+	"github.com/stormYuanYang/yytools/datastructure/sorted_set/resp_server"
 	"github.com/stormYuanYang/yytools/datastructure/stack"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 var commandsMap = map[string]int{}
@@ -46,6 +50,11 @@ func init() {
 		Note:    "最小堆",
 		Handler: heap.HeapTest,
 	})
+	commands = append(commands, &Command{
+		Key:     "leaderboard",
+		Note:    "排行榜",
+		Handler: leaderboard.LeaderboardTest,
+	})
 	commands = append(commands, &Command{
 		Key:     "mathcommon",
 		Note:    "公共数学方法（比如gcd）",
@@ -95,6 +104,27 @@ func testAll(num int) {
 	println("\n所有测试完毕...")
 }
 
+// 启动兼容redis协议的有序集合服务器，直到收到中断信号
+func serve(args []string) {
+	addr := "127.0.0.1:6379"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+	server := resp_server.NewServer()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		server.Close()
+	}()
+	fmt.Printf("有序集合服务器开始监听: %s\n", addr)
+	if err := server.ListenAndServe(addr); err != resp_server.ErrServerClosed {
+		fmt.Printf("Command: serve Error: %+v\n", err)
+		return
+	}
+	println("有序集合服务器已关闭")
+}
+
 func main() {
 	// 第一个参数是可执行文件本身的路径
 	// 后续的参数是通过控制台传递的参数
@@ -108,11 +138,16 @@ func main() {
 		println("使用参考：yytools sorted_set 5\n表示执行5轮sorted_set相关测试代码\n yytools all 5\n表示对所有测试进行5轮测试\n")
 		println("已支持的命令:")
 		fmt.Printf("%-20s\t说明:执行所有命令\n", "all")
+		fmt.Printf("%-20s\t说明:启动兼容redis协议的有序集合服务器(yytools serve [addr]，默认127.0.0.1:6379)\n", "serve")
 		for i := 0; i < len(commands); i++ {
 			fmt.Printf("%-20s\t说明:%s\n", commands[i].Key, commands[i].Note)
 		}
 		return
 	}
+	if command == "serve" {
+		serve(args[1:])
+		return
+	}
 	
 	num, err := strconv.Atoi(args[1])
 	if err != nil {